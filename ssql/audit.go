@@ -0,0 +1,209 @@
+package ssql
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// trueの場合、実行したSQLを正規化したクエリの形ごとに、実行回数と実行時間を記録する。
+// セキュリティやパフォーマンスの監査のために、アプリケーションが実際に実行しているSQLの一覧を把握する場合に利用する。
+// 記録した一覧はQueryShapesまたはDumpQueryShapesで取得する。
+//
+// 対象はQuery、Exec、ExecReturning、Batch等で実行したSQLのみで、
+// Seq Scanのチェック等のこのパッケージが内部で実行するSQLは含まない。
+// SELECTの実行時間は、結果セットの読み出しを含まない、最初の結果を受け取るまでの時間となる。
+var RecordQueryShapes = false
+
+// 正規化したクエリの形ごとの記録
+type QueryShape struct {
+	// 正規化したクエリのハッシュ値
+	Fingerprint string `json:"fingerprint"`
+	// 正規化したクエリ
+	// リテラル、プレースホルダーは"?"に置き換え、IN ($1, $2, $3)等の並びやVALUESの複数行は1つにまとめる。
+	Query string `json:"query"`
+	Count int64  `json:"count"`
+	// 最初に記録した際の引数の個数
+	ArgCount      int           `json:"arg_count"`
+	TotalDuration time.Duration `json:"total_duration"`
+	MinDuration   time.Duration `json:"min_duration"`
+	MaxDuration   time.Duration `json:"max_duration"`
+}
+
+func (s QueryShape) AvgDuration() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.TotalDuration / time.Duration(s.Count)
+}
+
+var (
+	queryShapesMu sync.Mutex
+	queryShapes   = map[string]*QueryShape{}
+)
+
+func recordQueryShape(query string, args []any, d time.Duration) {
+	if !RecordQueryShapes {
+		return
+	}
+	normalized := normalizeQuery(query)
+	fingerprint := queryFingerprint(normalized)
+
+	queryShapesMu.Lock()
+	defer queryShapesMu.Unlock()
+	s, ok := queryShapes[fingerprint]
+	if !ok {
+		s = &QueryShape{Fingerprint: fingerprint, Query: normalized, ArgCount: len(args), MinDuration: d}
+		queryShapes[fingerprint] = s
+	}
+	s.Count++
+	s.TotalDuration += d
+	s.MinDuration = min(s.MinDuration, d)
+	s.MaxDuration = max(s.MaxDuration, d)
+}
+
+// 記録したクエリの形の一覧を、合計の実行時間の長い順に返す。
+func QueryShapes() []QueryShape {
+	queryShapesMu.Lock()
+	defer queryShapesMu.Unlock()
+	r := make([]QueryShape, 0, len(queryShapes))
+	for _, s := range queryShapes {
+		r = append(r, *s)
+	}
+	slices.SortFunc(r, func(a, b QueryShape) int {
+		if c := cmp.Compare(b.TotalDuration, a.TotalDuration); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Query, b.Query)
+	})
+	return r
+}
+
+// 記録したクエリの形の一覧（QueryShapesの結果）をJSONの配列としてwへ書き込む。
+// 実行時間はナノ秒の整数となる。
+func DumpQueryShapes(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(QueryShapes())
+}
+
+// 記録したクエリの形を全て削除する。
+func ResetQueryShapes() {
+	queryShapesMu.Lock()
+	defer queryShapesMu.Unlock()
+	queryShapes = map[string]*QueryShape{}
+}
+
+func queryFingerprint(normalized string) string {
+	h := fnv.New64a()
+	h.Write([]byte(normalized))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// 引数の値や個数の違いを除いたクエリの形に正規化する。
+// コメントは取り除き、連続する空白は1つにまとめ、引用符の無い識別子、キーワードは小文字とする。
+func normalizeQuery(query string) string {
+	parts := []queryPart{}
+	last := 0
+	for _, t := range tokenize(query) {
+		p := queryPart{text: t.text, space: len(parts) > 0 && t.pos > last}
+		switch t.kind {
+		case tokenString, tokenNumber, tokenPlaceholder:
+			p.text = "?"
+		case tokenWord:
+			p.text = strings.ToLower(t.text)
+		}
+		parts = append(parts, p)
+		last = t.pos + len(t.text)
+	}
+	parts = collapseRepeatedGroups(collapseValueLists(parts))
+
+	var b strings.Builder
+	for _, p := range parts {
+		if p.space {
+			b.WriteByte(' ')
+		}
+		b.WriteString(p.text)
+	}
+	return b.String()
+}
+
+// 正規化したクエリの要素。spaceは直前に空白があるかどうか
+type queryPart struct {
+	text  string
+	space bool
+}
+
+// "?, ?, ?"の並びを"?, ..."にまとめる。
+func collapseValueLists(parts []queryPart) []queryPart {
+	r := []queryPart{}
+	for i := 0; i < len(parts); i++ {
+		r = append(r, parts[i])
+		if parts[i].text != "?" {
+			continue
+		}
+		j := i
+		for j+2 < len(parts) && parts[j+1].text == "," && parts[j+2].text == "?" {
+			j += 2
+		}
+		if j > i {
+			r = append(r, queryPart{text: ","}, queryPart{text: "...", space: true})
+			i = j
+		}
+	}
+	return r
+}
+
+// VALUES (?, ...), (?, ...)のように同じ形の括弧の並びを"(?, ...), ..."にまとめる。
+func collapseRepeatedGroups(parts []queryPart) []queryPart {
+	r := []queryPart{}
+	for i := 0; i < len(parts); i++ {
+		r = append(r, parts[i])
+		if parts[i].text != "(" {
+			continue
+		}
+		end := matchingParen(parts, i)
+		if end < 0 {
+			continue
+		}
+		group := parts[i : end+1]
+		next := end + 1
+		for next < len(parts) && parts[next].text == "," && sameTexts(parts[next+1:min(next+1+len(group), len(parts))], group) {
+			next += 1 + len(group)
+		}
+		if next == end+1 {
+			continue
+		}
+		r = append(r, parts[i+1:end+1]...)
+		r = append(r, queryPart{text: ","}, queryPart{text: "...", space: true})
+		i = next - 1
+	}
+	return r
+}
+
+// 空白の有無を除いて同じ要素の並びかどうか
+func sameTexts(a, b []queryPart) bool {
+	return slices.EqualFunc(a, b, func(x, y queryPart) bool { return x.text == y.text })
+}
+
+func matchingParen(parts []queryPart, start int) int {
+	depth := 0
+	for i := start; i < len(parts); i++ {
+		switch parts[i].text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
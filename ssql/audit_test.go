@@ -0,0 +1,78 @@
+package ssql
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestNormalizeQuery$ ./ssql
+func TestNormalizeQuery(t *testing.T) {
+	for _, tt := range []struct {
+		query    string
+		expected string
+	}{
+		{"SELECT * FROM users WHERE id = $1", "select * from users where id = ?"},
+		{"select *\n  from users -- comment\n where id = 10", "select * from users where id = ?"},
+		{"SELECT * FROM users WHERE name = 'a' AND id IN ($1, $2, $3)", "select * from users where name = ? and id in (?, ...)"},
+		{`SELECT u."Name" FROM users u WHERE u.id = $1`, `select u."Name" from users u where u.id = ?`},
+		{"INSERT INTO users (id, name) VALUES ($1, $2), ($3, $4), ($5, $6)", "insert into users (id, name) values (?, ...), ..."},
+		{"INSERT INTO users (id, name) VALUES ($1, $2)", "insert into users (id, name) values (?, ...)"},
+		{"SELECT count(*) FROM users WHERE team_id = $1", "select count(*) from users where team_id = ?"},
+	} {
+		testutil.AssertEqual(t, normalizeQuery(tt.query), tt.expected)
+	}
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestRecordQueryShapes$ ./ssql
+func TestRecordQueryShapes(t *testing.T) {
+	org := RecordQueryShapes
+	defer func() { RecordQueryShapes = org }()
+	defer ResetQueryShapes()
+
+	t.Run("disabled", func(t *testing.T) {
+		RecordQueryShapes = false
+		ResetQueryShapes()
+		recordQueryShape("SELECT * FROM users WHERE id = $1", []any{1}, time.Millisecond)
+		testutil.AssertEqual(t, len(QueryShapes()), 0)
+	})
+
+	t.Run("record", func(t *testing.T) {
+		RecordQueryShapes = true
+		ResetQueryShapes()
+		recordQueryShape("SELECT * FROM users WHERE id = $1", []any{1}, 3*time.Millisecond)
+		recordQueryShape("select * from users where id = 2", []any{}, 1*time.Millisecond)
+		recordQueryShape("SELECT * FROM users WHERE id IN ($1, $2)", []any{1, 2}, 10*time.Millisecond)
+
+		shapes := QueryShapes()
+		testutil.AssertEqual(t, len(shapes), 2)
+		// 合計の実行時間の長い順
+		testutil.AssertEqual(t, shapes[0].Query, "select * from users where id in (?, ...)")
+		s := shapes[1]
+		testutil.AssertEqual(t, s.Query, "select * from users where id = ?")
+		testutil.AssertEqual(t, s.Fingerprint, queryFingerprint(s.Query))
+		testutil.AssertEqual(t, s.Count, int64(2))
+		testutil.AssertEqual(t, s.ArgCount, 1)
+		testutil.AssertEqual(t, s.TotalDuration, 4*time.Millisecond)
+		testutil.AssertEqual(t, s.MinDuration, 1*time.Millisecond)
+		testutil.AssertEqual(t, s.MaxDuration, 3*time.Millisecond)
+		testutil.AssertEqual(t, s.AvgDuration(), 2*time.Millisecond)
+	})
+
+	t.Run("dump", func(t *testing.T) {
+		RecordQueryShapes = true
+		ResetQueryShapes()
+		recordQueryShape("DELETE FROM users WHERE id = $1", []any{1}, time.Millisecond)
+
+		var buf bytes.Buffer
+		testutil.AssertEqual(t, DumpQueryShapes(&buf), nil)
+		var r []QueryShape
+		testutil.AssertEqual(t, json.Unmarshal(buf.Bytes(), &r), nil)
+		testutil.AssertEqual(t, len(r), 1)
+		testutil.AssertEqual(t, r[0].Query, "delete from users where id = ?")
+		testutil.AssertEqual(t, r[0].Count, int64(1))
+	})
+}
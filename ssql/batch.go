@@ -0,0 +1,250 @@
+package ssql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// 複数のSQLをまとめて1回の通信で送信する。（pgxのバッチモードを利用）
+//
+// QueueExecやQueueQueryで登録したSQLは、Sendを呼ぶまで実行されない。
+// 各SQLに対するチェック（WHERE句の有無等）は登録時に行われ、
+// 違反した場合はQueryやExecと同様にpanicまたはerrorとなる。（errorはSendの戻り値として返される）
+// 結果はSend後にそれぞれの戻り値から取得する。
+//
+//	b := ssql.NewBatch()
+//	r1 := b.QueueExec("UPDATE users SET name = $1, updated_at = now() WHERE id = $2", name, id)
+//	r2 := ssql.QueueQuery(b, &User{}, "SELECT * FROM users WHERE id = $1", id)
+//	err := b.Send(nil)
+type Batch struct {
+	items []*batchItem
+}
+
+type batchItem struct {
+	query string
+	args  []any
+	opt   *options
+	// 登録時のチェックで返されたerror。Sendの戻り値として返す。
+	err error
+	// 書き込みを行うSQL（QueueExec）。送信後にキャッシュを削除する。
+	write bool
+	// バッチの結果から1つ分を読み出す。
+	read func(br pgx.BatchResults) error
+}
+
+func NewBatch() *Batch {
+	return &Batch{}
+}
+
+func (b *Batch) Len() int {
+	return len(b.items)
+}
+
+// Execの結果
+type BatchExecResult struct {
+	rowsAffected int64
+}
+
+func (r *BatchExecResult) RowsAffected() int64 {
+	return r.rowsAffected
+}
+
+// Queryの結果
+type BatchQueryResult[M any] struct {
+	rows []M
+}
+
+func (r *BatchQueryResult[M]) Rows() []M {
+	return r.rows
+}
+
+// INSERT、UPDATE、DELETE等をバッチへ登録する。
+func (b *Batch) QueueExec(query string, args ...any) *BatchExecResult {
+	args, opt := splitArgs(args)
+	query, args = inlineSQLValues(query, args)
+	err := checkBatchQuery(args, opt, func() { checkExecQuery(query, args, opt) })
+
+	r := &BatchExecResult{}
+	b.items = append(b.items, &batchItem{
+		query: query,
+		args:  args,
+		opt:   opt,
+		err:   err,
+		write: true,
+		read: func(br pgx.BatchResults) error {
+			ct, err := br.Exec()
+			if err != nil {
+				return err
+			}
+			r.rowsAffected = ct.RowsAffected()
+			return nil
+		},
+	})
+	return r
+}
+
+// SELECTをバッチへ登録する。
+// 取得したレコードはQueryと同様に構造体へ格納される。
+func QueueQuery[M any](b *Batch, mp *M, query string, args ...any) *BatchQueryResult[M] {
+	if mp == nil {
+		panic("arg mp must not be null")
+	}
+	args, opt := splitArgs(args)
+	query, args = inlineSQLValues(query, args)
+	err := checkBatchQuery(args, opt, func() { checkSelectQuery(query, args, opt) })
+
+	r := &BatchQueryResult[M]{rows: []M{}}
+	b.items = append(b.items, &batchItem{
+		query: query,
+		args:  args,
+		opt:   opt,
+		err:   err,
+		read: func(br pgx.BatchResults) error {
+			rows, err := br.Query()
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+
+			columns := []string{}
+			for _, f := range rows.FieldDescriptions() {
+				columns = append(columns, f.Name)
+			}
+			structValue := *mp
+			targets := structScanTargets(reflect.ValueOf(&structValue).Elem(), columns, opt.columnMapping)
+			for rows.Next() {
+				structValue = *mp
+				if err := rows.Scan(targets...); err != nil {
+					panic(err)
+				}
+				r.rows = append(r.rows, structValue)
+			}
+			return rows.Err()
+		},
+	})
+	return r
+}
+
+// 登録時のチェックと同様に、パラメータ数とSQLのチェックを行う。
+func checkBatchQuery(args []any, opt *options, check func()) error {
+	if err := checkParameterCount(args); err != nil {
+		return err
+	}
+	return guard(opt.ctx, opt.guard, check)
+}
+
+// 登録したSQLをまとめて送信し、結果を読み出す。
+//
+// txにはnil、*sql.DB、*sql.Connを指定できる。
+// database/sqlの*sql.Txからはpgxのコネクションを取得できないため、
+// Transaction内（*sql.Txを指定した場合）では利用できず、PanicBatchUnsupportedExecutorのpanicとなる。
+// トランザクション内で複数のSQLを実行する場合はExec等を個別に呼び出すこと。
+// （バッチはトランザクションが無い場合は暗黙のトランザクション内で実行されるため、すべてのSQLがまとめてコミットされる。）
+//
+// 登録時のチェックでerrorとなったSQLがある場合は、送信せずにそのerrorを返す。
+// いずれかのSQLでエラーが発生した場合はその時点で中断し、そのerrorを返す。
+func (b *Batch) Send(tx Executor) error {
+	return b.SendContext(context.Background(), tx)
+}
+
+func (b *Batch) SendContext(ctx context.Context, tx Executor) error {
+	if !IsPostgres() {
+		panic(PanicBatchRequiresPostgres)
+	}
+	if len(b.items) == 0 {
+		return nil
+	}
+
+	for _, item := range b.items {
+		if item.err != nil {
+			return item.err
+		}
+	}
+	for _, item := range b.items {
+		if err := checkSeqScanBeforeExecOnDebug(ctx, tx, item.query, item.args, item.opt); err != nil {
+			return err
+		}
+	}
+
+	var conn *sql.Conn
+	switch e := getExecutor(tx).(type) {
+	case *sql.DB:
+		c, err := e.Conn(ctx)
+		if err != nil {
+			if e := queryError(err, b.query(), nil, time.Now()); e != nil {
+				return e
+			}
+			panic(err)
+		}
+		defer c.Close()
+		conn = c
+	case *sql.Conn:
+		conn = e
+	default:
+		panic(fmt.Sprintf(PanicBatchUnsupportedExecutor, tx))
+	}
+
+	var assumedErr error
+	start := time.Now()
+	err := conn.Raw(func(driverConn any) error {
+		pc := driverConn.(*stdlib.Conn).Conn()
+		pb := &pgx.Batch{}
+		for _, item := range b.items {
+			pb.Queue(annotateQuery(ctx, item.query), item.args...)
+		}
+		br := pc.SendBatch(ctx, pb)
+		defer br.Close()
+
+		for _, item := range b.items {
+			// バッチは1回の通信で送信されるため、各SQLの実行時間は送信から結果を読み出すまでの時間となる。
+			err := item.read(br)
+			recordQueryShape(item.query, item.args, time.Since(start))
+			if err != nil {
+				if e := queryError(err, item.query, item.args, start); e != nil {
+					assumedErr = e
+					return nil
+				}
+				panic(fmt.Sprintf("query failed: %s, failed query: %s", err, item.query))
+			}
+		}
+		return br.Close()
+	})
+	// バッチは暗黙のトランザクション内で実行されるため、コミットされた後にキャッシュを削除する。
+	// 読み込みのみのバッチでは、以降の読み込みをプライマリへ振り分けないようにmarkWriteを呼ばない。
+	for _, item := range b.items {
+		if item.write {
+			markWrite()
+			invalidateCacheByQuery(item.query)
+		}
+	}
+	if err != nil {
+		if e := queryError(err, b.query(), nil, start); e != nil {
+			return e
+		}
+		panic(err)
+	}
+	if assumedErr != nil {
+		return assumedErr
+	}
+
+	for _, item := range b.items {
+		checkSeqScanOnDebug(tx, item.query, item.args, item.opt)
+	}
+	return nil
+}
+
+// コネクションの取得や送信自体のエラーで利用する、登録したSQLをまとめた文字列
+func (b *Batch) query() string {
+	queries := []string{}
+	for _, item := range b.items {
+		queries = append(queries, item.query)
+	}
+	return strings.Join(queries, "; ")
+}
@@ -0,0 +1,252 @@
+package ssql
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+)
+
+// メソッドチェーンでクエリを組み立てる。
+// 生成されるSQLはFind、FindLimitOffset等と同じで、各種チェックも同様に行われる。
+//
+//	users, err := ssql.Model(&User{}).Where("uid = ?", uid).Order("created_at DESC").Limit(10).Find(ctx)
+//
+// 各メソッドは新しいBuilderを返すため、途中までの条件を共有して使い回せる。
+type Builder[M any] struct {
+	mp       *M
+	tx       Executor
+	query    selectQuery
+	unscoped bool
+	preloads []string
+	table    string
+}
+
+func Model[M any](mp *M) *Builder[M] {
+	return &Builder[M]{mp: mp}
+}
+
+func (b *Builder[M]) clone() *Builder[M] {
+	c := *b
+	c.query.columns = slices.Clone(b.query.columns)
+	c.query.whereClauses = slices.Clone(b.query.whereClauses)
+	c.query.whereValues = slices.Clone(b.query.whereValues)
+	c.query.groupBy = slices.Clone(b.query.groupBy)
+	c.query.havingClauses = slices.Clone(b.query.havingClauses)
+	c.query.havingValues = slices.Clone(b.query.havingValues)
+	c.query.orderByClauses = slices.Clone(b.query.orderByClauses)
+	c.preloads = slices.Clone(b.preloads)
+	return &c
+}
+
+// 実行に利用するExecutorを指定する。指定しない場合はDBが利用される。
+func (b *Builder[M]) Tx(tx Executor) *Builder[M] {
+	c := b.clone()
+	c.tx = tx
+	return c
+}
+
+// 条件を追加する。プレースホルダーは"?"とする。
+// 複数回呼んだ場合はANDで結合される。
+func (b *Builder[M]) Where(clause string, values ...any) *Builder[M] {
+	c := b.clone()
+	c.query.whereClauses = append(c.query.whereClauses, parenthesize(clause))
+	c.query.whereValues = append(c.query.whereValues, values...)
+	return c
+}
+
+// ANDで結合した際に優先順位が変わらないように、条件は括弧で囲む。
+// （改行を挟んだOR等を文字列から確実に判定できないため、ORの有無に関わらず囲む）
+func parenthesize(clause string) string {
+	return "(" + clause + ")"
+}
+
+// Condで組み立てた条件を追加する。
+func (b *Builder[M]) WhereCond(cond Cond) *Builder[M] {
+	c := b.clone()
+	whereClauses, whereValues := WhereCond(cond)
+	c.query.whereClauses = append(c.query.whereClauses, whereClauses...)
+	c.query.whereValues = append(c.query.whereValues, whereValues...)
+	return c
+}
+
+// 取得するカラムや式を指定する。指定しない場合は"*"となる。
+// 集計結果を取得する場合はFindAsを利用する。
+func (b *Builder[M]) Select(columns ...string) *Builder[M] {
+	c := b.clone()
+	c.query.columns = append(c.query.columns, columns...)
+	return c
+}
+
+func (b *Builder[M]) Distinct() *Builder[M] {
+	c := b.clone()
+	c.query.distinct = true
+	return c
+}
+
+func (b *Builder[M]) GroupBy(columns ...string) *Builder[M] {
+	c := b.clone()
+	c.query.groupBy = append(c.query.groupBy, columns...)
+	return c
+}
+
+// HAVINGの条件を追加する。プレースホルダーは"?"とする。
+// 複数回呼んだ場合はANDで結合される。GroupByと併せて利用すること。
+func (b *Builder[M]) Having(clause string, values ...any) *Builder[M] {
+	c := b.clone()
+	c.query.havingClauses = append(c.query.havingClauses, parenthesize(clause))
+	c.query.havingValues = append(c.query.havingValues, values...)
+	return c
+}
+
+func (b *Builder[M]) Order(clause string) *Builder[M] {
+	c := b.clone()
+	c.query.orderByClauses = append(c.query.orderByClauses, clause)
+	return c
+}
+
+func (b *Builder[M]) Limit(n int) *Builder[M] {
+	c := b.clone()
+	c.query.limitOffset.Limit = &n
+	return c
+}
+
+func (b *Builder[M]) Offset(n int) *Builder[M] {
+	c := b.clone()
+	c.query.limitOffset.Offset = &n
+	return c
+}
+
+// 論理削除されたレコードも対象とする。
+func (b *Builder[M]) Unscoped() *Builder[M] {
+	c := b.clone()
+	c.unscoped = true
+	return c
+}
+
+// FindやFirstで取得したレコードのリレーションを読み込む。（Preloadを参照）
+func (b *Builder[M]) Preload(names ...string) *Builder[M] {
+	c := b.clone()
+	c.preloads = append(c.preloads, names...)
+	return c
+}
+
+// モデルのテーブル名の代わりに利用するテーブル名を指定する。（WithTableを参照）
+func (b *Builder[M]) Table(name string) *Builder[M] {
+	c := b.clone()
+	c.table = name
+	return c
+}
+
+// SQLの生成に利用するモデルを返す。
+func (b *Builder[M]) target() any {
+	return ormTarget(b.mp, []Option{WithTable(b.table)})
+}
+
+func (b *Builder[M]) scopedWhereClauses() []string {
+	if b.unscoped {
+		return b.query.whereClauses
+	}
+	return scopeSoftDelete(b.mp, b.query.whereClauses)
+}
+
+// 実行されるSELECT文と値を返す。
+func (b *Builder[M]) SQL() (string, []any) {
+	q := b.query
+	q.whereClauses = b.scopedWhereClauses()
+	return q.build(b.target())
+}
+
+func (b *Builder[M]) Find(c context.Context) ([]M, error) {
+	sql, values := b.SQL()
+	debugSQL(sql, values)
+	l, err := Query(b.tx, b.mp, sql, withContextArg(values, c)...)
+	if err != nil {
+		return nil, err
+	}
+	if err := preload(c, b.tx, l, b.preloads...); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (b *Builder[M]) First(c context.Context) (*M, error) {
+	sql, values := b.SQL()
+	debugSQL(sql, values)
+	m, err := QueryFirst(b.tx, b.mp, sql, withContextArg(values, c)...)
+	if err != nil || m == nil {
+		return m, err
+	}
+	l := []M{*m}
+	if err := preload(c, b.tx, l, b.preloads...); err != nil {
+		return nil, err
+	}
+	*m = l[0]
+	return m, nil
+}
+
+// GroupByやDistinctを指定した場合は、グループ（重複を除いた行）の数を返す。
+func (b *Builder[M]) Count(c context.Context) (int64, error) {
+	sql, values := b.countSQL()
+	debugSQL(sql, values)
+	return QueryScalar[int64](b.tx, sql, withContextArg(values, c)...)
+}
+
+func (b *Builder[M]) countSQL() (string, []any) {
+	if len(b.query.groupBy) == 0 && !b.query.distinct {
+		return getCountSQL(b.target(), b.scopedWhereClauses()), b.query.whereValues
+	}
+	// グループごとの行ではなくグループの数を数えるため、サブクエリとする。
+	q := b.query
+	q.whereClauses = b.scopedWhereClauses()
+	q.orderByClauses = nil
+	q.limitOffset = LimitOffset{}
+	// Selectが無い場合の"SELECT *"はGROUP BYと併せて利用できないため、グループのカラムのみを取得する。
+	if len(q.columns) == 0 && len(q.groupBy) > 0 {
+		q.columns = q.groupBy
+	}
+	sql, values := q.build(b.target())
+	return "SELECT COUNT(*) FROM (" + sql + ") AS grouped", values
+}
+
+func (b *Builder[M]) Exists(c context.Context) (bool, error) {
+	sql := getExistsSQL(b.target(), b.scopedWhereClauses())
+	debugSQL(sql, b.query.whereValues)
+	return QueryScalar[bool](b.tx, sql, withContextArg(b.query.whereValues, c)...)
+}
+
+// 条件に一致するレコードを更新する。updated_atは暗黙的に更新される。
+// Unscopedを指定しない場合、論理削除されたレコードは更新しない。
+func (b *Builder[M]) Update(c context.Context, setMaps map[string]any) (sql.Result, error) {
+	return withUpdateHooks(c, b.tx, b.mp, func() (sql.Result, error) {
+		if err := validateSetMaps(b.mp, setMaps); err != nil {
+			return nil, err
+		}
+		sql, values := b.updateSQL(setMaps)
+		debugSQL(sql, values)
+		return Exec(b.tx, sql, append(withContextArg(values, c), withoutUpdatedAtCheck())...)
+	})
+}
+
+func (b *Builder[M]) updateSQL(setMaps map[string]any) (string, []any) {
+	setClauses, setValues := getSetClauses(b.mp, setMaps)
+	return getUpdateSQL(b.target(), b.scopedWhereClauses(), b.query.whereValues, setClauses, setValues)
+}
+
+// Builderのクエリの結果をモデルとは別の構造体へ格納して返す。
+// SelectやGroupByで集計した結果を取得する場合に利用する。
+//
+//	type AgeCount struct {
+//		Age   int   `database:"age"`
+//		Count int64 `database:"count"`
+//	}
+//	l, err := ssql.FindAs(ctx, ssql.Model(&User{}).Select("age", "COUNT(*) AS count").Where("is_active = ?", true).GroupBy("age"), &AgeCount{})
+func FindAs[R, M any](c context.Context, b *Builder[M], rp *R) ([]R, error) {
+	sql, values := b.SQL()
+	debugSQL(sql, values)
+	return Query(b.tx, rp, sql, withContextArg(values, c)...)
+}
+
+// Builderが保持する値を変更しないように、コピーした上でオプションを追加する。
+func withContextArg(values []any, c context.Context) []any {
+	return append(slices.Clone(values), WithContext(c))
+}
@@ -0,0 +1,80 @@
+package ssql
+
+import (
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestBuilder$ ./ssql
+func TestBuilder(t *testing.T) {
+	t.Run("sql", func(t *testing.T) {
+		sql, values := Model(&TestStruct{}).Where("name = ?", "a").Where("age > ? OR age < ?", 60, 20).Order("id DESC").Limit(10).Offset(20).SQL()
+		testutil.AssertEqual(t, sql, "SELECT * FROM test_structs WHERE (name = $1) AND (age > $2 OR age < $3) ORDER BY id DESC LIMIT $4 OFFSET $5")
+		testutil.AssertDeepEqual(t, values, []any{"a", 60, 20, 10, 20})
+	})
+
+	t.Run("immutable", func(t *testing.T) {
+		base := Model(&TestStruct{}).Where("name = ?", "a")
+		b1 := base.Where("age = ?", 1)
+		b2 := base.WhereCond(Or(Eq("age", 2), IsNull("age")))
+		sql, _ := base.SQL()
+		testutil.AssertEqual(t, sql, "SELECT * FROM test_structs WHERE (name = $1)")
+		sql, _ = b1.SQL()
+		testutil.AssertEqual(t, sql, "SELECT * FROM test_structs WHERE (name = $1) AND (age = $2)")
+		sql, values := b2.SQL()
+		testutil.AssertEqual(t, sql, "SELECT * FROM test_structs WHERE (name = $1) AND (age = $2 OR age IS NULL)")
+		testutil.AssertDeepEqual(t, values, []any{"a", 2})
+	})
+
+	t.Run("soft delete", func(t *testing.T) {
+		b := Model(&TestStructWithSoftDelete{}).Where("name = ?", "a")
+		sql, _ := b.SQL()
+		testutil.AssertEqual(t, sql, `SELECT * FROM test_struct_with_soft_deletes WHERE (name = $1) AND "deleted_at" IS NULL`)
+		sql, _ = b.Unscoped().SQL()
+		testutil.AssertEqual(t, sql, `SELECT * FROM test_struct_with_soft_deletes WHERE (name = $1)`)
+	})
+	t.Run("group by having distinct", func(t *testing.T) {
+		sql, values := Model(&TestStruct{}).Select("age", "COUNT(*) AS count").Where("name <> ?", "a").GroupBy("age").Having("COUNT(*) > ?", 1).Order("age").Limit(5).SQL()
+		testutil.AssertEqual(t, sql, "SELECT age, COUNT(*) AS count FROM test_structs WHERE (name <> $1) GROUP BY age HAVING (COUNT(*) > $2) ORDER BY age LIMIT $3")
+		testutil.AssertDeepEqual(t, values, []any{"a", 1, 5})
+
+		sql, _ = Model(&TestStruct{}).Distinct().Select("name").Where("age > ?", 1).SQL()
+		testutil.AssertEqual(t, sql, "SELECT DISTINCT name FROM test_structs WHERE (age > $1)")
+	})
+
+	t.Run("count", func(t *testing.T) {
+		sql, values := Model(&TestStruct{}).Where("name = ?", "a").Order("id").countSQL()
+		testutil.AssertEqual(t, sql, "SELECT COUNT(*) FROM test_structs WHERE (name = $1)")
+		testutil.AssertDeepEqual(t, values, []any{"a"})
+
+		// グループの数を数える
+		sql, values = Model(&TestStruct{}).Where("name <> ?", "a").GroupBy("age").Having("COUNT(*) > ?", 1).Order("age").Limit(5).countSQL()
+		testutil.AssertEqual(t, sql, "SELECT COUNT(*) FROM (SELECT age FROM test_structs WHERE (name <> $1) GROUP BY age HAVING (COUNT(*) > $2)) AS grouped")
+		testutil.AssertDeepEqual(t, values, []any{"a", 1})
+
+		sql, _ = Model(&TestStruct{}).Distinct().Select("name").Where("age > ?", 1).countSQL()
+		testutil.AssertEqual(t, sql, "SELECT COUNT(*) FROM (SELECT DISTINCT name FROM test_structs WHERE (age > $1)) AS grouped")
+	})
+
+	t.Run("where precedence", func(t *testing.T) {
+		// 改行を挟んだORも括弧で囲まれる
+		sql, _ := Model(&TestStruct{}).Where("age > ?\nOR age < ?", 60, 20).Where("name = ?", "a").SQL()
+		testutil.AssertEqual(t, sql, "SELECT * FROM test_structs WHERE (age > $1\nOR age < $2) AND (name = $3)")
+	})
+
+	t.Run("update soft delete", func(t *testing.T) {
+		b := Model(&TestStructWithSoftDelete{}).Where("name = ?", "a")
+		sql, _ := b.updateSQL(map[string]any{"name": "b"})
+		testutil.AssertEqual(t, sql, `UPDATE test_struct_with_soft_deletes SET name = $1, "updated_at" = $2 WHERE (name = $3) AND "deleted_at" IS NULL`)
+		sql, _ = b.Unscoped().updateSQL(map[string]any{"name": "b"})
+		testutil.AssertEqual(t, sql, `UPDATE test_struct_with_soft_deletes SET name = $1, "updated_at" = $2 WHERE (name = $3)`)
+	})
+
+	t.Run("having without group by", func(t *testing.T) {
+		defer func() {
+			testutil.AssertEqual(t, recover(), "having must be used with group by")
+		}()
+		Model(&TestStruct{}).Where("age > ?", 1).Having("COUNT(*) > ?", 1).SQL()
+	})
+}
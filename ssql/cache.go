@@ -0,0 +1,227 @@
+package ssql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Queryの結果のキャッシュの保存先
+//
+// 値は取得した結果のスライスがそのまま渡されるため、
+// 外部のストアを利用する場合は実装側でシリアライズすること。
+type CacheStore interface {
+	Get(key string) (any, bool)
+	// tablesはクエリが参照しているテーブル。Invalidateで利用する。
+	Set(key string, value any, tables []string, ttl time.Duration)
+	// tableを参照しているエントリを削除する。
+	Invalidate(table string)
+	// すべてのエントリを削除する。
+	Clear()
+}
+
+// WithCacheを指定したQueryの結果のキャッシュの保存先
+// Exec等で書き込みを行った場合は、対象のテーブルを参照しているエントリが削除される。
+var QueryCache CacheStore = NewMemoryCacheStore()
+
+// この呼び出しの結果をttlの間キャッシュする。
+// 参照頻度が高く更新頻度の低いデータの取得に利用する。
+//
+// txを指定した場合はトランザクション内の状態を反映させるため、キャッシュは利用しない。
+func WithCache(ttl time.Duration) Option {
+	return func(o *options) {
+		o.cacheTTL = ttl
+	}
+}
+
+// 指定したテーブルを参照しているキャッシュを削除する。
+// ssqlを経由せずにデータを更新した場合等に利用する。
+func InvalidateCache(tables ...string) {
+	if QueryCache == nil {
+		return
+	}
+	for _, t := range tables {
+		QueryCache.Invalidate(strings.ToLower(t))
+	}
+}
+
+// Exec等で書き込みを行った後に、対象のテーブルのキャッシュを削除する。
+//
+// トランザクション（TransactionまたはBegin）内の場合、コミットまでの間に並行するQueryが
+// コミット前の古い行を再びキャッシュする可能性があるため、トランザクションの終了時にも再度削除する。
+func invalidateCacheAfterWrite(tx Executor, query string) {
+	invalidateCacheByQuery(query)
+	if st := loadTxState(tx); st != nil {
+		st.recordWrite(query)
+	}
+}
+
+// 書き込みを行ったSQLが対象とするテーブルのキャッシュを削除する。
+// テーブルを特定できない場合はすべて削除する。
+func invalidateCacheByQuery(query string) {
+	if QueryCache == nil {
+		return
+	}
+	tables := tablesInQuery(query)
+	if len(tables) == 0 {
+		QueryCache.Clear()
+		return
+	}
+	for _, t := range tables {
+		QueryCache.Invalidate(t)
+	}
+}
+
+// クエリと引数、モデルの型からキャッシュのキーを生成する。
+// 空白や改行の違いは同じクエリとして扱う。
+func cacheKey(rt reflect.Type, query string, args []any) string {
+	texts := []string{}
+	for _, t := range tokenize(query) {
+		texts = append(texts, t.text)
+	}
+	normalized := make([]any, len(args))
+	for i, a := range args {
+		normalized[i] = normalizeCacheArg(a)
+	}
+	return fmt.Sprintf("%s|%s|%#v", rt, strings.Join(texts, " "), normalized)
+}
+
+// キャッシュのキーに利用するため、引数を値として正規化する。
+// ポインタはアドレスではなく参照先の値、driver.ValuerはValue()の結果とする。
+// time.TimeはモノトニッククロックとロケーションをUTCの文字列に揃える。
+func normalizeCacheArg(a any) any {
+	for {
+		if a == nil {
+			return nil
+		}
+		rv := reflect.ValueOf(a)
+		if rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return nil
+		}
+		switch v := a.(type) {
+		case time.Time:
+			return v.UTC().Format(time.RFC3339Nano)
+		case driver.Valuer:
+			dv, err := v.Value()
+			if err != nil {
+				return fmt.Sprintf("%#v", a)
+			}
+			// Value()が自身を返す場合に無限ループとならないようにする。
+			if reflect.TypeOf(dv) == rv.Type() {
+				return dv
+			}
+			a = dv
+			continue
+		}
+		if rv.Kind() == reflect.Pointer {
+			a = rv.Elem().Interface()
+			continue
+		}
+		return a
+	}
+}
+
+// FROM、JOIN、INTO、UPDATEの直後に記述されたテーブル名を返す。
+// スキーマ名は除き、小文字に変換する。
+// 簡易的な解析のため、カンマ区切りで列挙されたテーブルの2つ目以降等は検出できない。
+func tablesInQuery(query string) []string {
+	tokens := tokenize(query)
+	r := []string{}
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i].kind != tokenWord {
+			continue
+		}
+		switch strings.ToUpper(tokens[i].text) {
+		case "FROM", "JOIN", "INTO", "UPDATE":
+		default:
+			continue
+		}
+		name := ""
+		for j := i + 1; j < len(tokens); j++ {
+			t := tokens[j]
+			if t.kind == tokenWord && strings.ToUpper(t.text) == "ONLY" && name == "" {
+				continue
+			}
+			if t.kind != tokenWord && t.kind != tokenQuotedIdent {
+				break
+			}
+			name = strings.ToLower(strings.Trim(t.text, `"`))
+			// schema.table
+			if j+1 < len(tokens) && tokens[j+1].text == "." {
+				j++
+				continue
+			}
+			break
+		}
+		if name != "" && !slices.Contains(r, name) {
+			r = append(r, name)
+		}
+	}
+	return r
+}
+
+// メモリ上に保持するCacheStoreの実装
+type memoryCacheStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+	// テーブル名からそのテーブルを参照しているキーへの索引
+	tables map[string]map[string]struct{}
+}
+
+type memoryCacheEntry struct {
+	value     any
+	expiresAt time.Time
+}
+
+func NewMemoryCacheStore() CacheStore {
+	return &memoryCacheStore{
+		entries: map[string]memoryCacheEntry{},
+		tables:  map[string]map[string]struct{}{},
+	}
+}
+
+func (s *memoryCacheStore) Get(key string) (any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expiresAt) {
+		delete(s.entries, key)
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (s *memoryCacheStore) Set(key string, value any, tables []string, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = memoryCacheEntry{value: value, expiresAt: time.Now().Add(ttl)}
+	for _, t := range tables {
+		if s.tables[t] == nil {
+			s.tables[t] = map[string]struct{}{}
+		}
+		s.tables[t][key] = struct{}{}
+	}
+}
+
+func (s *memoryCacheStore) Invalidate(table string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.tables[table] {
+		delete(s.entries, key)
+	}
+	delete(s.tables, table)
+}
+
+func (s *memoryCacheStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = map[string]memoryCacheEntry{}
+	s.tables = map[string]map[string]struct{}{}
+}
@@ -0,0 +1,127 @@
+package ssql
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestTablesInQuery$ ./ssql
+func TestTablesInQuery(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{query: "SELECT * FROM users WHERE id = $1", expected: []string{"users"}},
+		{query: `SELECT * FROM public."Users" u JOIN posts p ON u.id = p.user_id WHERE u.id = $1`, expected: []string{"users", "posts"}},
+		{query: "SELECT * FROM (SELECT * FROM users WHERE id = $1) t", expected: []string{"users"}},
+		{query: "INSERT INTO users (name) VALUES ($1)", expected: []string{"users"}},
+		{query: "UPDATE ONLY users SET name = $1 WHERE id = $2", expected: []string{"users"}},
+		{query: "DELETE FROM users WHERE name = 'from posts'", expected: []string{"users"}},
+		{query: "SELECT 1", expected: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			testutil.AssertDeepEqual(t, tablesInQuery(tt.query), tt.expected)
+		})
+	}
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestMemoryCacheStore$ ./ssql
+func TestMemoryCacheStore(t *testing.T) {
+	s := NewMemoryCacheStore()
+
+	t.Run("get and invalidate", func(t *testing.T) {
+		s.Set("k1", []int{1}, []string{"users"}, time.Minute)
+		s.Set("k2", []int{2}, []string{"posts"}, time.Minute)
+		v, ok := s.Get("k1")
+		testutil.AssertEqual(t, ok, true)
+		testutil.AssertDeepEqual(t, v, []int{1})
+
+		s.Invalidate("users")
+		_, ok = s.Get("k1")
+		testutil.AssertEqual(t, ok, false)
+		_, ok = s.Get("k2")
+		testutil.AssertEqual(t, ok, true)
+
+		s.Clear()
+		_, ok = s.Get("k2")
+		testutil.AssertEqual(t, ok, false)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		s.Set("k1", []int{1}, nil, -time.Second)
+		_, ok := s.Get("k1")
+		testutil.AssertEqual(t, ok, false)
+	})
+
+	t.Run("key", func(t *testing.T) {
+		rt := reflect.TypeFor[TestStruct]()
+		k1 := cacheKey(rt, "SELECT * FROM users\n  WHERE id = $1", []any{1})
+		k2 := cacheKey(rt, "SELECT * FROM users WHERE id = $1", []any{1})
+		k3 := cacheKey(rt, "SELECT * FROM users WHERE id = $1", []any{2})
+		testutil.AssertEqual(t, k1, k2)
+		testutil.AssertNotEqual(t, k1, k3)
+	})
+
+	t.Run("key with pointer args", func(t *testing.T) {
+		rt := reflect.TypeFor[TestStruct]()
+		q := "SELECT * FROM users WHERE name = $1"
+		name := "a"
+		k1 := cacheKey(rt, q, []any{&name})
+		name = "b"
+		k2 := cacheKey(rt, q, []any{&name})
+		testutil.AssertNotEqual(t, k1, k2)
+
+		other := "b"
+		testutil.AssertEqual(t, cacheKey(rt, q, []any{&other}), k2)
+		testutil.AssertEqual(t, cacheKey(rt, q, []any{"b"}), k2)
+	})
+
+	t.Run("key with valuer and time args", func(t *testing.T) {
+		rt := reflect.TypeFor[TestStruct]()
+		q := "SELECT * FROM users WHERE created_at = $1"
+		now := time.Now()
+		testutil.AssertEqual(t, cacheKey(rt, q, []any{now}), cacheKey(rt, q, []any{now.Round(0).In(time.FixedZone("JST", 9*60*60))}))
+		testutil.AssertEqual(t, cacheKey(rt, q, []any{sql.NullString{String: "a", Valid: true}}), cacheKey(rt, q, []any{"a"}))
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestCacheInvalidateAfterTx$ ./ssql
+func TestCacheInvalidateAfterTx(t *testing.T) {
+	defer func(s CacheStore) { QueryCache = s }(QueryCache)
+	QueryCache = NewMemoryCacheStore()
+
+	t.Run("tables", func(t *testing.T) {
+		tx := &sql.Tx{}
+		registerTx(context.Background(), nil, tx, nil)
+		invalidateCacheAfterWrite(tx, "UPDATE users SET name = $1")
+
+		// コミット前に別のクエリがキャッシュした行
+		QueryCache.Set("k1", []int{1}, []string{"users"}, time.Minute)
+		QueryCache.Set("k2", []int{2}, []string{"posts"}, time.Minute)
+
+		unregisterTx(tx)
+		_, ok := QueryCache.Get("k1")
+		testutil.AssertEqual(t, ok, false)
+		_, ok = QueryCache.Get("k2")
+		testutil.AssertEqual(t, ok, true)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		tx := &sql.Tx{}
+		registerTx(context.Background(), nil, tx, nil)
+		// テーブルを特定できない書き込みがある場合は全て削除する。
+		invalidateCacheAfterWrite(tx, "SELECT do_something()")
+
+		QueryCache.Set("k2", []int{2}, []string{"posts"}, time.Minute)
+
+		unregisterTx(tx)
+		_, ok := QueryCache.Get("k2")
+		testutil.AssertEqual(t, ok, false)
+	})
+}
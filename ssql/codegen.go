@@ -0,0 +1,74 @@
+package ssql
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ssqlgen（tool/ssqlgen）で生成されるメソッド
+//
+// モデルがこれらを実装している場合は、スキャンや書き込みの際にタグの解析やリフレクションによる
+// フィールドへのアクセスを行わず、生成されたメソッドを利用する。
+// 呼び出し側のAPI（Query、Insert、Save等）は変わらない。
+//
+//	//go:generate go run github.com/megur0/simple-sql/tool/ssqlgen $GOFILE
+//
+//	//ssql:generate
+//	type User struct { ... }
+
+// 結果セットのカラムの順番で、Scanへ渡すフィールドへのポインタを返す。
+// モデルに存在しないカラムが含まれる場合はfalseを返し、通常の処理（ColumnMapping）に委ねる。
+type ColumnScanner interface {
+	ScanTargets(columns []string) ([]any, bool)
+}
+
+// 書き込むカラムと値を返す。
+type ColumnValuer interface {
+	// Insertで書き込むカラムと値（readonlyオプションのカラムを除く）
+	InsertColumnValues() ([]string, []any)
+	// Save等の構造体による更新で書き込むカラムと値（insert_only、readonlyオプションのカラムを除く）
+	UpdateColumnValues() ([]string, []any)
+}
+
+func asColumnValuer(s any) (ColumnValuer, bool) {
+	if t, ok := s.(tableOverride); ok {
+		s = t.model
+	}
+	cv, ok := s.(ColumnValuer)
+	return cv, ok
+}
+
+// excludesに含まれるカラムを除き、ダブルクォートで囲んだカラム名と値を返す。
+func excludeColumns(columns []string, values []any, excludes []string) ([]string, []any) {
+	fields := []string{}
+	r := []any{}
+	for i, c := range columns {
+		if slices.Contains(excludes, c) {
+			continue
+		}
+		fields = append(fields, `"`+c+`"`)
+		r = append(r, values[i])
+	}
+	return fields, r
+}
+
+// ColumnValuerを実装したモデルの一括挿入のSQLを生成する。
+func getGeneratedBulkInsertSQL[T any](items []T, ignores []string, opts ...Option) (string, []any) {
+	fields := []string{}
+	values := []any{}
+	valueGroups := []string{}
+	for _, item := range items {
+		cv, _ := asColumnValuer(item)
+		columns, v := cv.InsertColumnValues()
+		fields, v = excludeColumns(columns, v, ignores)
+		placeholders := []string{}
+		for _, value := range v {
+			values = append(values, value)
+			placeholders = append(placeholders, "$"+strconv.Itoa(len(values)))
+		}
+		valueGroups = append(valueGroups, "("+strings.Join(placeholders, ", ")+")")
+	}
+	query := "INSERT INTO " + modelTableName(ormTarget(items[0], opts)) + " (" + strings.Join(fields, ", ") + ") VALUES " + strings.Join(valueGroups, ", ")
+	return query, values
+}
@@ -0,0 +1,82 @@
+package ssql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+// ssqlgenで生成されるメソッドを実装したモデル
+type testGeneratedModel struct {
+	ID        int     `database:"id"`
+	Name      *string `database:"name"`
+	Age       int     `database:"age,insert_only"`
+	CreatedAt string  `database:"created_at"`
+	UpdatedAt string  `database:"updated_at"`
+}
+
+func (m *testGeneratedModel) ScanTargets(columns []string) ([]any, bool) {
+	targets := make([]any, len(columns))
+	for i, c := range columns {
+		switch c {
+		case "id":
+			targets[i] = &m.ID
+		case "name":
+			targets[i] = &m.Name
+		case "age":
+			targets[i] = &m.Age
+		case "created_at":
+			targets[i] = &m.CreatedAt
+		case "updated_at":
+			targets[i] = &m.UpdatedAt
+		default:
+			return nil, false
+		}
+	}
+	return targets, true
+}
+
+func (m testGeneratedModel) InsertColumnValues() ([]string, []any) {
+	return []string{"id", "name", "age", "created_at", "updated_at"}, []any{m.ID, m.Name, m.Age, &m.CreatedAt, &m.UpdatedAt}
+}
+
+func (m testGeneratedModel) UpdateColumnValues() ([]string, []any) {
+	return []string{"id", "name", "created_at", "updated_at"}, []any{m.ID, m.Name, &m.CreatedAt, &m.UpdatedAt}
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGeneratedModel$ ./ssql
+func TestGeneratedModel(t *testing.T) {
+	ignores := generatedColumns(reflect.TypeFor[testGeneratedModel]())
+	name := Ptr("a")
+
+	t.Run("insert", func(t *testing.T) {
+		sql, values := getInsertSQL(&testGeneratedModel{Name: name, Age: 1}, ignores)
+		testutil.AssertEqual(t, sql, `INSERT INTO test_generated_models ("name", "age") VALUES ($1, $2)`)
+		testutil.AssertDeepEqual(t, values, []any{name, 1})
+
+		sql, values = getBulkInsertSQL([]testGeneratedModel{{Name: name, Age: 1}, {Age: 2}}, ignores)
+		testutil.AssertEqual(t, sql, `INSERT INTO test_generated_models ("name", "age") VALUES ($1, $2), ($3, $4)`)
+		testutil.AssertDeepEqual(t, values, []any{name, 1, (*string)(nil), 2})
+	})
+
+	t.Run("save", func(t *testing.T) {
+		sql, values := getSaveUpdateSQL(&testGeneratedModel{ID: 3, Name: name, Age: 1}, ignores)
+		testutil.AssertEqual(t, sql, `UPDATE test_generated_models SET "name" = $1, "updated_at" = $2 WHERE "id" = $3 RETURNING *`)
+		testutil.AssertEqual(t, values[0], any(name))
+		testutil.AssertEqual(t, values[2], any(3))
+	})
+
+	t.Run("scan", func(t *testing.T) {
+		m := testGeneratedModel{}
+		targets := structScanTargets(reflect.ValueOf(&m).Elem(), []string{"age", "id"}, ColumnMappingDefault)
+		*(targets[0].(*int)) = 20
+		*(targets[1].(*int)) = 5
+		testutil.AssertEqual(t, m.Age, 20)
+		testutil.AssertEqual(t, m.ID, 5)
+
+		// モデルに存在しないカラムはColumnMappingに従う。
+		targets = structScanTargets(reflect.ValueOf(&m).Elem(), []string{"id", "unknown"}, ColumnMappingLenient)
+		testutil.AssertEqual(t, len(targets), 2)
+	})
+}
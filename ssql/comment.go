@@ -0,0 +1,63 @@
+package ssql
+
+import (
+	"context"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// SQLの末尾にsqlcommenter形式のコメントを付与する。
+// /*route='%2Fusers',traceparent='00-...'*/
+//
+// DB側のログやpg_stat_activityとアプリケーションのリクエストを突き合わせるために利用する。
+// コメントの内容はContextWithQueryCommentでコンテキストへ設定した値と、
+// QueryCommenterが返した値から生成される。いずれも無い場合はコメントを付与しない。
+//
+// コメントの値はリクエストごとに異なるため、プリペアドステートメントのキャッシュとは併用しないこと。
+var QueryCommenter func(c context.Context) map[string]string
+
+type queryCommentKey struct{}
+
+// SQLに付与するコメントの値をコンテキストへ設定する。
+func ContextWithQueryComment(c context.Context, key string, value string) context.Context {
+	tags := maps.Clone(queryCommentFromContext(c))
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	tags[key] = value
+	return context.WithValue(c, queryCommentKey{}, tags)
+}
+
+func queryCommentFromContext(c context.Context) map[string]string {
+	tags, _ := c.Value(queryCommentKey{}).(map[string]string)
+	return tags
+}
+
+// コンテキストの値からコメントを生成してSQLへ付与する。
+// 既にコメントを含むSQLは変更しない。
+func annotateQuery(c context.Context, query string) string {
+	tags := maps.Clone(queryCommentFromContext(c))
+	if QueryCommenter != nil {
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		maps.Copy(tags, QueryCommenter(c))
+	}
+	if len(tags) == 0 || strings.Contains(query, "/*") || strings.Contains(query, "--") {
+		return query
+	}
+
+	pairs := []string{}
+	for _, k := range slices.Sorted(maps.Keys(tags)) {
+		pairs = append(pairs, encodeQueryComment(k)+"='"+encodeQueryComment(tags[k])+"'")
+	}
+
+	trimmed := strings.TrimRight(query, " \t\r\n;")
+	return trimmed + " /*" + strings.Join(pairs, ",") + "*/" + query[len(trimmed):]
+}
+
+func encodeQueryComment(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
@@ -0,0 +1,39 @@
+package ssql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestAnnotateQuery$ ./ssql
+func TestAnnotateQuery(t *testing.T) {
+	query := "SELECT * FROM users WHERE id = $1"
+
+	t.Run("no comment", func(t *testing.T) {
+		testutil.AssertEqual(t, annotateQuery(context.Background(), query), query)
+	})
+
+	t.Run("context values", func(t *testing.T) {
+		c := ContextWithQueryComment(context.Background(), "route", "/users/{id}")
+		c = ContextWithQueryComment(c, "action", "it's")
+		testutil.AssertEqual(t, annotateQuery(c, query+";"), query+" /*action='it%27s',route='%2Fusers%2F%7Bid%7D'*/;")
+	})
+
+	t.Run("commenter", func(t *testing.T) {
+		org := QueryCommenter
+		defer func() { QueryCommenter = org }()
+		QueryCommenter = func(c context.Context) map[string]string {
+			return map[string]string{"traceparent": "00-abc-def-01"}
+		}
+		c := ContextWithQueryComment(context.Background(), "route", "users")
+		testutil.AssertEqual(t, annotateQuery(c, query), query+" /*route='users',traceparent='00-abc-def-01'*/")
+	})
+
+	t.Run("already commented", func(t *testing.T) {
+		c := ContextWithQueryComment(context.Background(), "route", "users")
+		q := "SELECT /* hint */ * FROM users WHERE id = $1"
+		testutil.AssertEqual(t, annotateQuery(c, q), q)
+	})
+}
@@ -0,0 +1,171 @@
+package ssql
+
+import (
+	"reflect"
+	"strings"
+)
+
+// WHERE句の条件を組み立てる。
+// ORMの各関数のwhereClausesはANDでのみ結合されるため、ORやグループ化が必要な場合に利用する。
+//
+// プレースホルダーは"?"で出力されるため、WhereCondの戻り値をそのままORMの各関数へ渡せる。
+//
+//	c := ssql.And(
+//		ssql.Eq("is_active", true),
+//		ssql.Or(ssql.In("role", []string{"admin", "owner"}), ssql.IsNull("deleted_at")),
+//	)
+//	whereClauses, whereValues := ssql.WhereCond(c)
+//	users, err := ssql.Find(tx, &User{}, whereClauses, whereValues)
+type Cond struct {
+	// 単一の条件の場合
+	clause string
+	values []any
+	// Rawで指定された条件はOR等を含む可能性があるため、結合時に括弧で囲む。
+	raw bool
+
+	// AND、OR、NOTの場合
+	op    string
+	conds []Cond
+}
+
+// 任意の条件を指定する。プレースホルダーは"?"とする。
+func Raw(clause string, values ...any) Cond {
+	return Cond{clause: clause, values: values, raw: true}
+}
+
+func Eq(column string, value any) Cond {
+	return Cond{clause: column + " = ?", values: []any{value}}
+}
+
+func NotEq(column string, value any) Cond {
+	return Cond{clause: column + " <> ?", values: []any{value}}
+}
+
+func Gt(column string, value any) Cond {
+	return Cond{clause: column + " > ?", values: []any{value}}
+}
+
+func Gte(column string, value any) Cond {
+	return Cond{clause: column + " >= ?", values: []any{value}}
+}
+
+func Lt(column string, value any) Cond {
+	return Cond{clause: column + " < ?", values: []any{value}}
+}
+
+func Lte(column string, value any) Cond {
+	return Cond{clause: column + " <= ?", values: []any{value}}
+}
+
+// valuesはスライスで指定し、要素ごとにプレースホルダーへ展開する。
+// 空の場合は常に偽となる。
+func In(column string, values any) Cond {
+	rv := reflect.ValueOf(values)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		panic("values of In must be slice")
+	}
+	if rv.Len() == 0 {
+		return Cond{clause: "1 = 0"}
+	}
+	placeholders := make([]string, rv.Len())
+	vs := make([]any, rv.Len())
+	for i := range rv.Len() {
+		placeholders[i] = "?"
+		vs[i] = rv.Index(i).Interface()
+	}
+	return Cond{clause: column + " IN (" + strings.Join(placeholders, ", ") + ")", values: vs}
+}
+
+func Between(column string, from any, to any) Cond {
+	return Cond{clause: column + " BETWEEN ? AND ?", values: []any{from, to}}
+}
+
+func IsNull(column string) Cond {
+	return Cond{clause: column + " IS NULL"}
+}
+
+func IsNotNull(column string) Cond {
+	return Cond{clause: column + " IS NOT NULL"}
+}
+
+// 条件が無い場合は常に真となる。
+func And(conds ...Cond) Cond {
+	return Cond{op: "AND", conds: conds}
+}
+
+// 条件が無い場合は常に偽となる。
+func Or(conds ...Cond) Cond {
+	return Cond{op: "OR", conds: conds}
+}
+
+func Not(c Cond) Cond {
+	return Cond{op: "NOT", conds: []Cond{c}}
+}
+
+// 条件をSQLと値に変換する。プレースホルダーは"?"となる。
+func (c Cond) Build() (string, []any) {
+	values := []any{}
+	sql := c.build(&values)
+	return sql, values
+}
+
+func (c Cond) build(values *[]any) string {
+	switch c.op {
+	case "":
+		*values = append(*values, c.values...)
+		return c.clause
+	case "NOT":
+		return "NOT (" + c.conds[0].build(values) + ")"
+	}
+
+	if len(c.conds) == 0 {
+		if c.op == "AND" {
+			return "1 = 1"
+		}
+		return "1 = 0"
+	}
+	if len(c.conds) == 1 {
+		return c.conds[0].build(values)
+	}
+	parts := make([]string, len(c.conds))
+	for i, child := range c.conds {
+		s := child.build(values)
+		if child.needsParen() {
+			s = "(" + s + ")"
+		}
+		parts[i] = s
+	}
+	return strings.Join(parts, " "+c.op+" ")
+}
+
+// 他の条件と結合する際に括弧で囲む必要があるかどうか
+func (c Cond) needsParen() bool {
+	switch c.op {
+	case "":
+		return c.raw
+	case "NOT":
+		return false
+	}
+	if len(c.conds) == 1 {
+		return c.conds[0].needsParen()
+	}
+	return len(c.conds) > 1
+}
+
+// ORMの各関数のwhereClauses、whereValuesとして渡せる形に変換する。
+// whereClausesの各要素はANDで結合されるため、ORの場合は括弧で囲む。
+func WhereCond(c Cond) ([]string, []any) {
+	sql, values := c.Build()
+	if c.needsParen() {
+		sql = "(" + sql + ")"
+	}
+	return []string{sql}, values
+}
+
+// プレースホルダーを$1, $2...の形式に変換する。
+// Query等のSQLに直接組み込む場合に利用する。
+// startIdxには既にSQL内で利用しているプレースホルダーの個数を指定する。
+func (c Cond) BuildNumbered(startIdx int) (string, []any) {
+	sql, values := c.Build()
+	return replacePlaceholders(sql, startIdx), values
+}
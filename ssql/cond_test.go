@@ -0,0 +1,68 @@
+package ssql
+
+import (
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestCond$ ./ssql
+func TestCond(t *testing.T) {
+	tests := []struct {
+		name           string
+		cond           Cond
+		expected       string
+		expectedValues []any
+	}{
+		{
+			name:           "and",
+			cond:           And(Eq("name", "a"), Gt("age", 20)),
+			expected:       "name = ? AND age > ?",
+			expectedValues: []any{"a", 20},
+		},
+		{
+			name:           "or in and",
+			cond:           And(Eq("is_active", true), Or(In("role", []string{"admin", "owner"}), IsNull("deleted_at"))),
+			expected:       "is_active = ? AND (role IN (?, ?) OR deleted_at IS NULL)",
+			expectedValues: []any{true, "admin", "owner"},
+		},
+		{
+			name:           "not and raw",
+			cond:           Or(Not(Between("age", 10, 20)), Raw("a = ? OR b = ?", 1, 2)),
+			expected:       "NOT (age BETWEEN ? AND ?) OR (a = ? OR b = ?)",
+			expectedValues: []any{10, 20, 1, 2},
+		},
+		{
+			name:           "single child",
+			cond:           And(Or(Eq("a", 1))),
+			expected:       "a = ?",
+			expectedValues: []any{1},
+		},
+		{
+			name:           "empty",
+			cond:           And(Or(), In("id", []int{}), IsNotNull("name")),
+			expected:       "1 = 0 AND 1 = 0 AND name IS NOT NULL",
+			expectedValues: []any{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, values := tt.cond.Build()
+			testutil.AssertEqual(t, sql, tt.expected)
+			testutil.AssertDeepEqual(t, values, tt.expectedValues)
+		})
+	}
+
+	t.Run("with orm", func(t *testing.T) {
+		whereClauses, whereValues := WhereCond(Or(Eq("name", "a"), Lt("age", 20)))
+		sql, values := getQuerySQL(TestStruct{}, append(whereClauses, "id <> ?"), append(whereValues, 1), nil, LimitOffset{})
+		testutil.AssertEqual(t, sql, "SELECT * FROM test_structs WHERE (name = $1 OR age < $2) AND id <> $3")
+		testutil.AssertDeepEqual(t, values, []any{"a", 20, 1})
+	})
+
+	t.Run("numbered", func(t *testing.T) {
+		sql, _ := And(Eq("name", "a"), NotEq("age", 20)).BuildNumbered(1)
+		testutil.AssertEqual(t, sql, "name = $2 AND age <> $3")
+	})
+}
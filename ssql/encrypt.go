@@ -0,0 +1,136 @@
+package ssql
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// encryptedオプションのカラムの暗号化と復号を行う。
+//
+// `database:"ssn,encrypted"`のように指定したフィールドは、Insert、Update等の書き込み時に暗号化され、
+// Query等の読み込み時に復号される。カラムの型はbyteaとすること。
+// フィールドの型はstring、[]byte、*stringのいずれかとする。
+//
+// 暗号化した値は毎回異なるため、暗号化したカラムをWHEREの条件やインデックスには利用できない。
+type Encryptor interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// encryptedオプションのカラムに利用するEncryptor
+// encryptedオプションのカラムを利用する場合は、初期化時に設定すること。
+var DefaultEncryptor Encryptor
+
+func encryptor() Encryptor {
+	if DefaultEncryptor == nil {
+		panic("DefaultEncryptor is not set")
+	}
+	return DefaultEncryptor
+}
+
+// AES-GCMで暗号化するEncryptorを返す。
+// keyは16、24、32バイトのいずれか（AES-128、AES-192、AES-256）とする。
+// 暗号文はランダムなnonceを先頭に付与したものとなる。
+func NewAESGCMEncryptor(key []byte) (Encryptor, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &aesGCMEncryptor{gcm: gcm}, nil
+}
+
+type aesGCMEncryptor struct {
+	gcm cipher.AEAD
+}
+
+func (e *aesGCMEncryptor) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, e.gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return e.gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (e *aesGCMEncryptor) Decrypt(ciphertext []byte) ([]byte, error) {
+	n := e.gcm.NonceSize()
+	if len(ciphertext) < n {
+		return nil, errors.New("ciphertext too short")
+	}
+	return e.gcm.Open(nil, ciphertext[:n], ciphertext[n:], nil)
+}
+
+// 書き込む値を暗号化する。nilの場合はNULLとして扱う。
+func encryptColumn(v any) any {
+	var b []byte
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case string:
+		b = []byte(t)
+	case *string:
+		if t == nil {
+			return nil
+		}
+		b = []byte(*t)
+	case []byte:
+		if t == nil {
+			return nil
+		}
+		b = t
+	default:
+		panic(fmt.Sprintf("encrypted option does not support type: %T", v))
+	}
+	c, err := encryptor().Encrypt(b)
+	if err != nil {
+		panic(fmt.Sprintf("encrypt failed: %s", err))
+	}
+	return c
+}
+
+// encryptedオプションのカラムの値を復号してフィールドへ格納するためのScanner
+type encryptedScanner struct {
+	dest reflect.Value
+}
+
+func (s *encryptedScanner) Scan(src any) error {
+	// 前の行の値が残らないように、一旦ゼロ値にしておく。
+	s.dest.Set(reflect.Zero(s.dest.Type()))
+	if src == nil {
+		return nil
+	}
+	var b []byte
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unsupported encrypted source type: %T", src)
+	}
+	plain, err := encryptor().Decrypt(b)
+	if err != nil {
+		return err
+	}
+	dest := s.dest
+	if dest.Kind() == reflect.Ptr {
+		dest.Set(reflect.New(dest.Type().Elem()))
+		dest = dest.Elem()
+	}
+	switch dest.Kind() {
+	case reflect.String:
+		dest.SetString(string(plain))
+	case reflect.Slice:
+		dest.SetBytes(plain)
+	default:
+		return fmt.Errorf("encrypted option does not support type: %s", s.dest.Type())
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package ssql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+type testEncryptedModel struct {
+	SSN   string  `database:"ssn,encrypted"`
+	Note  *string `database:"note,encrypted"`
+	Token []byte  `database:"token,encrypted"`
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestEncrypted$ ./ssql
+func TestEncrypted(t *testing.T) {
+	e, err := NewAESGCMEncryptor([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	org := DefaultEncryptor
+	defer func() { DefaultEncryptor = org }()
+	DefaultEncryptor = e
+
+	t.Run("round trip", func(t *testing.T) {
+		c1, _ := e.Encrypt([]byte("secret"))
+		c2, _ := e.Encrypt([]byte("secret"))
+		testutil.AssertEqual(t, reflect.DeepEqual(c1, c2), false)
+		p, err := e.Decrypt(c1)
+		testutil.AssertEqual(t, err == nil, true)
+		testutil.AssertEqual(t, string(p), "secret")
+
+		_, err = e.Decrypt([]byte("short"))
+		testutil.AssertEqual(t, err == nil, false)
+	})
+
+	t.Run("insert and scan", func(t *testing.T) {
+		_, values := getInsertSQL(&testEncryptedModel{SSN: "123-45-6789", Token: []byte{1, 2}}, nil)
+		testutil.AssertEqual(t, values[1] == nil, true)
+
+		m := testEncryptedModel{Note: Ptr("old")}
+		targets := structScanTargets(reflect.ValueOf(&m).Elem(), []string{"ssn", "note", "token"}, ColumnMappingDefault)
+		for i, v := range values {
+			if err := targets[i].(*encryptedScanner).Scan(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		testutil.AssertEqual(t, m.SSN, "123-45-6789")
+		testutil.AssertEqual(t, m.Note == nil, true)
+		testutil.AssertDeepEqual(t, m.Token, []byte{1, 2})
+
+		c := encryptColumn(Ptr("note"))
+		if err := targets[1].(*encryptedScanner).Scan(c); err != nil {
+			t.Fatal(err)
+		}
+		testutil.AssertEqual(t, *m.Note, "note")
+	})
+
+	t.Run("update", func(t *testing.T) {
+		_, values := getSetClauses(&testEncryptedModel{}, map[string]any{"ssn": "987"})
+		p, _ := e.Decrypt(values[0].([]byte))
+		testutil.AssertEqual(t, string(p), "987")
+	})
+
+	t.Run("not set", func(t *testing.T) {
+		DefaultEncryptor = nil
+		defer func() {
+			testutil.AssertEqual(t, recover(), any("DefaultEncryptor is not set"))
+		}()
+		encryptColumn("a")
+	})
+}
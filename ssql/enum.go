@@ -0,0 +1,91 @@
+package ssql
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Postgresのenum型のカラムに対応する型で実装する。
+// 取り得る値を返し、Insert、Update等の書き込み前に値が含まれるかを検証する。
+// 含まれない場合はSQLを実行せずに*EnumErrorを返す。
+//
+//	type Status string
+//
+//	const (
+//		StatusActive   Status = "active"
+//		StatusInactive Status = "inactive"
+//	)
+//
+//	func (Status) EnumValues() []any { return []any{StatusActive, StatusInactive} }
+//
+// intの定数をenumのラベルへ対応付ける場合は、driver.Valuerとsql.Scannerも実装する。
+// 書き込み前の検証を経由しない値（Execで直接指定した値等）の場合も、
+// データベースがenumの値として受け付けなかった場合はErrInvalidEnumを返す。
+type Enum interface {
+	EnumValues() []any
+}
+
+var enumType = reflect.TypeFor[Enum]()
+
+// Enumの取り得る値に含まれない値を書き込もうとした場合のエラー
+// errors.Is(err, ErrInvalidEnum)、errors.Is(err, ErrValidation)のいずれでも判定できる。
+type EnumError struct {
+	Column string
+	Value  any
+}
+
+func (e *EnumError) Error() string {
+	return fmt.Sprintf("%s: %v is not a valid value of %s", ErrInvalidEnum, e.Value, e.Column)
+}
+
+func (e *EnumError) Unwrap() []error {
+	return []error{ErrInvalidEnum, ErrValidation}
+}
+
+// フィールドの型がEnumを実装している場合に、値が取り得る値に含まれるかを検証する。
+// ゼロ値（ポインタの場合はnil）は検証しない。必須の場合はrequiredを指定すること。
+func validateEnum(column string, ft reflect.Type, v reflect.Value) error {
+	if ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	if !ft.Implements(enumType) {
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.IsZero() {
+		return nil
+	}
+	// Updateのマップでは"active"や int64(1) のように元の型で指定される場合があるため、型ではなく値で比較する。
+	for _, e := range reflect.Zero(ft).Interface().(Enum).EnumValues() {
+		if enumValueEqual(reflect.ValueOf(e), v) {
+			return nil
+		}
+	}
+	value := v.Interface()
+	if v.Kind() == ft.Kind() {
+		value = v.Convert(ft).Interface()
+	}
+	return &EnumError{Column: column, Value: value}
+}
+
+// 整数はint、int64、uint等の種類に関わらず値で比較する。
+func enumValueEqual(e reflect.Value, v reflect.Value) bool {
+	switch {
+	case e.CanInt() && v.CanInt():
+		return e.Int() == v.Int()
+	case e.CanUint() && v.CanUint():
+		return e.Uint() == v.Uint()
+	case e.CanInt() && v.CanUint():
+		return e.Int() >= 0 && uint64(e.Int()) == v.Uint()
+	case e.CanUint() && v.CanInt():
+		return v.Int() >= 0 && e.Uint() == uint64(v.Int())
+	case e.Kind() != v.Kind() || !v.Type().ConvertibleTo(e.Type()):
+		return false
+	}
+	return v.Convert(e.Type()).Interface() == e.Interface()
+}
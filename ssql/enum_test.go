@@ -0,0 +1,67 @@
+package ssql
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+type testEnumStatus string
+
+const (
+	testEnumStatusActive   testEnumStatus = "active"
+	testEnumStatusInactive testEnumStatus = "inactive"
+)
+
+func (testEnumStatus) EnumValues() []any {
+	return []any{testEnumStatusActive, testEnumStatusInactive}
+}
+
+type testEnumPriority int
+
+func (testEnumPriority) EnumValues() []any {
+	return []any{testEnumPriority(1), testEnumPriority(2)}
+}
+
+type testEnumModel struct {
+	Status   testEnumStatus    `database:"status"`
+	Priority *testEnumPriority `database:"priority"`
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestValidateEnum$ ./ssql
+func TestValidateEnum(t *testing.T) {
+	t.Run("struct", func(t *testing.T) {
+		testutil.AssertEqual(t, validateStruct(&testEnumModel{}) == nil, true)
+		testutil.AssertEqual(t, validateStruct(&testEnumModel{Status: testEnumStatusActive, Priority: Ptr(testEnumPriority(2))}) == nil, true)
+
+		err := validateStruct(&testEnumModel{Status: "deleted"})
+		testutil.AssertEqual(t, errors.Is(err, ErrInvalidEnum), true)
+		testutil.AssertEqual(t, errors.Is(err, ErrValidation), true)
+		var e *EnumError
+		testutil.AssertEqual(t, errors.As(err, &e), true)
+		testutil.AssertEqual(t, e.Column, "status")
+		testutil.AssertEqual(t, e.Value, any(testEnumStatus("deleted")))
+
+		err = validateStruct(&testEnumModel{Priority: Ptr(testEnumPriority(3))})
+		testutil.AssertEqual(t, errors.Is(err, ErrInvalidEnum), true)
+	})
+
+	t.Run("set maps", func(t *testing.T) {
+		testutil.AssertEqual(t, validateSetMaps(&testEnumModel{}, map[string]any{"status": "inactive", "priority": 1}) == nil, true)
+		testutil.AssertEqual(t, validateSetMaps(&testEnumModel{}, map[string]any{"status": Expr("'active'")}) == nil, true)
+
+		err := validateSetMaps(&testEnumModel{}, map[string]any{"status": "deleted"})
+		testutil.AssertEqual(t, errors.Is(err, ErrInvalidEnum), true)
+		err = validateSetMaps(&testEnumModel{}, map[string]any{"priority": "1"})
+		testutil.AssertEqual(t, errors.Is(err, ErrInvalidEnum), true)
+
+		// 整数は種類に関わらず値で比較する
+		testutil.AssertEqual(t, validateSetMaps(&testEnumModel{}, map[string]any{"priority": int64(1)}) == nil, true)
+		testutil.AssertEqual(t, validateSetMaps(&testEnumModel{}, map[string]any{"priority": uint8(2)}) == nil, true)
+		err = validateSetMaps(&testEnumModel{}, map[string]any{"priority": int64(3)})
+		testutil.AssertEqual(t, errors.Is(err, ErrInvalidEnum), true)
+		err = validateSetMaps(&testEnumModel{}, map[string]any{"priority": int64(-1)})
+		testutil.AssertEqual(t, errors.Is(err, ErrInvalidEnum), true)
+	})
+}
@@ -1,28 +1,101 @@
 package ssql
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+)
 
 var (
-	PanicPlaceHolderNumberNotMatch  = "the number of PlaceHolder must match the number of args"
-	PanicDeleteSQLMustUseWhere      = "delete sql must use where keyword"
-	PanicSelectSQLMustUseWhere      = "select sql must use where keyword"
-	PanicUpdateSQLMustUseWhere      = "update sql must use where keyword"
-	PanicUpdateSQLMustHaveUpdatedAt = "update sql must have updated_at field"
-	PanicLockingReadMustUseNowait   = "locking read must use nowait"
-	PanicCommitDespiteErrInTx       = "you have executed commit despite there is error in transaction"
-	PanicQueryNotContanSelect       = "select does not contain select"
-	PanicSQLIsSeqScan               = "sql executed by Seq Scan: %s"
+	PanicPlaceHolderNumberNotMatch      = "the number of PlaceHolder must match the number of args"
+	PanicDeleteSQLMustUseWhere          = "delete sql must use where keyword"
+	PanicSelectSQLMustUseWhere          = "select sql must use where keyword"
+	PanicUpdateSQLMustUseWhere          = "update sql must use where keyword"
+	PanicUpdateSQLMustHaveUpdatedAt     = "update sql must have updated_at field"
+	PanicLockingReadMustUseNowait       = "locking read must use nowait"
+	PanicCommitDespiteErrInTx           = "you have executed commit despite there is error in transaction"
+	PanicQueryNotContanSelect           = "select does not contain select"
+	PanicSQLIsSeqScan                   = "sql executed by Seq Scan: %s"
+	PanicPlanRuleViolation              = "plan violates rule %q (%s): %s"
+	PanicExecReturningMustHaveReturning = "exec returning must have returning clause"
+	PanicBatchRequiresPostgres          = "batch requires postgres dialect"
+	PanicBatchUnsupportedExecutor       = "batch does not support executor: %T"
+	PanicExplainRequiresPostgres        = "explain requires postgres dialect"
+	PanicInvalidIdentifier              = "invalid identifier: %s"
+	PanicDefaultRequiresNullableField   = "default option requires pointer or nullable field: %s"
+	PanicExecInReadOnlyTransaction      = "exec is not allowed in read-only transaction"
+	PanicOrderByWithoutLimit            = "select with order by must use limit"
+	PanicTooManyAffectedRows            = "update/delete would affect %d rows, exceeding MaxAffectedRows (%d): %s"
+	PanicDangerousStatementInProduction = "dangerous statement (TRUNCATE, DROP, ALTER, GRANT etc.) is not allowed in production mode"
 )
 
 var (
 	ErrLockNotAvailable = errors.New("lock not available")
 	ErrUniqConstraint   = errors.New("violate uniq constraint")
-	ErrDeadLock         = errors.New("dead lock")
+	// 外部キー制約違反（参照先が存在しない、参照されている行の削除等）
+	ErrForeignKeyConstraint = errors.New("violate foreign key constraint")
+	// NOT NULL制約違反
+	ErrNotNullViolation = errors.New("violate not null constraint")
+	// CHECK制約違反
+	ErrCheckConstraint = errors.New("violate check constraint")
+	ErrDeadLock        = errors.New("dead lock")
+	// REPEATABLE READ、SERIALIZABLEのトランザクションで競合が発生した場合
+	ErrSerializationFailure = errors.New("serialization failure")
+	// statement_timeout（TxOptions.StatementTimeout等）を超えて文の実行がキャンセルされた場合
+	ErrStatementTimeout = errors.New("statement timeout")
+	// コンテキストのキャンセルにより文の実行が中断された場合（リクエストの中断等）
+	// errors.Is(err, context.Canceled)も満たす。
+	ErrQueryCanceled = fmt.Errorf("query canceled: %w", context.Canceled)
+	// コンテキストのデッドライン（WithTimeout等）を超えて文の実行が中断された場合
+	// errors.Is(err, context.DeadlineExceeded)も満たす。
+	ErrQueryTimeout = fmt.Errorf("query timeout: %w", context.DeadlineExceeded)
+	// DBへ接続できない、または接続が切断された場合（接続拒否、サーバーの停止等）
+	// IsRetryableを満たす。ヘルスチェックやサーキットブレーカーでの判定に利用する。
+	ErrConnUnavailable = errors.New("connection unavailable")
+	// トランザクションの開始、コミット、ロールバックに失敗した場合（TryTransactionでのみ返される）
+	ErrBeginFailed    = errors.New("begin transaction failed")
+	ErrCommitFailed   = errors.New("commit failed")
+	ErrRollbackFailed = errors.New("rollback failed")
+	// プレースホルダーの引数の個数がMaxBindParametersを超えた場合（InsertBulkの件数が多すぎる場合等）
+	ErrTooManyParameters = errors.New("too many bind parameters")
+	// Query等で取得した行数がGuardConfig.MaxResultRowsを超えた場合
+	ErrTooManyRows = errors.New("too many rows")
+	// ExecExpectRowsで更新された行数が期待した行数と一致しない場合
+	ErrUnexpectedRowCount = errors.New("unexpected row count")
+	// OrderByで指定されたカラムや並び順が不正な場合
+	ErrInvalidOrderBy = errors.New("invalid order by")
+	// Columnで指定されたカラムがモデルに存在しない場合
+	ErrInvalidColumn = errors.New("invalid column")
+	// ページングの件数（FindKeysetのpageSize等）が不正な場合
+	ErrInvalidPagination = errors.New("invalid pagination")
+	// validateタグに違反した場合（詳細は*ValidationErrorで取得できる）
+	ErrValidation = errors.New("validation failed")
+	// enumの取り得る値に含まれない値を書き込もうとした場合（詳細は*EnumErrorで取得できる）
+	ErrInvalidEnum = errors.New("invalid enum value")
+	// カラムの最大長を超える値を書き込もうとした場合（varcharの長さ超過等）
+	// validateタグで検証されなかった場合にDBから返される。errors.Is(err, ErrValidation)も満たす。
+	ErrValueTooLong = fmt.Errorf("value too long: %w", ErrValidation)
+)
+
+var (
+	PostgresErrCodeLockNotAvailable          = "55P03"
+	PostgresErrCodeInvalidSyntax             = "22P02"
+	PostgresErrCodeUniqConstraint            = "23505"
+	PostgresErrCodeForeignKeyConstraint      = "23503"
+	PostgresErrCodeNotNullViolation          = "23502"
+	PostgresErrCodeCheckConstraint           = "23514"
+	PostgresErrCodeDeadLock                  = "40P01"
+	PostgresErrCodeSerializationFailure      = "40001"
+	PostgresErrCodeStringDataRightTruncation = "22001"
+	PostgresErrCodeQueryCanceled             = "57014"
+	PostgresErrCodeAdminShutdown             = "57P01"
+	PostgresErrCodeCrashShutdown             = "57P02"
+	PostgresErrCodeCannotConnectNow          = "57P03"
+	// クラス08（connection_exception）の接頭辞
+	PostgresErrClassConnectionException = "08"
 )
 
 var (
-	PostgresErrCodeLockNotAvailable = "55P03"
-	PostgresErrCodeInvalidSyntax    = "22P02"
-	PostgresErrCodeUniqConstraint   = "23505"
-	PostgresErrCodeDeadLock         = "40P01"
+	SQLiteErrMessageBusy           = "database is locked"
+	SQLiteErrMessageUniqConstraint = "UNIQUE constraint failed"
 )
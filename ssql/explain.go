@@ -0,0 +1,216 @@
+package ssql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// EXPLAIN (FORMAT json)の実行計画の各ノード
+// 主な項目のみ構造体へ格納する。それ以外の項目はExplainResult.Rawから取得する。
+//
+// [参考]
+// https://www.postgresql.jp/docs/14/using-explain.html
+type PlanNode struct {
+	// "Seq Scan"、"Index Scan"、"Bitmap Heap Scan"、"Sort"、"Limit"等
+	NodeType     string `json:"Node Type"`
+	RelationName string `json:"Relation Name"`
+	Alias        string `json:"Alias"`
+	IndexName    string `json:"Index Name"`
+	// 推定コスト（最初の行を返すまで、全ての行を返すまで）
+	StartupCost float64 `json:"Startup Cost"`
+	TotalCost   float64 `json:"Total Cost"`
+	// 推定行数
+	PlanRows  float64 `json:"Plan Rows"`
+	PlanWidth int     `json:"Plan Width"`
+	Filter    string  `json:"Filter"`
+	IndexCond string  `json:"Index Cond"`
+	// Sortのキー、方式（"external merge"等。ANALYZEを指定した場合のみ）
+	SortKey    []string `json:"Sort Key"`
+	SortMethod string   `json:"Sort Method"`
+	// ANALYZEを指定した場合の実測値（時間はミリ秒、行数はループ1回あたり）
+	ActualStartupTime float64 `json:"Actual Startup Time"`
+	ActualTotalTime   float64 `json:"Actual Total Time"`
+	ActualRows        float64 `json:"Actual Rows"`
+	ActualLoops       float64 `json:"Actual Loops"`
+	// BUFFERSを指定した場合の共有バッファのヒット数、ディスクからの読み込み数（ブロック）
+	SharedHitBlocks  int64 `json:"Shared Hit Blocks"`
+	SharedReadBlocks int64 `json:"Shared Read Blocks"`
+	// 子のノード
+	Plans []PlanNode `json:"Plans"`
+}
+
+// 自身を含む全てのノードを深さ優先の順で返す。
+func (n *PlanNode) Nodes() []*PlanNode {
+	r := []*PlanNode{n}
+	for i := range n.Plans {
+		r = append(r, n.Plans[i].Nodes()...)
+	}
+	return r
+}
+
+// 自身を含むいずれかのノードのNodeTypeに指定した種類を含むかどうか（大文字小文字は区別しない）
+// "Seq Scan"を指定した場合は"Parallel Seq Scan"も含む。
+func (n *PlanNode) HasNodeType(nodeType string) bool {
+	for _, node := range n.Nodes() {
+		if StrContainWithIgnoreCase(node.NodeType, nodeType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Explainの結果
+type ExplainResult struct {
+	// 最上位のノード
+	Plan PlanNode `json:"Plan"`
+	// ANALYZEを指定した場合の計画、実行の時間（ミリ秒）
+	PlanningTime  float64 `json:"Planning Time"`
+	ExecutionTime float64 `json:"Execution Time"`
+	// EXPLAINが返したJSON
+	Raw string `json:"-"`
+}
+
+// クエリの実行計画（EXPLAIN (FORMAT json)）を返す。PostgreSQLのみ対応している。
+// インデックスが使われているか、推定行数が想定内か等をアプリケーションやテストから確認する場合に利用する。
+//
+//	r, err := ssql.Explain(nil, "SELECT * FROM users WHERE email = $1", email)
+//	if r.Plan.HasNodeType("Seq Scan") { ... }
+//
+// ANALYZEは指定しないため、クエリは実行されない。（UPDATE、DELETE等も指定できる）
+// プレースホルダーがある場合は、実行計画を決定するために値をargsへ指定する。
+func Explain(tx Executor, query string, args ...any) (*ExplainResult, error) {
+	if !IsPostgres() {
+		panic(PanicExplainRequiresPostgres)
+	}
+	args, opt := splitArgs(args)
+	tx = contextTx(tx, opt)
+	if err := beforeTxStatement(tx); err != nil {
+		return nil, err
+	}
+	query, args = inlineSQLValues(query, args)
+	ctx, cancel := opt.context()
+	defer cancel()
+
+	if err := checkParameterCount(args); err != nil {
+		return nil, err
+	}
+	if err := guard(ctx, opt.guard, func() { checkPlaceholders(query, args) }); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	raw, err := explainJSON(ctx, getExecutor(tx), query, args, false)
+	if err != nil {
+		if e := queryError(err, query, args, start); e != nil {
+			return nil, e
+		}
+		panic(fmt.Sprintf("query failed: %s, failed query: %s", err, query))
+	}
+	r, err := parseExplainJSON(raw)
+	if err != nil {
+		panic(err)
+	}
+	return r, nil
+}
+
+// WithAnalyzeが指定された場合に、EXPLAIN (ANALYZE, BUFFERS)の結果をLoggerへ出力する。
+// 調査のための出力のため、失敗した場合も警告の出力のみとする。
+func logExplainAnalyzeOnDebug(ctx context.Context, tx Executor, query string, args []any, opt *options) {
+	if !opt.analyze || !IsDebugMode() || !IsPostgres() {
+		return
+	}
+	// ANALYZEはクエリを実際に実行するため、データを変更するCTE（WITH d AS (DELETE ...) SELECT ...）を含む場合は実行しない。
+	s := analyzeStatement(query)
+	if s.kind != "SELECT" || slices.ContainsFunc([]string{"INSERT", "UPDATE", "DELETE", "MERGE"}, func(kw string) bool {
+		return len(s.statementStarts(kw)) > 0
+	}) {
+		l.Warn(ctx, "explain analyze skipped, only select is supported: "+truncateQuery(query))
+		return
+	}
+	var raw string
+	var err error
+	if t := transactionOf(tx); t != nil {
+		// 失敗した場合に呼び出し元のトランザクションを中断させないように、セーブポイント内で実行する。
+		raw, err = explainInSavepoint(ctx, t, query, args, true)
+	} else {
+		raw, err = explainJSON(ctx, tx, query, args, true)
+	}
+	if err != nil {
+		l.Warn(ctx, fmt.Sprintf("explain analyze failed: %s, query: %s", err, truncateQuery(query)))
+		return
+	}
+	r, err := parseExplainJSON(raw)
+	if err != nil {
+		l.Warn(ctx, fmt.Sprintf("explain analyze failed: %s, query: %s", err, truncateQuery(query)))
+		return
+	}
+	l.Info(ctx, formatExplainAnalyze(r, query))
+}
+
+// EXPLAIN ANALYZEのテキスト形式に近い形で、各ノードの実測値を1行ずつ出力する。
+func formatExplainAnalyze(r *ExplainResult, query string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "explain analyze (planning: %.3f ms, execution: %.3f ms): %s", r.PlanningTime, r.ExecutionTime, truncateQuery(query))
+	var write func(n *PlanNode, depth int)
+	write = func(n *PlanNode, depth int) {
+		b.WriteString("\n" + strings.Repeat("  ", depth) + "-> " + n.NodeType)
+		if n.IndexName != "" {
+			b.WriteString(" using " + n.IndexName)
+		}
+		if n.RelationName != "" {
+			b.WriteString(" on " + n.RelationName)
+		}
+		fmt.Fprintf(&b, " (actual time=%.3f..%.3f rows=%.0f loops=%.0f, estimated rows=%.0f, shared hit=%d read=%d)",
+			n.ActualStartupTime, n.ActualTotalTime, n.ActualRows, n.ActualLoops, n.PlanRows, n.SharedHitBlocks, n.SharedReadBlocks)
+		for i := range n.Plans {
+			write(&n.Plans[i], depth+1)
+		}
+	}
+	write(&r.Plan, 0)
+	return b.String()
+}
+
+// EXPLAIN (FORMAT json)を実行し、結果のJSONを返す。
+// analyzeの場合はANALYZE、BUFFERSを指定する。（クエリが実際に実行される）
+func explainJSON(ctx context.Context, tx Executor, query string, args []any, analyze bool) (string, error) {
+	explain := "EXPLAIN (ANALYZE false, FORMAT json) "
+	if analyze {
+		explain = "EXPLAIN (ANALYZE true, BUFFERS true, FORMAT json) "
+	}
+	rows, err := tx.QueryContext(ctx, explain+strings.TrimRight(query, " \t\r\n;"), args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	r := []string{}
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return "", err
+		}
+		r = append(r, s)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if len(r) != 1 {
+		return "", fmt.Errorf("explain result is not 1 row")
+	}
+	return r[0], nil
+}
+
+func parseExplainJSON(raw string) (*ExplainResult, error) {
+	r := []ExplainResult{}
+	if err := json.Unmarshal([]byte(raw), &r); err != nil {
+		return nil, err
+	}
+	if len(r) != 1 {
+		return nil, fmt.Errorf("explain result json is not 1 child")
+	}
+	r[0].Raw = raw
+	return &r[0], nil
+}
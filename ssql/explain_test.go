@@ -0,0 +1,120 @@
+package ssql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestParseExplainJSON$ ./ssql
+func TestParseExplainJSON(t *testing.T) {
+	raw := `[{"Plan": {"Node Type": "Limit", "Total Cost": 8.3, "Plan Rows": 1, "Plans": [
+		{"Node Type": "Sort", "Plans": [
+			{"Node Type": "Index Scan", "Relation Name": "users", "Index Name": "users_pkey", "Index Cond": "(id = 1)", "Plan Rows": 1}
+		]},
+		{"Node Type": "Parallel Seq Scan", "Relation Name": "teams", "Filter": "(name = 'a'::text)", "Plan Rows": 120.5}
+	]}}]`
+	r, err := parseExplainJSON(raw)
+	testutil.AssertEqual(t, err, nil)
+	testutil.AssertEqual(t, r.Raw, raw)
+	plan := &r.Plan
+	testutil.AssertEqual(t, plan.NodeType, "Limit")
+	testutil.AssertEqual(t, plan.TotalCost, 8.3)
+
+	nodes := plan.Nodes()
+	testutil.AssertEqual(t, len(nodes), 4)
+	testutil.AssertEqual(t, nodes[2].IndexName, "users_pkey")
+	testutil.AssertEqual(t, nodes[2].IndexCond, "(id = 1)")
+	testutil.AssertEqual(t, nodes[3].RelationName, "teams")
+	testutil.AssertEqual(t, nodes[3].PlanRows, 120.5)
+
+	testutil.AssertTrue(t, plan.HasNodeType("index scan"))
+	testutil.AssertTrue(t, plan.HasNodeType("Seq Scan"))
+	testutil.AssertFalse(t, plan.HasNodeType("Bitmap Heap Scan"))
+
+	_, err = parseExplainJSON(`[]`)
+	testutil.AssertTrue(t, err != nil)
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestExplain$ ./ssql
+func TestExplain(t *testing.T) {
+	refreshDB()
+
+	t.Run("success", func(t *testing.T) {
+		r, err := Explain(nil, "SELECT * FROM table_for_tests WHERE id = $1", "69e00805-dbc9-4a12-b43f-65b0fd6c5023")
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertTrue(t, r.Plan.NodeType != "")
+		testutil.AssertContainStr(t, r.Raw, "Node Type")
+	})
+
+	t.Run("not_executed", func(t *testing.T) {
+		_, err := Explain(nil, "DELETE FROM table_for_tests WHERE name = $1", "aaaa")
+		testutil.AssertEqual(t, err, nil)
+	})
+
+	t.Run("with_analyze", func(t *testing.T) {
+		_, err := Query(nil, &TableForTest{}, "SELECT * FROM table_for_tests WHERE id = $1", "69e00805-dbc9-4a12-b43f-65b0fd6c5023", WithAnalyze())
+		testutil.AssertEqual(t, err, nil)
+	})
+
+	t.Run("with_analyze_in_tx", func(t *testing.T) {
+		// セーブポイント内で実行するため、呼び出し元のトランザクションを継続できる。
+		Transaction(context.Background(), func(tx *sql.Tx) error {
+			_, err := Query(tx, &TableForTest{}, "SELECT * FROM table_for_tests WHERE id = $1", "69e00805-dbc9-4a12-b43f-65b0fd6c5023", WithAnalyze())
+			testutil.AssertEqual(t, err, nil)
+			_, err = Query(tx, &TableForTest{}, "SELECT * FROM table_for_tests WHERE id = $1", "69e00805-dbc9-4a12-b43f-65b0fd6c5023")
+			testutil.AssertEqual(t, err, nil)
+			return nil
+		})
+	})
+
+	t.Run("fail_placeholder", func(t *testing.T) {
+		org := GuardViolationAsError
+		defer func() { GuardViolationAsError = org }()
+		GuardViolationAsError = true
+		_, err := Explain(nil, "SELECT * FROM table_for_tests WHERE id = $1")
+		testutil.AssertTrue(t, errors.Is(err, ErrGuardViolation))
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestFormatExplainAnalyze$ ./ssql
+func TestFormatExplainAnalyze(t *testing.T) {
+	raw := `[{"Plan": {"Node Type": "Limit", "Plan Rows": 10, "Actual Startup Time": 0.01, "Actual Total Time": 0.5, "Actual Rows": 10, "Actual Loops": 1, "Shared Hit Blocks": 3, "Plans": [
+		{"Node Type": "Index Scan", "Relation Name": "users", "Index Name": "users_team_id_idx", "Plan Rows": 12, "Actual Startup Time": 0.008, "Actual Total Time": 0.4, "Actual Rows": 10, "Actual Loops": 1, "Shared Hit Blocks": 2, "Shared Read Blocks": 1}
+	]}, "Planning Time": 0.123, "Execution Time": 0.6}]`
+	r, err := parseExplainJSON(raw)
+	testutil.AssertEqual(t, err, nil)
+	testutil.AssertEqual(t, r.ExecutionTime, 0.6)
+	testutil.AssertEqual(t, formatExplainAnalyze(r, "SELECT * FROM users WHERE team_id = $1 LIMIT 10"),
+		"explain analyze (planning: 0.123 ms, execution: 0.600 ms): SELECT * FROM users WHERE team_id = $1 LIMIT 10\n"+
+			"-> Limit (actual time=0.010..0.500 rows=10 loops=1, estimated rows=10, shared hit=3 read=0)\n"+
+			"  -> Index Scan using users_team_id_idx on users (actual time=0.008..0.400 rows=10 loops=1, estimated rows=12, shared hit=2 read=1)")
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestWithAnalyze$ ./ssql
+func TestWithAnalyze(t *testing.T) {
+	orgMode, orgDialect, orgLogger := Mode, Dialect, l
+	defer func() { Mode, Dialect = orgMode, orgDialect }()
+	defer SetLogger(orgLogger)
+	Mode, Dialect = MODE_DEBUG, DIALECT_POSTGRES
+
+	t.Run("skip_data_modifying_cte", func(t *testing.T) {
+		rl := &recordLogger{}
+		SetLogger(rl)
+		query := "WITH d AS (DELETE FROM users WHERE id = $1 RETURNING id) SELECT * FROM d WHERE id = $1"
+		logExplainAnalyzeOnDebug(context.Background(), nil, query, []any{1}, &options{analyze: true})
+		testutil.AssertEqual(t, len(rl.warnings()), 1)
+		testutil.AssertContainStr(t, rl.warnings()[0], "explain analyze skipped")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		rl := &recordLogger{}
+		SetLogger(rl)
+		// WithAnalyzeが指定されていない場合はDBへアクセスしない。
+		logExplainAnalyzeOnDebug(context.Background(), nil, "SELECT * FROM users WHERE id = $1", []any{1}, &options{})
+		testutil.AssertEqual(t, len(rl.warnings()), 0)
+	})
+}
@@ -0,0 +1,139 @@
+package ssql
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// クエリの結果セットを、構造体を経由せずにCSVとしてwへ書き込む。
+// 1行目はカラム名のヘッダーとなる。
+// 結果を保持せずに1行ずつ書き込むため、大量の行を管理画面からダウンロードさせる場合等に利用する。
+//
+// NULLは空文字、時刻はRFC3339形式、それ以外はfmt.Sprintの形式で出力する。
+// wへの書き込みに失敗した場合はその時点で中断し、そのerrorを返す。
+func ExportCSV(tx Executor, w io.Writer, query string, args ...any) error {
+	cw := csv.NewWriter(w)
+	var record []string
+	err := queryEachValues(tx, query, args, func(columns []string) error {
+		record = make([]string, len(columns))
+		return cw.Write(columns)
+	}, func(values []any) error {
+		for i, v := range values {
+			record[i] = csvValue(v)
+		}
+		return cw.Write(record)
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvValue(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(t)
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+// クエリの結果セットを、構造体を経由せずにJSONの配列としてwへ書き込む。
+// 各行はカラム名をキーとしたオブジェクトとなり、キーは結果セットのカラムの順番となる。
+// 値はjson.Marshalの形式で出力する。（[]byteはBase64となる）
+//
+// ExportCSVと同様に1行ずつ書き込む。
+func ExportJSON(tx Executor, w io.Writer, query string, args ...any) error {
+	bw := bufio.NewWriter(w)
+	var keys [][]byte
+	first := true
+	err := queryEachValues(tx, query, args, func(columns []string) error {
+		for _, c := range columns {
+			k, err := json.Marshal(c)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, k)
+		}
+		_, err := bw.WriteString("[")
+		return err
+	}, func(values []any) error {
+		if !first {
+			bw.WriteString(",")
+		}
+		first = false
+		bw.WriteString("{")
+		for i, v := range values {
+			if i > 0 {
+				bw.WriteString(",")
+			}
+			b, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			bw.Write(keys[i])
+			bw.WriteString(":")
+			bw.Write(b)
+		}
+		// bufio.Writerは書き込みに失敗した後はerrorを返し続けるため、行の最後でまとめてチェックする。
+		_, err := bw.WriteString("}")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := bw.WriteString("]"); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// 結果セットのカラム名をheaderへ渡し、各行の値を1行ずつfnへ渡す。
+// fnに渡すスライスは行ごとに再利用される。
+func queryEachValues(tx Executor, query string, args []any, header func([]string) error, fn func([]any) error) error {
+	args, opt := splitArgs(args)
+	query, args = inlineSQLValues(query, args)
+	ctx, cancel := opt.context()
+	defer cancel()
+	rows, err := queryRows(ctx, tx, query, args, opt)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		panic(err)
+	}
+	if err := header(columns); err != nil {
+		return err
+	}
+	values := make([]any, len(columns))
+	valuePtrs := make([]any, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(valuePtrs...); err != nil {
+			panic(err)
+		}
+		if err := fn(values); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		panic(err)
+	}
+
+	checkSeqScanOnDebug(tx, query, args, opt)
+
+	return nil
+}
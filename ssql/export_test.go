@@ -0,0 +1,17 @@
+package ssql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestCSVValue$ ./ssql
+func TestCSVValue(t *testing.T) {
+	testutil.AssertEqual(t, csvValue(nil), "")
+	testutil.AssertEqual(t, csvValue([]byte("abc")), "abc")
+	testutil.AssertEqual(t, csvValue(int64(10)), "10")
+	testutil.AssertEqual(t, csvValue(true), "true")
+	testutil.AssertEqual(t, csvValue(time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)), "2024-06-01T09:00:00Z")
+}
@@ -0,0 +1,94 @@
+package ssql
+
+import (
+	"strconv"
+	"strings"
+)
+
+// バインドパラメーターではなく、SQLとしてそのまま埋め込む式
+//
+// Updateのset、whereの値、Query、Exec等の引数として利用でき、
+// プレースホルダーの位置に"(式)"として展開される。
+// 式の中でも"?"で値を渡すことができ、呼び出し側のクエリに合わせて採番し直される。
+//
+//	ssql.Update(tx, &User{}, []string{"id = ?"}, []any{id}, map[string]any{"login_count": ssql.Expr("login_count + ?", 1)})
+//
+// 式はSQLとして実行されるため、外部からの入力を含めないこと。
+type SQLExpr struct {
+	sql    string
+	values []any
+}
+
+func Expr(sql string, values ...any) SQLExpr {
+	sql, values = inlineSQLValues(replacePlaceholders(sql, 0), values)
+	return SQLExpr{sql: sql, values: values}
+}
+
+func (e SQLExpr) inline() (string, []any) {
+	return "(" + e.sql + ")", e.values
+}
+
+// プレースホルダーの位置にSQLとして展開される値
+type inlineValue interface {
+	inline() (string, []any)
+}
+
+// argsに含まれるExprやSubqueryValueをクエリへ展開し、プレースホルダーを採番し直す。
+// 含まれない場合はそのまま返す。
+func inlineSQLValues(query string, args []any) (string, []any) {
+	found := false
+	for _, a := range args {
+		if _, ok := a.(inlineValue); ok {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return query, args
+	}
+
+	// 元の番号から置き換え後の文字列への対応
+	replacements := make(map[int]string, len(args))
+	values := make([]any, 0, len(args))
+	for i, a := range args {
+		if v, ok := a.(inlineValue); ok {
+			sql, vs := v.inline()
+			offset := len(values)
+			replacements[i+1] = renumberPlaceholders(sql, func(n int) string {
+				return "$" + strconv.Itoa(n+offset)
+			})
+			values = append(values, vs...)
+			continue
+		}
+		values = append(values, a)
+		replacements[i+1] = "$" + strconv.Itoa(len(values))
+	}
+	return renumberPlaceholders(query, func(n int) string {
+		if r, ok := replacements[n]; ok {
+			return r
+		}
+		// 範囲外の番号はそのまま残し、checkPlaceholdersで検出させる。
+		return "$" + strconv.Itoa(n)
+	}), values
+}
+
+// SQL内の各プレースホルダーをfnの戻り値で置き換える。
+// 文字列リテラルやコメント内の"$"は置き換えない。
+func renumberPlaceholders(query string, fn func(n int) string) string {
+	var b strings.Builder
+	last := 0
+	for _, t := range tokenize(query) {
+		if t.kind != tokenPlaceholder {
+			continue
+		}
+		n, err := strconv.Atoi(t.text[1:])
+		if err != nil {
+			panic(err)
+		}
+		b.WriteString(query[last:t.pos])
+		b.WriteString(fn(n))
+		last = t.pos + len(t.text)
+	}
+	b.WriteString(query[last:])
+	return b.String()
+}
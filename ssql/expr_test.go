@@ -0,0 +1,28 @@
+package ssql
+
+import (
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestExpr$ ./ssql
+func TestExpr(t *testing.T) {
+	t.Run("update set", func(t *testing.T) {
+		setClauses, setValues := getSetClauses(&TestStruct{}, map[string]any{"age": Expr("age + ?", 1), "name": "a"})
+		sql, values := getUpdateSQL(&TestStruct{}, []string{"id = ?"}, []any{10}, setClauses, setValues)
+		sql, values = inlineSQLValues(sql, values)
+		testutil.AssertEqual(t, sql, `UPDATE test_structs SET age = (age + $1), name = $2, "updated_at" = $3 WHERE id = $4`)
+		testutil.AssertEqual(t, len(values), 4)
+		testutil.AssertEqual(t, values[0], any(1))
+		testutil.AssertEqual(t, values[1], any("a"))
+		testutil.AssertEqual(t, values[3], any(10))
+	})
+
+	t.Run("where", func(t *testing.T) {
+		sql, values := getQuerySQL(TestStruct{}, []string{"created_at < ?", "name = ?"}, []any{Expr("now() - interval '1 day'"), "a"}, nil, LimitOffset{})
+		sql, values = inlineSQLValues(sql, values)
+		testutil.AssertEqual(t, sql, "SELECT * FROM test_structs WHERE created_at < (now() - interval '1 day') AND name = $1")
+		testutil.AssertDeepEqual(t, values, []any{"a"})
+	})
+}
@@ -0,0 +1,265 @@
+package ssql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"strings"
+)
+
+// SQLに対する各種チェック（ガード）の設定
+type GuardConfig struct {
+	// デバッグモードの際にSQLのExplainをチェックして"Seq Scan"を含む場合にpanicとさせる。
+	// これを利用することでインデックスの設定漏れを回避できる。
+	UseSeqScanCheck bool
+	// UseSeqScanCheckの際に、推定行数（Plan Rows）がこの値以下のSeq Scanは許容する。（0の場合は制限しない）
+	// 件数の少ないマスタテーブル等で、SeqScanCheckDisableClauseを付与せずに済むようにする。
+	// 推定行数はFilterによる絞り込み後の行数となるため、大きなテーブルの絞り込みも許容される点に注意すること。
+	SeqScanRowsThreshold float64
+	// UseSeqScanCheckの際に、推定の総コスト（Total Cost）がこの値以下のSeq Scanは許容する。（0の場合は制限しない）
+	// SeqScanRowsThresholdと両方を指定した場合は、いずれかを超えた場合にpanicとなる。
+	SeqScanCostThreshold float64
+	// trueの場合、Seq Scanのチェックを実行後ではなく実行前に行い、違反した場合は実行しない。
+	// 実行後のチェックでは、デバッグ環境でも重い全件検索や意図しない更新が実際に行われてしまうため、それを防ぐ場合に利用する。
+	// 実行前のチェックのため、ViolationAsErrorの場合はpanicではなくErrGuardViolationのerrorを返す。
+	SeqScanCheckBeforeExec bool
+	// デバッグモードで、Seq Scanのチェックと同時に実行計画の各ノードをチェックするルール
+	// 該当するノードがある場合はpanicとする。UseSeqScanCheckがfalseの場合もチェックする。
+	//
+	//	cfg.PlanRules = []ssql.PlanRule{ssql.NestedLoopRowsRule(100000), ssql.ParallelSeqScanRule()}
+	PlanRules []PlanRule
+	// WHEREが含まれない検索、更新、削除をpanicとさせる。
+	// これによってデータの全検索や全件の更新を回避する。
+	UseWhereCheck bool
+	// FOR UPDATEやFOR SHARE等の行ロック句の際はNOWAITが付与されている事を強制する
+	ForceNowaitOnLockingRead bool
+	// UPDATE文の際は"updated_at"（UpdatedAtColumn）が含まれている事を強制する
+	// ORMの更新はモデルの設定に従って更新日時をセットするため、チェックの対象外となる。
+	ForceUpdatedAtCheck bool
+	// 本番モードでも実行を許可する文の種類（DangerousStatementsのうち"ALTER"等）
+	// マイグレーションをアプリケーションから実行する場合等に指定する。
+	AllowedStatementsInProduction []string
+	// Query、QueryColumn、QueryMaps等で取得する行数の上限（0の場合は制限しない）
+	// WHEREのチェックをすり抜けた、絞り込みの不十分な検索（WHERE is_active = true等）を検出する。
+	// 超えた場合はその時点で読み込みを中断し、ErrTooManyRowsを返す。
+	// 大量の行を順次処理するQueryEachは対象外となる。
+	MaxResultRows int
+	// trueの場合、MaxResultRowsを超えてもerrorとせずにLoggerで警告を出力する。
+	// 本番モードでは警告のみとする場合等に利用する。
+	MaxResultRowsWarnOnly bool
+	// デバッグモードで、UPDATE、DELETEの実行前にWHEREに一致する行数を数え、超えた場合はpanicとする。（0の場合はチェックしない）
+	// 条件の不足によって意図せず大量の行を更新、削除してしまう誤りを検出する。
+	// 実行ごとに件数を数えるSQLが追加で発行されるため、既定では無効としている。
+	MaxAffectedRows int
+	// trueの場合、MaxAffectedRowsを超えてもpanicとせずにLoggerで警告を出力する。
+	MaxAffectedRowsWarnOnly bool
+	// デバッグモードで、LIMITの無いORDER BYのSELECTをpanicとする。
+	// 条件に一致する全行のソートが必要となるため、意図しない重いクエリとなりやすい。
+	// GROUP BYによる集計結果の並び替えや、集計関数、ウィンドウ関数内のORDER BYは対象外となる。
+	ForceLimitWithOrderBy bool
+	// trueの場合、ForceLimitWithOrderByに違反してもpanicとせずにLoggerで警告を出力する。
+	LimitWithOrderByWarnOnly bool
+	// trueの場合、実行前のチェックに違反した際にpanicではなく*GuardError（ErrGuardViolation）を返す。
+	// 対象となるチェックはGuardViolationAsErrorと同じ。WithGuardConfigを指定しない呼び出しでは、
+	// GuardViolationAsErrorがtrueの場合もtrueとして扱う。
+	ViolationAsError bool
+}
+
+// 1つのSQLで指定できるプレースホルダーの引数の個数の上限
+// PostgreSQLのプロトコルの上限（65535）。SQLiteの場合はSQLITE_MAX_VARIABLE_NUMBER（既定値は32766）に合わせて変更する。
+var MaxBindParameters = 65535
+
+// 引数の個数が上限を超える場合は、DBへ送信する前にErrTooManyParametersをラップしたerrorを返す。
+// 上限を超えた場合のドライバーのエラーは原因が分かりにくいため、分割の方法を含めたメッセージとする。
+func checkParameterCount(args []any) error {
+	if MaxBindParameters <= 0 || len(args) <= MaxBindParameters {
+		return nil
+	}
+	return fmt.Errorf("%w: %d parameters exceed the limit of %d, split the rows into smaller chunks (e.g. slices.Chunk) or pass a slice as a single parameter with = ANY($1)",
+		ErrTooManyParameters, len(args), MaxBindParameters)
+}
+
+// 取得したn行目がMaxResultRowsを超えた場合はErrTooManyRowsをラップしたerrorを返す。
+// 警告のみの場合は、超えた時点で1回だけ警告を出力する。
+func checkResultRows(n int, query string, opt *options) error {
+	max := opt.guard.MaxResultRows
+	if max <= 0 || n != max+1 {
+		return nil
+	}
+	if opt.guard.MaxResultRowsWarnOnly {
+		l.Warn(opt.ctx, fmt.Sprintf("query returned more than %d rows: %s", max, truncateQuery(query)))
+		return nil
+	}
+	return fmt.Errorf("%w: more than %d rows (query: %s)", ErrTooManyRows, max, truncateQuery(query))
+}
+
+// 本番モード（MODE_PRODUCTION）ではExec等による実行を拒否する文の種類（文の先頭のキーワード）
+// アプリケーションのコードから誤って破壊的な文を実行することを防ぐ。
+var DangerousStatements = []string{"TRUNCATE", "DROP", "ALTER", "GRANT", "REVOKE"}
+
+// 本番モードで、許可されていない危険な文が含まれる場合はpanicとする。
+func checkDangerousStatement(s *statementInfo, cfg GuardConfig) {
+	if IsDebugMode() {
+		return
+	}
+	for _, keyword := range s.statementKeywords() {
+		if slices.Contains(DangerousStatements, keyword) && !slices.ContainsFunc(cfg.AllowedStatementsInProduction, func(a string) bool {
+			return strings.EqualFold(a, keyword)
+		}) {
+			panic(PanicDangerousStatementInProduction)
+		}
+	}
+}
+
+// WithGuardConfigを指定しない呼び出しに適用するガードの設定
+// プロセス全体で共有される値のため、起動時（クエリを実行する前）にのみ変更すること。
+// 実行中に変更すると並行する全ての呼び出しへ影響する。（排他制御は行わない）
+// 呼び出しごと、テストごとに変更する場合はWithGuardConfigを利用すること。
+var DefaultGuardConfig = GuardConfig{
+	UseSeqScanCheck:          true,
+	UseWhereCheck:            true,
+	ForceNowaitOnLockingRead: true,
+	ForceUpdatedAtCheck:      true,
+}
+
+// 以下はGuardConfigへ移行する前の設定
+// falseにした場合は、DefaultGuardConfigの値に関わらずそのチェックを無効とする。
+// （WithGuardConfigを指定した呼び出しには影響しない）
+
+// Deprecated: DefaultGuardConfig.UseSeqScanCheckを利用すること。
+var UseSeqScanCheck = true
+
+// Deprecated: DefaultGuardConfig.UseWhereCheckを利用すること。
+var UseWhereCheck = true
+
+// Deprecated: DefaultGuardConfig.ForceNowaitOnLockingReadを利用すること。
+var ForceNowaitOnLockingRead = true
+
+// Deprecated: DefaultGuardConfig.ForceUpdatedAtCheckを利用すること。
+var ForceUpdatedAtCheck = true
+
+// WithGuardConfigを指定しない呼び出しに適用する設定（DefaultGuardConfigへ移行前の設定、GuardViolationAsErrorを反映したもの）を返す。
+func defaultGuardConfig() GuardConfig {
+	cfg := DefaultGuardConfig
+	cfg.UseSeqScanCheck = cfg.UseSeqScanCheck && UseSeqScanCheck
+	cfg.UseWhereCheck = cfg.UseWhereCheck && UseWhereCheck
+	cfg.ForceNowaitOnLockingRead = cfg.ForceNowaitOnLockingRead && ForceNowaitOnLockingRead
+	cfg.ForceUpdatedAtCheck = cfg.ForceUpdatedAtCheck && ForceUpdatedAtCheck
+	cfg.ViolationAsError = cfg.ViolationAsError || GuardViolationAsError
+	return cfg
+}
+
+// Seq Scanのチェックで、enable_seqscanをoffにしたことによりSeq Scanのノードへ加算されるコスト
+// （PostgreSQLのdisable_cost。PostgreSQL 18以降は加算されずに"Disabled"として出力される）
+const seqScanDisableCost = 1.0e10
+
+// Seq Scanのノードが許容する閾値（SeqScanRowsThreshold、SeqScanCostThreshold）を超えるかどうか
+// 閾値が指定されていない場合は常にtrueとなる。
+func (cfg GuardConfig) exceedsSeqScanThreshold(n *PlanNode) bool {
+	if cfg.SeqScanRowsThreshold <= 0 && cfg.SeqScanCostThreshold <= 0 {
+		return true
+	}
+	cost := n.TotalCost
+	if cost >= seqScanDisableCost {
+		cost -= seqScanDisableCost
+	}
+	return (cfg.SeqScanRowsThreshold > 0 && n.PlanRows > cfg.SeqScanRowsThreshold) ||
+		(cfg.SeqScanCostThreshold > 0 && cost > cfg.SeqScanCostThreshold)
+}
+
+// SQLのチェックに違反した場合のerror（GuardConfig.ViolationAsErrorがtrueの場合のみ返される）
+var ErrGuardViolation = errors.New("guard violation")
+
+// SQLのチェックに違反した場合の詳細
+// Messageは通常時のpanicの値（PanicDeleteSQLMustUseWhere等）と同じ。
+//
+//	var ge *ssql.GuardError
+//	if errors.As(err, &ge) && ge.Message == ssql.PanicDeleteSQLMustUseWhere { ... }
+type GuardError struct {
+	Message string
+}
+
+func (e *GuardError) Error() string {
+	return ErrGuardViolation.Error() + ": " + e.Message
+}
+
+func (e *GuardError) Unwrap() error {
+	return ErrGuardViolation
+}
+
+// SQLのチェックを実行する。
+// GuardViolationLogOnlyがtrueの場合は、チェックによるpanicを警告として出力し、nilを返す。
+// （最初の違反でチェックが中断されるため、1つの文につき出力される違反は1つのみとなる）
+// cfg.ViolationAsErrorがtrueの場合は、チェックによるpanicを*GuardErrorに変換して返す。
+// それ以外のpanicはそのまま伝搬する。
+func guard(c context.Context, cfg GuardConfig, fn func()) (err error) {
+	if !cfg.ViolationAsError && !GuardViolationLogOnly {
+		fn()
+		return nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			msg, ok := r.(string)
+			if !ok || !isGuardPanic(msg) {
+				panic(r)
+			}
+			// 本番モードの危険な文は警告のみとせずに実行を止める。
+			if GuardViolationLogOnly && msg != PanicDangerousStatementInProduction {
+				logGuardViolation(c, msg)
+				return
+			}
+			if !cfg.ViolationAsError {
+				panic(r)
+			}
+			err = &GuardError{Message: msg}
+		}
+	}()
+	fn()
+	return nil
+}
+
+// guardの外で行うチェック（実行後のSeq Scanのチェック等）の違反を報告する。
+// GuardViolationLogOnlyがtrueの場合は警告を出力して処理を続け、それ以外の場合はpanicとする。
+func reportGuardViolation(c context.Context, msg string) {
+	if GuardViolationLogOnly {
+		logGuardViolation(c, msg)
+		return
+	}
+	panic(msg)
+}
+
+// 違反箇所を特定できるように、スタックトレースを含めて警告を出力する。
+func logGuardViolation(c context.Context, msg string) {
+	l.Warn(c, fmt.Sprintf("%s: %s\n%s", ErrGuardViolation, msg, debug.Stack()))
+}
+
+// SQLのチェックによるpanicの値かどうか
+func isGuardPanic(msg string) bool {
+	for _, p := range []string{
+		PanicPlaceHolderNumberNotMatch,
+		PanicDeleteSQLMustUseWhere,
+		PanicSelectSQLMustUseWhere,
+		PanicUpdateSQLMustUseWhere,
+		PanicUpdateSQLMustHaveUpdatedAt,
+		PanicLockingReadMustUseNowait,
+		PanicQueryNotContanSelect,
+		PanicExecReturningMustHaveReturning,
+		PanicExecInReadOnlyTransaction,
+		PanicDangerousStatementInProduction,
+		PanicOrderByWithoutLimit,
+	} {
+		if msg == p {
+			return true
+		}
+	}
+	// 実行前の実行計画のチェック（GuardConfig.SeqScanCheckBeforeExec）
+	for _, format := range []string{PanicSQLIsSeqScan, PanicPlanRuleViolation} {
+		prefix, _, _ := strings.Cut(format, "%")
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	return false
+}
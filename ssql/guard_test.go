@@ -0,0 +1,364 @@
+package ssql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGuard$ ./ssql
+func TestGuard(t *testing.T) {
+	org := GuardViolationAsError
+	defer func() { GuardViolationAsError = org }()
+
+	t.Run("panic by default", func(t *testing.T) {
+		GuardViolationAsError = false
+		defer func() {
+			testutil.AssertEqual(t, recover(), PanicDeleteSQLMustUseWhere)
+		}()
+		guard(context.Background(), defaultGuardConfig(), func() { checkExecQuery("DELETE FROM users", []any{}, &options{guard: DefaultGuardConfig}) })
+	})
+
+	t.Run("error", func(t *testing.T) {
+		GuardViolationAsError = true
+		err := guard(context.Background(), defaultGuardConfig(), func() { checkExecQuery("DELETE FROM users", []any{}, &options{guard: DefaultGuardConfig}) })
+		testutil.AssertTrue(t, errors.Is(err, ErrGuardViolation))
+
+		var ge *GuardError
+		testutil.AssertTrue(t, errors.As(err, &ge))
+		testutil.AssertEqual(t, ge.Message, PanicDeleteSQLMustUseWhere)
+	})
+
+	t.Run("placeholder", func(t *testing.T) {
+		GuardViolationAsError = true
+		err := guard(context.Background(), defaultGuardConfig(), func() {
+			checkSelectQuery("SELECT * FROM users WHERE id = $1", []any{}, &options{guard: DefaultGuardConfig})
+		})
+		testutil.AssertTrue(t, errors.Is(err, ErrGuardViolation))
+	})
+
+	t.Run("no violation", func(t *testing.T) {
+		GuardViolationAsError = true
+		testutil.AssertEqual(t, guard(context.Background(), defaultGuardConfig(), func() {
+			checkExecQuery("DELETE FROM users WHERE id = $1", []any{1}, &options{guard: DefaultGuardConfig})
+		}), nil)
+	})
+
+	t.Run("plan", func(t *testing.T) {
+		GuardViolationAsError = true
+		msg := fmt.Sprintf(PanicSQLIsSeqScan, "SELECT * FROM users WHERE name = $1")
+		err := guard(context.Background(), defaultGuardConfig(), func() { panic(msg) })
+		var ge *GuardError
+		testutil.AssertTrue(t, errors.As(err, &ge))
+		testutil.AssertEqual(t, ge.Message, msg)
+	})
+
+	t.Run("other panic", func(t *testing.T) {
+		GuardViolationAsError = true
+		defer func() {
+			testutil.AssertEqual(t, recover(), "other")
+		}()
+		guard(context.Background(), defaultGuardConfig(), func() { panic("other") })
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGuardViolationLogOnly$ ./ssql
+func TestGuardViolationLogOnly(t *testing.T) {
+	org, orgAsError := GuardViolationLogOnly, GuardViolationAsError
+	defer func() { GuardViolationLogOnly, GuardViolationAsError = org, orgAsError }()
+	orgLogger := l
+	defer SetLogger(orgLogger)
+	GuardViolationLogOnly = true
+	GuardViolationAsError = true
+
+	t.Run("log and continue", func(t *testing.T) {
+		rl := &recordLogger{}
+		SetLogger(rl)
+		err := guard(context.Background(), defaultGuardConfig(), func() { checkExecQuery("DELETE FROM users", []any{}, &options{guard: DefaultGuardConfig}) })
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, len(rl.warnings()), 1)
+		testutil.AssertContainStr(t, rl.warnings()[0], PanicDeleteSQLMustUseWhere)
+		// スタックトレースに違反箇所が含まれる
+		testutil.AssertContainStr(t, rl.warnings()[0], "checkExecQuery")
+	})
+
+	t.Run("dangerous statement in production", func(t *testing.T) {
+		Mode = MODE_PRODUCTION
+		defer func() { Mode = MODE_DEBUG }()
+		rl := &recordLogger{}
+		SetLogger(rl)
+		// 警告のみとせずに実行を止める。
+		err := guard(context.Background(), defaultGuardConfig(), func() { checkExecQuery("TRUNCATE users", []any{}, &options{guard: DefaultGuardConfig}) })
+		testutil.AssertTrue(t, errors.Is(err, ErrGuardViolation))
+		testutil.AssertEqual(t, len(rl.warnings()), 0)
+
+		GuardViolationAsError = false
+		defer func() { GuardViolationAsError = true }()
+		defer func() {
+			testutil.AssertEqual(t, recover(), any(PanicDangerousStatementInProduction))
+		}()
+		guard(context.Background(), defaultGuardConfig(), func() { checkExecQuery("TRUNCATE users", []any{}, &options{guard: DefaultGuardConfig}) })
+	})
+
+	t.Run("outside guard", func(t *testing.T) {
+		rl := &recordLogger{}
+		SetLogger(rl)
+		reportGuardViolation(context.Background(), PanicSelectSQLMustUseWhere)
+		testutil.AssertEqual(t, len(rl.warnings()), 1)
+		testutil.AssertContainStr(t, rl.warnings()[0], PanicSelectSQLMustUseWhere)
+	})
+
+	t.Run("no violation", func(t *testing.T) {
+		rl := &recordLogger{}
+		SetLogger(rl)
+		testutil.AssertEqual(t, guard(context.Background(), defaultGuardConfig(), func() {
+			checkExecQuery("DELETE FROM users WHERE id = $1", []any{1}, &options{guard: DefaultGuardConfig})
+		}), nil)
+		testutil.AssertEqual(t, len(rl.warnings()), 0)
+	})
+
+	t.Run("other panic", func(t *testing.T) {
+		defer func() {
+			testutil.AssertEqual(t, recover(), "other")
+		}()
+		guard(context.Background(), defaultGuardConfig(), func() { panic("other") })
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGuardConfig$ ./ssql
+func TestGuardConfig(t *testing.T) {
+	org := GuardViolationAsError
+	defer func() { GuardViolationAsError = org }()
+	GuardViolationAsError = true
+
+	t.Run("default", func(t *testing.T) {
+		_, opt := splitArgs(nil)
+		testutil.AssertDeepEqual(t, opt.guard, defaultGuardConfig())
+		testutil.AssertTrue(t, opt.guard.ViolationAsError)
+		err := guard(context.Background(), defaultGuardConfig(), func() { checkExecQuery("UPDATE users SET name = $1", []any{"a"}, opt) })
+		testutil.AssertTrue(t, errors.Is(err, ErrGuardViolation))
+	})
+
+	t.Run("with guard config", func(t *testing.T) {
+		cfg := DefaultGuardConfig
+		cfg.UseWhereCheck = false
+		cfg.ForceUpdatedAtCheck = false
+		_, opt := splitArgs([]any{"a", WithGuardConfig(cfg)})
+		testutil.AssertEqual(t, guard(context.Background(), defaultGuardConfig(), func() { checkExecQuery("UPDATE users SET name = $1", []any{"a"}, opt) }), nil)
+		testutil.AssertEqual(t, guard(context.Background(), defaultGuardConfig(), func() { checkSelectQuery("SELECT * FROM users", []any{}, opt) }), nil)
+
+		// 他の呼び出しには影響しない
+		_, opt = splitArgs(nil)
+		testutil.AssertTrue(t, errors.Is(guard(context.Background(), defaultGuardConfig(), func() { checkSelectQuery("SELECT * FROM users", []any{}, opt) }), ErrGuardViolation))
+	})
+
+	t.Run("orm", func(t *testing.T) {
+		cfg := DefaultGuardConfig
+		cfg.UseWhereCheck = false
+		testutil.AssertDeepEqual(t, scopeSoftDelete(TestStructWithSoftDelete{}, nil, WithGuardConfig(cfg)), []string{`"deleted_at" IS NULL`})
+	})
+
+	t.Run("violation as error", func(t *testing.T) {
+		// GuardViolationAsErrorに関わらず、呼び出しごとに指定できる。
+		cfg := DefaultGuardConfig
+		cfg.ViolationAsError = false
+		_, opt := splitArgs([]any{WithGuardConfig(cfg)})
+		func() {
+			defer func() {
+				testutil.AssertEqual(t, recover(), any(PanicDeleteSQLMustUseWhere))
+			}()
+			guard(context.Background(), opt.guard, func() { checkExecQuery("DELETE FROM users", []any{}, opt) })
+		}()
+
+		GuardViolationAsError = false
+		defer func() { GuardViolationAsError = true }()
+		cfg.ViolationAsError = true
+		_, opt = splitArgs([]any{WithGuardConfig(cfg)})
+		err := guard(context.Background(), opt.guard, func() { checkExecQuery("DELETE FROM users", []any{}, opt) })
+		testutil.AssertTrue(t, errors.Is(err, ErrGuardViolation))
+	})
+
+	t.Run("deprecated vars", func(t *testing.T) {
+		defer func() { UseWhereCheck = true }()
+		UseWhereCheck = false
+		_, opt := splitArgs(nil)
+		testutil.AssertFalse(t, opt.guard.UseWhereCheck)
+		testutil.AssertEqual(t, guard(context.Background(), defaultGuardConfig(), func() { checkSelectQuery("SELECT * FROM users", []any{}, opt) }), nil)
+
+		// WithGuardConfigを指定した場合は影響しない
+		_, opt = splitArgs([]any{WithGuardConfig(DefaultGuardConfig)})
+		testutil.AssertTrue(t, opt.guard.UseWhereCheck)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestAllowNoWhere$ ./ssql
+func TestAllowNoWhere(t *testing.T) {
+	org := GuardViolationAsError
+	defer func() { GuardViolationAsError = org }()
+	GuardViolationAsError = true
+
+	_, opt := splitArgs([]any{AllowNoWhere()})
+	testutil.AssertEqual(t, guard(context.Background(), defaultGuardConfig(), func() { checkSelectQuery("SELECT * FROM users", []any{}, opt) }), nil)
+	testutil.AssertEqual(t, guard(context.Background(), defaultGuardConfig(), func() { checkExecQuery("DELETE FROM users", []any{}, opt) }), nil)
+	testutil.AssertEqual(t, guard(context.Background(), defaultGuardConfig(), func() { checkExecQuery("UPDATE users SET updated_at = now()", []any{}, opt) }), nil)
+	testutil.AssertDeepEqual(t, scopeSoftDelete(TestStructWithSoftDelete{}, nil, AllowNoWhere()), []string{`"deleted_at" IS NULL`})
+
+	// 他のチェックは引き続き行う
+	err := guard(context.Background(), defaultGuardConfig(), func() { checkExecQuery("UPDATE users SET name = $1", []any{"a"}, opt) })
+	testutil.AssertTrue(t, errors.Is(err, ErrGuardViolation))
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestCheckDangerousStatement$ ./ssql
+func TestCheckDangerousStatement(t *testing.T) {
+	org := GuardViolationAsError
+	defer func() { GuardViolationAsError = org }()
+	GuardViolationAsError = true
+
+	check := func(query string, opts ...any) error {
+		_, opt := splitArgs(opts)
+		return guard(context.Background(), defaultGuardConfig(), func() { checkExecQuery(query, []any{}, opt) })
+	}
+
+	t.Run("debug mode", func(t *testing.T) {
+		testutil.AssertEqual(t, check("TRUNCATE users"), nil)
+	})
+
+	Mode = MODE_PRODUCTION
+	defer func() { Mode = MODE_DEBUG }()
+
+	t.Run("denied", func(t *testing.T) {
+		for _, q := range []string{
+			"TRUNCATE users",
+			"drop table users",
+			"ALTER TABLE users ADD COLUMN age int",
+			"GRANT SELECT ON users TO app",
+			"UPDATE users SET updated_at = now() WHERE id = 1; DROP TABLE users",
+		} {
+			err := check(q)
+			var ge *GuardError
+			testutil.AssertTrue(t, errors.As(err, &ge))
+			testutil.AssertEqual(t, ge.Message, PanicDangerousStatementInProduction)
+		}
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		testutil.AssertEqual(t, check("INSERT INTO users (name) VALUES ('DROP TABLE users')"), nil)
+		testutil.AssertEqual(t, check("DELETE FROM users WHERE id = 1 -- ; DROP TABLE users"), nil)
+
+		cfg := DefaultGuardConfig
+		cfg.AllowedStatementsInProduction = []string{"alter"}
+		testutil.AssertEqual(t, check("ALTER TABLE users ADD COLUMN age int", WithGuardConfig(cfg)), nil)
+		testutil.AssertTrue(t, errors.Is(check("DROP TABLE users", WithGuardConfig(cfg)), ErrGuardViolation))
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestCheckResultRows$ ./ssql
+func TestCheckResultRows(t *testing.T) {
+	cfg := DefaultGuardConfig
+	cfg.MaxResultRows = 2
+
+	t.Run("unlimited", func(t *testing.T) {
+		_, opt := splitArgs(nil)
+		testutil.AssertEqual(t, checkResultRows(10000, "SELECT * FROM users WHERE is_active", opt), nil)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, opt := splitArgs([]any{WithGuardConfig(cfg)})
+		testutil.AssertEqual(t, checkResultRows(2, "SELECT * FROM users WHERE is_active", opt), nil)
+		err := checkResultRows(3, "SELECT * FROM users WHERE is_active", opt)
+		testutil.AssertTrue(t, errors.Is(err, ErrTooManyRows))
+		testutil.AssertContainStr(t, err.Error(), "SELECT * FROM users WHERE is_active")
+	})
+
+	t.Run("warn only", func(t *testing.T) {
+		org := l
+		defer SetLogger(org)
+		rl := &recordLogger{}
+		SetLogger(rl)
+
+		cfg := cfg
+		cfg.MaxResultRowsWarnOnly = true
+		_, opt := splitArgs([]any{WithGuardConfig(cfg)})
+		for n := 1; n <= 5; n++ {
+			testutil.AssertEqual(t, checkResultRows(n, "SELECT * FROM users WHERE is_active", opt), nil)
+		}
+		testutil.AssertEqual(t, len(rl.warnings()), 1)
+		testutil.AssertContainStr(t, rl.warnings()[0], "more than 2 rows")
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestCheckParameterCount$ ./ssql
+func TestCheckParameterCount(t *testing.T) {
+	testutil.AssertEqual(t, checkParameterCount(make([]any, MaxBindParameters)), nil)
+
+	err := checkParameterCount(make([]any, MaxBindParameters+1))
+	testutil.AssertTrue(t, errors.Is(err, ErrTooManyParameters))
+	testutil.AssertContainStr(t, err.Error(), "65536 parameters exceed the limit of 65535")
+
+	org := MaxBindParameters
+	defer func() { MaxBindParameters = org }()
+	MaxBindParameters = 0
+	testutil.AssertEqual(t, checkParameterCount(make([]any, 100000)), nil)
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestForceLimitWithOrderBy$ ./ssql
+func TestForceLimitWithOrderBy(t *testing.T) {
+	org := GuardViolationAsError
+	defer func() { GuardViolationAsError = org }()
+	GuardViolationAsError = true
+
+	query := "SELECT * FROM users WHERE team_id = $1 ORDER BY created_at"
+	cfg := DefaultGuardConfig
+	cfg.ForceLimitWithOrderBy = true
+
+	t.Run("disabled by default", func(t *testing.T) {
+		_, opt := splitArgs(nil)
+		testutil.AssertEqual(t, guard(context.Background(), defaultGuardConfig(), func() { checkSelectQuery(query, []any{1}, opt) }), nil)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, opt := splitArgs([]any{WithGuardConfig(cfg)})
+		var ge *GuardError
+		testutil.AssertTrue(t, errors.As(guard(context.Background(), defaultGuardConfig(), func() { checkSelectQuery(query, []any{1}, opt) }), &ge))
+		testutil.AssertEqual(t, ge.Message, PanicOrderByWithoutLimit)
+		testutil.AssertEqual(t, guard(context.Background(), defaultGuardConfig(), func() { checkSelectQuery(query+" LIMIT 10", []any{1}, opt) }), nil)
+	})
+
+	t.Run("warn only", func(t *testing.T) {
+		org := l
+		defer SetLogger(org)
+		rl := &recordLogger{}
+		SetLogger(rl)
+
+		cfg := cfg
+		cfg.LimitWithOrderByWarnOnly = true
+		_, opt := splitArgs([]any{WithGuardConfig(cfg)})
+		testutil.AssertEqual(t, guard(context.Background(), defaultGuardConfig(), func() { checkSelectQuery(query, []any{1}, opt) }), nil)
+		testutil.AssertEqual(t, len(rl.warnings()), 1)
+		testutil.AssertContainStr(t, rl.warnings()[0], query)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestSeqScanThreshold$ ./ssql
+func TestSeqScanThreshold(t *testing.T) {
+	small := &PlanNode{NodeType: "Seq Scan", PlanRows: 10, TotalCost: seqScanDisableCost + 1.5}
+	large := &PlanNode{NodeType: "Seq Scan", PlanRows: 50000, TotalCost: seqScanDisableCost + 1200}
+
+	testutil.AssertTrue(t, GuardConfig{}.exceedsSeqScanThreshold(small))
+
+	rows := GuardConfig{SeqScanRowsThreshold: 100}
+	testutil.AssertFalse(t, rows.exceedsSeqScanThreshold(small))
+	testutil.AssertTrue(t, rows.exceedsSeqScanThreshold(large))
+
+	// disable_costを除いたコストで判定する
+	cost := GuardConfig{SeqScanCostThreshold: 100}
+	testutil.AssertFalse(t, cost.exceedsSeqScanThreshold(small))
+	testutil.AssertTrue(t, cost.exceedsSeqScanThreshold(large))
+	testutil.AssertFalse(t, cost.exceedsSeqScanThreshold(&PlanNode{NodeType: "Seq Scan", TotalCost: 35.5}))
+
+	both := GuardConfig{SeqScanRowsThreshold: 100, SeqScanCostThreshold: 100}
+	testutil.AssertTrue(t, both.exceedsSeqScanThreshold(&PlanNode{NodeType: "Seq Scan", PlanRows: 1, TotalCost: 500}))
+}
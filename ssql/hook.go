@@ -0,0 +1,164 @@
+package ssql
+
+import (
+	"context"
+	"reflect"
+)
+
+// モデルのライフサイクルフック
+//
+// モデル（のポインタ）が以下のインターフェースを実装している場合、
+// Insert、Update、Delete等の実行前後に呼び出される。
+// Before系のフックがerrorを返した場合はSQLを実行せず、そのerrorを返す。
+// After系のフックがerrorを返した場合もそのerrorを返すため、
+// 取り消しが必要な場合はトランザクション内で実行すること。
+//
+// ポインタレシーバーで実装した場合は、モデルをポインタで渡した場合のみ呼び出される。
+// Beforeフックでフィールドを変更した場合は、変更後の値で書き込まれる。
+// フックのcにはWithContextで指定したコンテキストが渡される。（指定が無い場合はcontext.Background()）
+//
+//	func (u *User) BeforeInsert(c context.Context, tx ssql.Executor) error {
+//		u.Email = strings.ToLower(u.Email)
+//		return nil
+//	}
+//
+// Update、Deleteではwhereの条件に関わらず、引数に渡したモデルに対して1回だけ呼び出される。
+
+type BeforeInserter interface {
+	BeforeInsert(c context.Context, tx Executor) error
+}
+
+type AfterInserter interface {
+	AfterInsert(c context.Context, tx Executor) error
+}
+
+type BeforeUpdater interface {
+	BeforeUpdate(c context.Context, tx Executor) error
+}
+
+type AfterUpdater interface {
+	AfterUpdate(c context.Context, tx Executor) error
+}
+
+type BeforeDeleter interface {
+	BeforeDelete(c context.Context, tx Executor) error
+}
+
+// フックへ渡すコンテキストを返す。WithContextが指定されている場合はそのコンテキストとなる。
+func hookContext(opts []Option) context.Context {
+	return applyOptions(opts).ctx
+}
+
+// Beforeのフックで値が補正される場合を考慮し、validateタグの検証はフックの後に行う。
+func withInsertHooks[R any](c context.Context, tx Executor, s any, fn func() (R, error)) (R, error) {
+	var zero R
+	if h, ok := s.(BeforeInserter); ok {
+		if err := h.BeforeInsert(c, tx); err != nil {
+			return zero, err
+		}
+	}
+	if err := validateStruct(s); err != nil {
+		return zero, err
+	}
+	r, err := fn()
+	if err != nil {
+		return zero, err
+	}
+	if h, ok := s.(AfterInserter); ok {
+		if err := h.AfterInsert(c, tx); err != nil {
+			return zero, err
+		}
+	}
+	return r, nil
+}
+
+// 各要素に対してInsertのフックを呼び出す。
+func withBulkInsertHooks[T any, R any](c context.Context, tx Executor, items []T, fn func() (R, error)) (R, error) {
+	var zero R
+	for i := range items {
+		if h, ok := hookTarget(items, i).(BeforeInserter); ok {
+			if err := h.BeforeInsert(c, tx); err != nil {
+				return zero, err
+			}
+		}
+		if err := validateStruct(hookTarget(items, i)); err != nil {
+			return zero, err
+		}
+	}
+	r, err := fn()
+	if err != nil {
+		return zero, err
+	}
+	for i := range items {
+		if h, ok := hookTarget(items, i).(AfterInserter); ok {
+			if err := h.AfterInsert(c, tx); err != nil {
+				return zero, err
+			}
+		}
+	}
+	return r, nil
+}
+
+// ポインタレシーバーのフックも呼び出せるように、要素がポインタでない場合はアドレスを返す。
+func hookTarget[T any](items []T, i int) any {
+	if reflect.TypeFor[T]().Kind() == reflect.Ptr {
+		return items[i]
+	}
+	return &items[i]
+}
+
+func withUpdateHooks[R any](c context.Context, tx Executor, s any, fn func() (R, error)) (R, error) {
+	var zero R
+	if h, ok := s.(BeforeUpdater); ok {
+		if err := h.BeforeUpdate(c, tx); err != nil {
+			return zero, err
+		}
+	}
+	r, err := fn()
+	if err != nil {
+		return zero, err
+	}
+	if h, ok := s.(AfterUpdater); ok {
+		if err := h.AfterUpdate(c, tx); err != nil {
+			return zero, err
+		}
+	}
+	return r, nil
+}
+
+// 各要素に対してUpdateのフックを呼び出し、validateタグを検証する。
+func withBulkUpdateHooks[T any, R any](c context.Context, tx Executor, items []T, fn func() (R, error)) (R, error) {
+	var zero R
+	for i := range items {
+		if h, ok := hookTarget(items, i).(BeforeUpdater); ok {
+			if err := h.BeforeUpdate(c, tx); err != nil {
+				return zero, err
+			}
+		}
+		if err := validateStruct(hookTarget(items, i)); err != nil {
+			return zero, err
+		}
+	}
+	r, err := fn()
+	if err != nil {
+		return zero, err
+	}
+	for i := range items {
+		if h, ok := hookTarget(items, i).(AfterUpdater); ok {
+			if err := h.AfterUpdate(c, tx); err != nil {
+				return zero, err
+			}
+		}
+	}
+	return r, nil
+}
+
+func withDeleteHooks[R any](c context.Context, tx Executor, s any, fn func() (R, error)) (R, error) {
+	if h, ok := s.(BeforeDeleter); ok {
+		if err := h.BeforeDelete(c, tx); err != nil {
+			var zero R
+			return zero, err
+		}
+	}
+	return fn()
+}
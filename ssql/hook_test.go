@@ -0,0 +1,139 @@
+package ssql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+type testHookModel struct {
+	Name   string `database:"name"`
+	called []string
+}
+
+var errTestHook = errors.New("hook error")
+
+func (m *testHookModel) BeforeInsert(c context.Context, tx Executor) error {
+	if m.Name == "" {
+		return errTestHook
+	}
+	m.called = append(m.called, "before_insert")
+	return nil
+}
+
+func (m *testHookModel) AfterInsert(c context.Context, tx Executor) error {
+	m.called = append(m.called, "after_insert")
+	return nil
+}
+
+func (m *testHookModel) BeforeDelete(c context.Context, tx Executor) error {
+	m.called = append(m.called, "before_delete")
+	return nil
+}
+
+// 受け取ったコンテキストを記録し、SQLを実行させないようにerrorを返す。
+type testContextHookModel struct {
+	Name string `database:"name"`
+	c    context.Context
+}
+
+func (m *testContextHookModel) BeforeInsert(c context.Context, tx Executor) error {
+	m.c = c
+	return errTestHook
+}
+
+func (m *testContextHookModel) BeforeUpdate(c context.Context, tx Executor) error {
+	m.c = c
+	return errTestHook
+}
+
+func (m *testContextHookModel) BeforeDelete(c context.Context, tx Executor) error {
+	m.c = c
+	return errTestHook
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestHooks$ ./ssql
+func TestHooks(t *testing.T) {
+	t.Run("insert", func(t *testing.T) {
+		m := &testHookModel{Name: "a"}
+		_, err := withInsertHooks(context.Background(), nil, m, func() (int, error) {
+			m.called = append(m.called, "exec")
+			return 1, nil
+		})
+		testutil.AssertEqual(t, err == nil, true)
+		testutil.AssertDeepEqual(t, m.called, []string{"before_insert", "exec", "after_insert"})
+	})
+
+	t.Run("before hook error", func(t *testing.T) {
+		executed := false
+		_, err := withInsertHooks(context.Background(), nil, &testHookModel{}, func() (int, error) {
+			executed = true
+			return 1, nil
+		})
+		testutil.AssertEqual(t, err, errTestHook)
+		testutil.AssertEqual(t, executed, false)
+	})
+
+	t.Run("bulk with value items", func(t *testing.T) {
+		items := []testHookModel{{Name: "a"}, {Name: "b"}}
+		withBulkInsertHooks(context.Background(), nil, items, func() (int, error) {
+			return 0, nil
+		})
+		testutil.AssertDeepEqual(t, items[0].called, []string{"before_insert", "after_insert"})
+		testutil.AssertDeepEqual(t, items[1].called, []string{"before_insert", "after_insert"})
+	})
+
+	t.Run("value model does not call pointer receiver hook", func(t *testing.T) {
+		_, err := withInsertHooks(context.Background(), nil, testHookModel{}, func() (int, error) {
+			return 1, nil
+		})
+		testutil.AssertEqual(t, err == nil, true)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		m := &testHookModel{}
+		withDeleteHooks(context.Background(), nil, m, func() (int, error) {
+			return 1, nil
+		})
+		testutil.AssertDeepEqual(t, m.called, []string{"before_delete"})
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestHookContext$ ./ssql
+func TestHookContext(t *testing.T) {
+	type key struct{}
+	c := context.WithValue(context.Background(), key{}, "v")
+
+	for name, fn := range map[string]func(m *testContextHookModel) error{
+		"insert": func(m *testContextHookModel) error {
+			_, err := Insert(nil, m, WithContext(c))
+			return err
+		},
+		"update": func(m *testContextHookModel) error {
+			_, err := Update(nil, m, []string{"name = ?"}, []any{"a"}, map[string]any{"name": "b"}, WithContext(c))
+			return err
+		},
+		"update_bulk": func(m *testContextHookModel) error {
+			_, err := UpdateBulk(nil, []*testContextHookModel{m}, "name", []string{"name"}, WithContext(c))
+			return err
+		},
+		"delete": func(m *testContextHookModel) error {
+			_, err := Delete(nil, m, []string{"name = ?"}, []any{"a"}, WithContext(c))
+			return err
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := &testContextHookModel{}
+			testutil.AssertEqual(t, fn(m), errTestHook)
+			testutil.AssertEqual(t, m.c.Value(key{}), any("v"))
+		})
+	}
+
+	t.Run("default", func(t *testing.T) {
+		m := &testContextHookModel{}
+		Update(nil, m, []string{"name = ?"}, []any{"a"}, map[string]any{"name": "b"})
+		testutil.AssertEqual(t, m.c, context.Background())
+	})
+}
@@ -0,0 +1,51 @@
+package ssql
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ORMの関数に指定された文字列はSQLへそのまま連結されるため、
+// リクエストパラメーター等の外部からの入力をカラム名として利用する場合は、
+// ColumnやQuoteIdentifierで検証またはエスケープすること。
+
+// 識別子をダブルクォートで囲み、含まれるダブルクォートをエスケープする。
+// モデルに存在しないテーブル名やカラム名を外部からの入力で指定する場合に利用する。
+//
+//	ssql.QuoteIdentifier(`a"b`) // "a""b"
+func QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// モデルに存在するカラムかを検証した上で、ダブルクォートで囲んだカラム名を返す。
+// 存在しない場合はErrInvalidColumnを返す。
+//
+//	column, err := ssql.Column(&User{}, r.URL.Query().Get("column"))
+func Column(s any, name string) (string, error) {
+	rt := checkAndGetStructValue(s).Type()
+	if _, ok := findFieldTag(rt, name); !ok || name == "" {
+		return "", ErrInvalidColumn
+	}
+	return QuoteIdentifier(name), nil
+}
+
+// 英数字とアンダースコアのみからなる識別子、またはダブルクォートで囲まれた識別子
+var safeIdentifierRegexp = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*|"([^"]|"")+")$`)
+
+// スキーマで修飾したテーブル名（schema.table）を含む
+var safeTableNameRegexp = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*|"([^"]|"")+")(\.([A-Za-z_][A-Za-z0-9_]*|"([^"]|"")+"))?$`)
+
+// カラム名としてSQLへ連結される文字列が識別子であることをチェックする。
+func checkIdentifier(name string) {
+	if !safeIdentifierRegexp.MatchString(name) {
+		panic(fmt.Sprintf(PanicInvalidIdentifier, name))
+	}
+}
+
+// テーブル名としてSQLへ連結される文字列が識別子であることをチェックする。
+func checkTableName(name string) {
+	if !safeTableNameRegexp.MatchString(name) {
+		panic(fmt.Sprintf(PanicInvalidIdentifier, name))
+	}
+}
@@ -0,0 +1,112 @@
+package ssql
+
+import (
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestQuoteIdentifier$ ./ssql
+func TestQuoteIdentifier(t *testing.T) {
+	testutil.AssertEqual(t, QuoteIdentifier("name"), `"name"`)
+	testutil.AssertEqual(t, QuoteIdentifier(`a"b`), `"a""b"`)
+	testutil.AssertEqual(t, QuoteIdentifier(`name" = 1; DROP TABLE users; --`), `"name"" = 1; DROP TABLE users; --"`)
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestColumn$ ./ssql
+func TestColumn(t *testing.T) {
+	c, err := Column(&TestStruct{}, "name")
+	testutil.AssertEqual(t, err == nil, true)
+	testutil.AssertEqual(t, c, `"name"`)
+
+	for _, name := range []string{"", "unknown", "name; DROP TABLE users"} {
+		_, err := Column(&TestStruct{}, name)
+		testutil.AssertEqual(t, err, ErrInvalidColumn)
+	}
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestCheckIdentifier$ ./ssql
+func TestCheckIdentifier(t *testing.T) {
+	tests := []struct {
+		name      string
+		wantPanic bool
+	}{
+		{name: "name"},
+		{name: "_created_at2"},
+		{name: `"order"`},
+		{name: `"a""b"`},
+		{name: "", wantPanic: true},
+		{name: "1name", wantPanic: true},
+		{name: "name = 1, age", wantPanic: true},
+		{name: "name) FROM users; --", wantPanic: true},
+		{name: `"a"b"`, wantPanic: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if tt.wantPanic {
+					testutil.AssertEqual(t, r, any("invalid identifier: "+tt.name))
+				} else if r != nil {
+					t.Errorf("expected no panic, but got panic: %v", r)
+				}
+			}()
+			checkIdentifier(tt.name)
+		})
+	}
+
+	t.Run("set maps", func(t *testing.T) {
+		defer func() {
+			testutil.AssertEqual(t, recover(), any("invalid identifier: name = 'x', age"))
+		}()
+		getSetClauses(&TestStruct{}, map[string]any{"name = 'x', age": 1})
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestCheckTableName$ ./ssql
+func TestCheckTableName(t *testing.T) {
+	tests := []struct {
+		name      string
+		wantPanic bool
+	}{
+		{name: "events_2024_06"},
+		{name: "archive.events"},
+		{name: `"Archive"."Events"`},
+		{name: `"a.b"`},
+		{name: "", wantPanic: true},
+		{name: "a.b.c", wantPanic: true},
+		{name: "events.", wantPanic: true},
+		{name: "events WHERE 1 = 1; --", wantPanic: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if tt.wantPanic {
+					testutil.AssertEqual(t, r, any("invalid identifier: "+tt.name))
+				} else if r != nil {
+					t.Errorf("expected no panic, but got panic: %v", r)
+				}
+			}()
+			checkTableName(tt.name)
+		})
+	}
+
+	t.Run("with table", func(t *testing.T) {
+		defer func() {
+			testutil.AssertEqual(t, recover(), any("invalid identifier: users; DROP TABLE users"))
+		}()
+		getInsertMapSQL(ormTarget(&TestStruct{}, []Option{WithTable("users; DROP TABLE users")}), map[string]any{"name": "a"})
+	})
+
+	t.Run("table tag", func(t *testing.T) {
+		type invalidTableTag struct {
+			_    struct{} `table:"users u, posts"`
+			Name string   `db:"name"`
+		}
+		defer func() {
+			testutil.AssertEqual(t, recover(), any("invalid identifier: users u, posts"))
+		}()
+		modelTableName(&invalidTableTag{})
+	})
+}
@@ -0,0 +1,145 @@
+package ssql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// JOINの結果を複数のモデルを組み合わせた構造体へ格納する。
+//
+// 埋め込み（またはjoinタグを指定した）構造体のフィールドで構成された構造体をモデルとして
+// Query、QueryFirst等に渡すと、結果セットの各カラムをそれぞれのモデルのフィールドへ格納する。
+//
+//	type UserWithProfile struct {
+//		User
+//		Profile `join:"p"`
+//	}
+//
+//	query := "SELECT " + ssql.JoinColumns(&UserWithProfile{}) +
+//		` FROM users JOIN profiles p ON p.user_id = users.id WHERE users.id = $1`
+//	l, err := ssql.Query(tx, &UserWithProfile{}, query, id)
+//
+// 結果セットのカラムは"プレフィックス.カラム名"の形式でモデルを区別する。
+// プレフィックスはjoinタグで指定し、省略時はモデルのテーブル名（スキーマは除く）となる。
+// プレフィックスの無いカラムは、そのカラムを持つモデルが1つだけの場合に限り対応付ける。
+
+// JOIN用のモデルを構成するモデルのフィールド
+type joinComponent struct {
+	index  int
+	prefix string
+}
+
+// 構造体がJOIN用のモデルの場合は構成するモデルの一覧を返す。それ以外の場合はnilを返す。
+func joinComponents(rt reflect.Type) []joinComponent {
+	var r []joinComponent
+	for i := range rt.NumField() {
+		f := rt.Field(i)
+		if f.Name == "_" {
+			continue
+		}
+		prefix, hasJoinTag := f.Tag.Lookup("join")
+		if !hasJoinTag && !(f.Anonymous && f.Tag.Get("database") == "") {
+			continue
+		}
+		if f.Type.Kind() != reflect.Struct {
+			panic(fmt.Sprintf("join field must be struct: %s", f.Name))
+		}
+		if prefix == "" {
+			prefix = tableName(f.Type)
+			if _, after, ok := strings.Cut(prefix, "."); ok {
+				prefix = after
+			}
+		}
+		r = append(r, joinComponent{index: i, prefix: prefix})
+	}
+	return r
+}
+
+func joinScanTargets(structElem reflect.Value, components []joinComponent, columns []string, mapping ColumnMapping) []any {
+	structType := structElem.Type()
+	if len(components) != structType.NumField()-countBlankFields(structType) {
+		panic(fmt.Sprintf("%s has field other than join models.", structType.Name()))
+	}
+
+	// "プレフィックス.カラム名"と、プレフィックスの無いカラム名の両方で引けるようにする。
+	// プレフィックスの無いカラム名が複数のモデルに存在する場合はnilとし、曖昧なカラムとして扱う。
+	prefixed := make(map[string]any)
+	unprefixed := make(map[string]any)
+	for _, c := range components {
+		model := structElem.Field(c.index)
+		for i, tag := range columnFields(model.Type()) {
+			if tag.Column == "" {
+				panic(fmt.Sprintf("%s has no database label.", model.Type().Field(i).Name))
+			}
+			dest := toScanDest(tag, model.Field(i))
+			prefixed[c.prefix+"."+tag.Column] = dest
+			if _, ok := unprefixed[tag.Column]; ok {
+				unprefixed[tag.Column] = nil
+			} else {
+				unprefixed[tag.Column] = dest
+			}
+		}
+	}
+
+	targets := make([]any, len(columns))
+	resultColumns := make(map[string]struct{}, len(columns))
+	for i, c := range columns {
+		resultColumns[c] = struct{}{}
+		dest, ok := prefixed[c]
+		if !ok {
+			dest, ok = unprefixed[c]
+			if ok && dest == nil {
+				panic(fmt.Sprint("ambiguous result field: ", c))
+			}
+		}
+		if !ok {
+			if mapping == ColumnMappingLenient {
+				targets[i] = new(any)
+				continue
+			}
+			panic(fmt.Sprint("model does not have result field: ", c))
+		}
+		targets[i] = dest
+	}
+	if mapping == ColumnMappingStrict {
+		for _, c := range components {
+			for _, tag := range columnFields(structElem.Field(c.index).Type()) {
+				if !hasKey(resultColumns, c.prefix+"."+tag.Column) && !hasKey(resultColumns, tag.Column) {
+					panic(fmt.Sprint("result does not have model field: ", c.prefix+"."+tag.Column))
+				}
+			}
+		}
+	}
+	return targets
+}
+
+func countBlankFields(rt reflect.Type) int {
+	n := 0
+	for i := range rt.NumField() {
+		if rt.Field(i).Name == "_" {
+			n++
+		}
+	}
+	return n
+}
+
+// JOIN用のモデルの全てのカラムを"プレフィックス.カラム名"の別名を付けて列挙したSELECT句を返す。
+// プレフィックスはFROM句やJOIN句のテーブル名（または別名）と一致させること。
+func JoinColumns(mp any) string {
+	rt := reflect.TypeOf(mp)
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	components := joinComponents(rt)
+	if len(components) == 0 {
+		panic(fmt.Sprintf("%s is not join model.", rt.Name()))
+	}
+	columns := []string{}
+	for _, c := range components {
+		for _, tag := range columnFields(rt.Field(c.index).Type) {
+			columns = append(columns, fmt.Sprintf(`"%s"."%s" AS "%s.%s"`, c.prefix, tag.Column, c.prefix, tag.Column))
+		}
+	}
+	return strings.Join(columns, ", ")
+}
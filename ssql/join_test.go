@@ -0,0 +1,67 @@
+package ssql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+type testJoinOwner struct {
+	ID   int    `database:"id"`
+	Name string `database:"name"`
+}
+
+type testJoinPet struct {
+	ID      int    `database:"id"`
+	OwnerID int    `database:"owner_id"`
+	Kind    string `database:"kind"`
+}
+
+type testJoinOwnerWithPet struct {
+	testJoinOwner
+	Pet testJoinPet `join:"p"`
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestJoinColumns$ ./ssql
+func TestJoinColumns(t *testing.T) {
+	testutil.AssertEqual(t, JoinColumns(&testJoinOwnerWithPet{}),
+		`"test_join_owners"."id" AS "test_join_owners.id", "test_join_owners"."name" AS "test_join_owners.name", `+
+			`"p"."id" AS "p.id", "p"."owner_id" AS "p.owner_id", "p"."kind" AS "p.kind"`)
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestJoinScanTargets$ ./ssql
+func TestJoinScanTargets(t *testing.T) {
+	t.Run("prefixed and unprefixed", func(t *testing.T) {
+		var m testJoinOwnerWithPet
+		targets := structScanTargets(reflect.ValueOf(&m).Elem(), []string{"test_join_owners.id", "p.id", "name", "kind"}, ColumnMappingDefault)
+		*targets[0].(*int) = 1
+		*targets[1].(*int) = 2
+		*targets[2].(*string) = "owner"
+		*targets[3].(*string) = "dog"
+		testutil.AssertEqual(t, m.testJoinOwner, testJoinOwner{ID: 1, Name: "owner"})
+		testutil.AssertEqual(t, m.Pet, testJoinPet{ID: 2, Kind: "dog"})
+	})
+
+	t.Run("ambiguous", func(t *testing.T) {
+		defer func() {
+			testutil.AssertEqual(t, recover(), "ambiguous result field: id")
+		}()
+		var m testJoinOwnerWithPet
+		structScanTargets(reflect.ValueOf(&m).Elem(), []string{"id"}, ColumnMappingDefault)
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		var m testJoinOwnerWithPet
+		targets := structScanTargets(reflect.ValueOf(&m).Elem(), []string{"x.id"}, ColumnMappingLenient)
+		testutil.AssertEqual(t, len(targets), 1)
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		defer func() {
+			testutil.AssertEqual(t, recover(), "result does not have model field: p.owner_id")
+		}()
+		var m testJoinOwnerWithPet
+		structScanTargets(reflect.ValueOf(&m).Elem(), []string{"test_join_owners.id", "name", "p.id", "kind"}, ColumnMappingStrict)
+	})
+}
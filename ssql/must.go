@@ -0,0 +1,30 @@
+package ssql
+
+import "database/sql"
+
+// errorの場合にpanicとする各関数のラッパー
+// ツールやマイグレーション等、エラーをその場で処理する必要の無いスクリプト用途を想定している。
+// アプリケーションのコードではerrorを返す通常の関数を利用すること。
+
+func MustQuery[M any](tx Executor, mp *M, query string, args ...any) []M {
+	return must(Query(tx, mp, query, args...))
+}
+
+func MustQueryFirst[M any](tx Executor, mp *M, query string, args ...any) *M {
+	return must(QueryFirst(tx, mp, query, args...))
+}
+
+func MustQueryScalar[T any](tx Executor, query string, args ...any) T {
+	return must(QueryScalar[T](tx, query, args...))
+}
+
+func MustExec(tx Executor, query string, args ...any) sql.Result {
+	return must(Exec(tx, query, args...))
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
@@ -0,0 +1,188 @@
+package ssql
+
+import (
+	"context"
+	"time"
+)
+
+// 呼び出し単位で挙動を変更するためのオプション
+//
+// Query、Exec等の可変長引数argsに含めて渡す。
+// プレースホルダーに対応する引数とは区別され、SQLには渡されない。
+//
+//	ssql.Query(tx, &User{}, "SELECT * FROM users WHERE id = $1", id, ssql.WithColumnMapping(ssql.ColumnMappingLenient))
+type Option func(*options)
+
+type options struct {
+	ctx           context.Context
+	timeout       time.Duration
+	columnMapping ColumnMapping
+	primary       bool
+	retryPolicy   *RetryPolicy
+	guard         GuardConfig
+	allowSeqScan  bool
+	allowNoWhere  bool
+	analyze       bool
+	cacheTTL      time.Duration
+	table         string
+	// ORMが更新日時をセットする場合
+	skipUpdatedAtCheck bool
+}
+
+// argsからOptionを取り除き、残りの引数と適用後のオプションを返す。
+func splitArgs(args []any) ([]any, *options) {
+	o := &options{
+		ctx:           context.Background(),
+		columnMapping: DefaultColumnMapping,
+		retryPolicy:   DefaultRetryPolicy,
+		guard:         defaultGuardConfig(),
+	}
+	values := make([]any, 0, len(args))
+	for _, a := range args {
+		if opt, ok := a.(Option); ok {
+			opt(o)
+			continue
+		}
+		values = append(values, a)
+	}
+	return values, o
+}
+
+// ORMの関数等で、argsを経由せずに受け取ったOptionを適用する。
+func applyOptions(opts []Option) *options {
+	_, o := splitArgs(optionArgs(nil, opts))
+	return o
+}
+
+// WHEREのチェックを行うかどうか
+func (o *options) whereCheck() bool {
+	return o.guard.UseWhereCheck && !o.allowNoWhere
+}
+
+// クエリの実行に利用するコンテキストを返す。
+// タイムアウトが指定されている場合はデッドラインを設定する。
+// 呼び出し側で必ずcancelを呼ぶこと。
+func (o *options) context() (context.Context, context.CancelFunc) {
+	if o.timeout > 0 {
+		return context.WithTimeout(o.ctx, o.timeout)
+	}
+	return o.ctx, func() {}
+}
+
+// クエリの実行に利用するコンテキストを指定する。
+func WithContext(c context.Context) Option {
+	return func(o *options) {
+		o.ctx = c
+	}
+}
+
+// この呼び出しのみに適用するタイムアウトを指定する。
+// 時間内にクエリが完了しない場合はキャンセルされる。
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// 結果セットのカラムとモデルのフィールドの対応付けのモード
+type ColumnMapping int
+
+const (
+	// 結果セットにモデルに存在しないカラムが含まれる場合はpanicとする。
+	ColumnMappingDefault ColumnMapping = iota
+	// 結果セットにモデルに存在しないカラムが含まれる場合は無視する。
+	// 列の多いテーブルに対してSELECT *をする場合等に利用する。
+	ColumnMappingLenient
+	// ColumnMappingDefaultに加えて、モデルのフィールドが結果セットに含まれない場合もpanicとする。
+	ColumnMappingStrict
+)
+
+// Queryでの対応付けのモードのデフォルト値
+var DefaultColumnMapping = ColumnMappingDefault
+
+// 結果セットのカラムとモデルのフィールドの対応付けのモードを指定する。
+func WithColumnMapping(m ColumnMapping) Option {
+	return func(o *options) {
+		o.columnMapping = m
+	}
+}
+
+// レプリカが設定されている場合でも、この呼び出しはプライマリで実行する。
+// 直前の書き込みを確実に読み取る必要がある場合に利用する。
+func WithPrimary() Option {
+	return func(o *options) {
+		o.primary = true
+	}
+}
+
+// ORMが生成したUPDATE文に付与し、更新日時のカラムのチェックを外す。
+func withoutUpdatedAtCheck() Option {
+	return func(o *options) {
+		o.skipUpdatedAtCheck = true
+	}
+}
+
+// この呼び出しのみに適用する再試行の設定を指定する。
+func WithRetry(p RetryPolicy) Option {
+	return func(o *options) {
+		o.retryPolicy = &p
+	}
+}
+
+// ORMの関数で、モデルのテーブル名の代わりに利用するテーブル名を指定する。
+// 期間やテナントごとに分割したテーブルに対して、同じモデルを利用する場合に利用する。
+//
+//	ssql.Find(tx, &Event{}, where, values, ssql.WithTable("events_2024_06"))
+//
+// スキーマで修飾したテーブル名（archive.events）、ダブルクォートで囲んだテーブル名も指定できる。
+// 識別子として不正な文字列の場合はpanicとなる。（外部からの入力を指定する場合は事前に検証すること）
+//
+// Query、Exec等のSQLを直接指定する関数では無視される。
+func WithTable(name string) Option {
+	return func(o *options) {
+		o.table = name
+	}
+}
+
+// この呼び出しのみに適用するガードの設定を指定する。
+// サービスごとに異なる設定を利用する場合や、テストで一時的にチェックを変更する場合に利用する。
+//
+//	cfg := ssql.DefaultGuardConfig
+//	cfg.ForceUpdatedAtCheck = false
+//	ssql.Exec(tx, query, args, ssql.WithGuardConfig(cfg))
+func WithGuardConfig(cfg GuardConfig) Option {
+	return func(o *options) {
+		o.guard = cfg
+	}
+}
+
+// この呼び出しのみSeq Scanのチェックを外す。
+// 件数の少ないマスタテーブルや、バッチ処理での全件の走査等、意図したSeq Scanの場合に利用する。
+//
+//	ssql.Query(nil, &Prefecture{}, "SELECT * FROM prefectures WHERE enabled", ssql.AllowSeqScan())
+func AllowSeqScan() Option {
+	return func(o *options) {
+		o.allowSeqScan = true
+	}
+}
+
+// デバッグモードの場合に、実行前にEXPLAIN (ANALYZE, BUFFERS)を実行し、各ノードの実測の時間、行数、バッファのヒット数をLoggerへ出力する。
+// スロークエリのログ等で見つかった遅いクエリの調査に利用する。
+// SELECTのみが対象で、クエリは調査のために2回実行される。本番モードでは何もしない。
+//
+//	ssql.Query(nil, &User{}, "SELECT * FROM users WHERE team_id = $1", teamID, ssql.WithAnalyze())
+func WithAnalyze() Option {
+	return func(o *options) {
+		o.analyze = true
+	}
+}
+
+// この呼び出しのみWHEREのチェック（SELECT、UPDATE、DELETE）を外す。
+// ORMの関数では条件の指定が無い場合のチェックも外れる。
+//
+//	ssql.Query(nil, &Prefecture{}, "SELECT * FROM prefectures", ssql.AllowNoWhere(), ssql.AllowSeqScan())
+func AllowNoWhere() Option {
+	return func(o *options) {
+		o.allowNoWhere = true
+	}
+}
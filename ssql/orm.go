@@ -3,6 +3,8 @@ package ssql
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"fmt"
 	"reflect"
 	"regexp"
 	"slices"
@@ -15,63 +17,458 @@ import (
 // SQLを出力する
 var DebugSQL = false
 
-func First[M any](tx HasQuery, mp *M, whereClauses []string, whereValues []any) (*M, error) {
-	sql, values := getQuerySQL(mp, whereClauses, whereValues, nil, nil)
+// Updateのsetの値に指定すると、updated_atと同じ現在時刻がセットされる。
+//
+//	ssql.Update(tx, &User{}, where, values, map[string]any{"last_login_at": ssql.Now})
+var Now = NowValue{}
+
+type NowValue struct{}
+
+// Updateのsetの値の文字列"NOW"を現在時刻として扱う。
+//
+// Deprecated: "NOW"という文字列を保存できないため、ssql.Nowを利用してfalseにすること。
+var TreatNowStringAsCurrentTime = true
+
+func First[M any](tx Executor, mp *M, whereClauses []string, whereValues []any, opts ...Option) (*M, error) {
+	sql, values := getQuerySQL(ormTarget(mp, opts), scopeSoftDelete(mp, whereClauses, opts...), whereValues, nil, LimitOffset{})
 	debugSQL(sql, values)
-	return QueryFirst(tx, mp, sql, values...)
+	return QueryFirst(tx, mp, sql, optionArgs(values, opts)...)
+}
+
+// 主キーを指定して1件のレコードを取得する。存在しない場合はnilを返す。
+// 主キーはprimary_keyオプションを指定したカラム、指定が無い場合は"id"のカラムとなる。
+//
+//	user, err := ssql.FindByID(tx, &User{}, id)
+func FindByID[M any](tx Executor, mp *M, id any, opts ...Option) (*M, error) {
+	return First(tx, mp, []string{primaryKeyCondition(mp, "= ?")}, []any{id}, opts...)
+}
+
+// Deprecated: FirstLimitOffsetを利用する。
+func FirstLimit[M any](tx Executor, mp *M, whereClauses []string, whereValues []any, orderByClauses []string, limitOffset map[string]int, opts ...Option) (*M, error) {
+	return FirstLimitOffset(tx, mp, whereClauses, whereValues, orderByClauses, toLimitOffset(limitOffset), opts...)
 }
 
-func FirstLimit[M any](tx HasQuery, mp *M, whereClauses []string, whereValues []any, orderByClauses []string, limitOffset map[string]int) (*M, error) {
-	sql, values := getQuerySQL(mp, whereClauses, whereValues, orderByClauses, limitOffset)
+// OrderBy, Limit, Offsetを指定する場合
+func FirstLimitOffset[M any](tx Executor, mp *M, whereClauses []string, whereValues []any, orderByClauses []string, limitOffset LimitOffset, opts ...Option) (*M, error) {
+	sql, values := getQuerySQL(ormTarget(mp, opts), scopeSoftDelete(mp, whereClauses, opts...), whereValues, orderByClauses, limitOffset)
 	debugSQL(sql, values)
-	return QueryFirst(tx, mp, sql, values...)
+	return QueryFirst(tx, mp, sql, optionArgs(values, opts)...)
 }
 
-func Find[M any](tx HasQuery, mp *M, whereClauses []string, whereValues []any) ([]M, error) {
-	sql, values := getQuerySQL(mp, whereClauses, whereValues, nil, nil)
+func Find[M any](tx Executor, mp *M, whereClauses []string, whereValues []any, opts ...Option) ([]M, error) {
+	sql, values := getQuerySQL(ormTarget(mp, opts), scopeSoftDelete(mp, whereClauses, opts...), whereValues, nil, LimitOffset{})
 	debugSQL(sql, values)
-	return Query(tx, mp, sql, values...)
+	return Query(tx, mp, sql, optionArgs(values, opts)...)
+}
+
+// Findの結果を構造体のポインタのリストとして返す。（QueryPtrsを参照）
+func FindPtrs[M any](tx Executor, mp *M, whereClauses []string, whereValues []any, opts ...Option) ([]*M, error) {
+	l, err := Find(tx, mp, whereClauses, whereValues, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return toPtrs(l), nil
+}
+
+// 論理削除されたレコードも含めて取得する。
+func FirstUnscoped[M any](tx Executor, mp *M, whereClauses []string, whereValues []any, opts ...Option) (*M, error) {
+	sql, values := getQuerySQL(ormTarget(mp, opts), whereClauses, whereValues, nil, LimitOffset{})
+	debugSQL(sql, values)
+	return QueryFirst(tx, mp, sql, optionArgs(values, opts)...)
+}
+
+// 論理削除されたレコードも含めて取得する。
+func FindUnscoped[M any](tx Executor, mp *M, whereClauses []string, whereValues []any, opts ...Option) ([]M, error) {
+	sql, values := getQuerySQL(ormTarget(mp, opts), whereClauses, whereValues, nil, LimitOffset{})
+	debugSQL(sql, values)
+	return Query(tx, mp, sql, optionArgs(values, opts)...)
 }
 
-// OrderBy, Limit, Offsetを指定する場合
 // limitOffsetはmapで"limit"と"offset"を指定する。
-func FindLimit[M any](tx HasQuery, mp *M, whereClauses []string, whereValues []any, orderByClauses []string, limitOffset map[string]int) ([]M, error) {
-	sql, values := getQuerySQL(mp, whereClauses, whereValues, orderByClauses, limitOffset)
+//
+// Deprecated: FindLimitOffsetを利用する。
+func FindLimit[M any](tx Executor, mp *M, whereClauses []string, whereValues []any, orderByClauses []string, limitOffset map[string]int, opts ...Option) ([]M, error) {
+	return FindLimitOffset(tx, mp, whereClauses, whereValues, orderByClauses, toLimitOffset(limitOffset), opts...)
+}
+
+// OrderBy, Limit, Offsetを指定する場合
+//
+//	ssql.FindLimitOffset(tx, &User{}, where, values, []string{"created_at DESC"}, ssql.LimitOffset{Limit: ssql.Ptr(10)})
+func FindLimitOffset[M any](tx Executor, mp *M, whereClauses []string, whereValues []any, orderByClauses []string, limitOffset LimitOffset, opts ...Option) ([]M, error) {
+	sql, values := getQuerySQL(ormTarget(mp, opts), scopeSoftDelete(mp, whereClauses, opts...), whereValues, orderByClauses, limitOffset)
 	debugSQL(sql, values)
-	return Query(tx, mp, sql, values...)
+	return Query(tx, mp, sql, optionArgs(values, opts)...)
 }
 
-func getQuerySQL(s any, whereClauses []string, whereValues []any, orderByClauses []string, limitOffset map[string]int) (string, []any) {
-	rv := checkAndGetStructValue(s)
+// LIMITとOFFSETの指定
+// nilの場合は指定しない。（0を指定した場合はLIMIT 0となる）
+type LimitOffset struct {
+	Limit  *int
+	Offset *int
+}
+
+// 旧形式のmapをLimitOffsetへ変換する。
+// "limit"と"offset"以外のキーはタイプミスの可能性が高いためpanicとする。
+func toLimitOffset(m map[string]int) LimitOffset {
+	lo := LimitOffset{}
+	for k, v := range m {
+		switch k {
+		case "limit":
+			lo.Limit = Ptr(v)
+		case "offset":
+			lo.Offset = Ptr(v)
+		default:
+			panic(fmt.Sprintf("invalid limitOffset key: %s", k))
+		}
+	}
+	return lo
+}
+
+// Paginateの結果
+type Page[M any] struct {
+	Items []M
+	// 条件に一致するレコードの総数
+	Total int64
+	// 1始まりのページ番号
+	Page    int
+	PerPage int
+}
+
+// 総ページ数を返す。
+func (p *Page[M]) TotalPages() int {
+	if p.PerPage <= 0 {
+		return 0
+	}
+	return int((p.Total + int64(p.PerPage) - 1) / int64(p.PerPage))
+}
+
+// LIMIT/OFFSETによるページングを行い、条件に一致するレコードの総数と合わせて返す。
+// pageは1始まりとする。pageまたはperPageが1未満の場合はSQLを実行せずにErrInvalidPaginationを返す。
+//
+// 総数の取得とレコードの取得は別々のSQLで行われるため、
+// 両者の整合性が必要な場合はREPEATABLE READ以上のトランザクションをtxに指定すること。
+func Paginate[M any](tx Executor, mp *M, page int, perPage int, whereClauses []string, whereValues []any, orderByClauses []string, opts ...Option) (*Page[M], error) {
+	if page < 1 {
+		return nil, fmt.Errorf("%w: page must be positive: %d", ErrInvalidPagination, page)
+	}
+	if perPage < 1 {
+		return nil, fmt.Errorf("%w: per page must be positive: %d", ErrInvalidPagination, perPage)
+	}
+	total, err := Count(tx, mp, whereClauses, whereValues, opts...)
+	if err != nil {
+		return nil, err
+	}
+	p := &Page[M]{Items: []M{}, Total: total, Page: page, PerPage: perPage}
+	offset := (page - 1) * perPage
+	if total == 0 || int64(offset) >= total {
+		return p, nil
+	}
+	items, err := FindLimitOffset(tx, mp, whereClauses, whereValues, orderByClauses, LimitOffset{Limit: &perPage, Offset: &offset}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	p.Items = items
+	return p, nil
+}
+
+// キーセット方式（カーソル方式）でページングする。
+// cursorColumnの昇順に並べ、cursorValueより大きいレコードをpageSize件まで取得する。
+// OFFSETを使わないため、後ろのページでも性能が劣化しない。
+//
+// 最初のページはcursorValueにnilを指定する。
+// 次のページのカーソルとして最後のレコードのcursorColumnの値を返す。
+// 次のページが存在しない場合はnilを返す。（判定のためにpageSize+1件を取得する）
+// cursorColumnにはユニークなカラムを指定すること。
+// pageSizeが1未満の場合はErrInvalidPaginationを返す。
+func FindKeyset[M any](tx Executor, mp *M, cursorColumn string, cursorValue any, pageSize int, whereClauses []string, whereValues []any, opts ...Option) ([]M, any, error) {
+	if pageSize < 1 {
+		return nil, nil, fmt.Errorf("%w: page size must be positive: %d", ErrInvalidPagination, pageSize)
+	}
+	sql, values := getKeysetSQL(ormTarget(mp, opts), cursorColumn, cursorValue, pageSize, scopeSoftDelete(mp, whereClauses, opts...), whereValues)
+	debugSQL(sql, values)
+	l, err := Query(tx, mp, sql, optionArgs(values, opts)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	l, cursor := keysetPage(l, cursorColumn, pageSize)
+	return l, cursor, nil
+}
+
+// 次のページの有無を判定するために、pageSize+1件を取得するSQLを返す。
+func getKeysetSQL(s any, cursorColumn string, cursorValue any, pageSize int, whereClauses []string, whereValues []any) (string, []any) {
+	rt := checkAndGetStructValue(s).Type()
+	if _, ok := findFieldTag(rt, cursorColumn); !ok {
+		panic(fmt.Sprintf("%s does not have field: %s", rt.Name(), cursorColumn))
+	}
+	whereClauses = slices.Clone(whereClauses)
+	whereValues = slices.Clone(whereValues)
+	if cursorValue != nil {
+		whereClauses = append(whereClauses, `"`+cursorColumn+`" > ?`)
+		whereValues = append(whereValues, cursorValue)
+	}
+	limit := pageSize + 1
+	return getQuerySQL(s, whereClauses, whereValues, []string{`"` + cursorColumn + `"`}, LimitOffset{Limit: &limit})
+}
+
+// pageSize+1件まで取得したレコードから、pageSize件のレコードと次のページのカーソルを返す。
+// 取得件数がpageSize以下の場合は次のページが無いためnilを返す。
+func keysetPage[M any](l []M, cursorColumn string, pageSize int) ([]M, any) {
+	if len(l) <= pageSize {
+		return l, nil
+	}
+	l = l[:pageSize]
+	rv := reflect.ValueOf(l[len(l)-1])
 	rt := rv.Type()
+	for i, tag := range columnFields(rt) {
+		if tag.Column == cursorColumn {
+			return l, rv.Field(i).Interface()
+		}
+	}
+	return l, nil
+}
 
-	values := []any{}
-	values = append(values, whereValues...)
+// 条件に一致するレコードが存在するかどうかを返す。
+// レコード自体は取得しないため、存在チェックのみの場合に利用する。
+func Exists(tx Executor, s any, whereClauses []string, whereValues []any, opts ...Option) (bool, error) {
+	sql := getExistsSQL(ormTarget(s, opts), scopeSoftDelete(s, whereClauses, opts...))
+	debugSQL(sql, whereValues)
+	return QueryScalar[bool](tx, sql, optionArgs(whereValues, opts)...)
+}
+
+func getExistsSQL(s any, whereClauses []string) string {
+	checkAndGetStructValue(s)
 
 	whereClause := ""
 	if len(whereClauses) > 0 {
 		whereClause = " WHERE " + strings.Join(whereClauses, " AND ")
 	}
-	orderByClause := ""
-	if len(orderByClauses) > 0 {
-		orderByClause = " ORDER BY " + strings.Join(orderByClauses, ", ")
+	tableName := modelTableName(s)
+	query := "SELECT EXISTS(SELECT 1 FROM " + tableName + whereClause + ")"
+
+	// Replace placeholders with $1, $2, ...
+	query = replacePlaceholders(query, 0)
+
+	return query
+}
+
+// 条件に一致するレコードの件数を返す。
+func Count(tx Executor, s any, whereClauses []string, whereValues []any, opts ...Option) (int64, error) {
+	sql := getCountSQL(ormTarget(s, opts), scopeSoftDelete(s, whereClauses, opts...))
+	debugSQL(sql, whereValues)
+	return QueryScalar[int64](tx, sql, optionArgs(whereValues, opts)...)
+}
+
+// CountEstimateで推定値がこの件数未満の場合は、COUNT(*)で正確な件数を取得する。
+var CountEstimateThreshold int64 = 10000
+
+// テーブルの件数の推定値を返す。
+// PostgreSQLの統計情報（pg_class.reltuples）を参照するため、巨大なテーブルでも高速に取得できる。
+// 統計情報はVACUUMやANALYZEの際に更新されるため、実際の件数とは誤差がある。
+//
+// 推定値がCountEstimateThreshold未満の場合（統計情報が未取得の場合を含む）や、
+// PostgreSQL以外の場合はCOUNT(*)で正確な件数を返す。
+// いずれの場合も論理削除されたレコードを含めたテーブル全体の件数となる。
+func CountEstimate(tx Executor, s any, opts ...Option) (int64, error) {
+	target := ormTarget(s, opts)
+	if IsPostgres() {
+		sql := "SELECT COALESCE((SELECT reltuples::bigint FROM pg_class WHERE oid = to_regclass($1)), -1)"
+		debugSQL(sql, []any{modelTableName(target)})
+		n, err := QueryScalar[int64](tx, sql, optionArgs([]any{modelTableName(target), AllowSeqScan()}, opts)...)
+		if err != nil {
+			return 0, err
+		}
+		if n >= CountEstimateThreshold {
+			return n, nil
+		}
 	}
-	limitClause := ""
-	offsetClause := ""
-	if limitOffset != nil {
-		if limit, ok := limitOffset["limit"]; ok {
-			limitClause = " LIMIT ?"
-			values = append(values, limit)
+	sql := getCountSQL(target, nil)
+	debugSQL(sql, nil)
+	return QueryScalar[int64](tx, sql, optionArgs([]any{AllowNoWhere(), AllowSeqScan()}, opts)...)
+}
+
+func getCountSQL(s any, whereClauses []string) string {
+	return getAggregateSQL(s, "COUNT(*)", whereClauses)
+}
+
+// 条件に一致するレコードに対して集計関数を実行し、結果を返す。
+// exprには"SUM(amount)"のように集計式を指定する。
+//
+// 対象のレコードが存在しない場合、COUNT以外の集計関数はNULLを返すため、
+// Tにはポインタ型やsql.NullInt64等を指定すること。
+func Aggregate[T any](tx Executor, s any, expr string, whereClauses []string, whereValues []any, opts ...Option) (T, error) {
+	sql := getAggregateSQL(ormTarget(s, opts), expr, scopeSoftDelete(s, whereClauses, opts...))
+	debugSQL(sql, whereValues)
+	return QueryScalar[T](tx, sql, optionArgs(whereValues, opts)...)
+}
+
+// 合計値を返す。対象のレコードが存在しない場合は0を返す。
+func Sum[T any](tx Executor, s any, column string, whereClauses []string, whereValues []any, opts ...Option) (T, error) {
+	checkIdentifier(column)
+	return Aggregate[T](tx, s, "COALESCE(SUM("+column+"), 0)", whereClauses, whereValues, opts...)
+}
+
+// 最小値を返す。対象のレコードが存在しない場合はNULLとなる。
+func Min[T any](tx Executor, s any, column string, whereClauses []string, whereValues []any, opts ...Option) (T, error) {
+	checkIdentifier(column)
+	return Aggregate[T](tx, s, "MIN("+column+")", whereClauses, whereValues, opts...)
+}
+
+// 最大値を返す。対象のレコードが存在しない場合はNULLとなる。
+func Max[T any](tx Executor, s any, column string, whereClauses []string, whereValues []any, opts ...Option) (T, error) {
+	checkIdentifier(column)
+	return Aggregate[T](tx, s, "MAX("+column+")", whereClauses, whereValues, opts...)
+}
+
+// 平均値を返す。対象のレコードが存在しない場合はNULLとなる。
+func Avg[T any](tx Executor, s any, column string, whereClauses []string, whereValues []any, opts ...Option) (T, error) {
+	checkIdentifier(column)
+	return Aggregate[T](tx, s, "AVG("+column+")", whereClauses, whereValues, opts...)
+}
+
+func getAggregateSQL(s any, expr string, whereClauses []string) string {
+	checkAndGetStructValue(s)
+
+	whereClause := ""
+	if len(whereClauses) > 0 {
+		whereClause = " WHERE " + strings.Join(whereClauses, " AND ")
+	}
+	tableName := modelTableName(s)
+	query := "SELECT " + expr + " FROM " + tableName + whereClause
+
+	// Replace placeholders with $1, $2, ...
+	query = replacePlaceholders(query, 0)
+
+	return query
+}
+
+// 条件に一致するレコードの1つのカラムの値をスライスとして返す。
+//
+//	uids, err := ssql.Pluck[string](tx, &User{}, "uid", []string{"is_active = ?"}, []any{true})
+func Pluck[T any](tx Executor, s any, column string, whereClauses []string, whereValues []any, opts ...Option) ([]T, error) {
+	sql := getPluckSQL(ormTarget(s, opts), column, scopeSoftDelete(s, whereClauses, opts...))
+	debugSQL(sql, whereValues)
+	return QueryColumn[T](tx, sql, optionArgs(whereValues, opts)...)
+}
+
+func getPluckSQL(s any, column string, whereClauses []string) string {
+	rt := checkAndGetStructValue(s).Type()
+	if _, ok := findFieldTag(rt, column); !ok {
+		panic(fmt.Sprintf("%s does not have field: %s", rt.Name(), column))
+	}
+	return getAggregateSQL(s, `"`+column+`"`, whereClauses)
+}
+
+// モデルに存在するカラムかを検証した上でORDER BYの句を返す。
+// directionは"ASC"、"DESC"（大文字小文字は問わない）または空文字（ASC）のみ許容する。
+// リクエストパラメーター等の外部からの入力で並び順を指定する場合に、SQLインジェクションを防ぐために利用する。
+//
+// 検証に失敗した場合はErrInvalidOrderByを返す。
+func OrderBy(s any, column string, direction string) (string, error) {
+	quoted, err := Column(s, column)
+	if err != nil {
+		return "", ErrInvalidOrderBy
+	}
+	switch strings.ToUpper(direction) {
+	case "", "ASC":
+		return quoted + " ASC", nil
+	case "DESC":
+		return quoted + " DESC", nil
+	}
+	return "", ErrInvalidOrderBy
+}
+
+// "name,-created_at"のようなカンマ区切りの指定をORDER BYの句へ変換する。
+// 先頭に"-"が付いたカラムは降順とする。各カラムはOrderByと同様に検証する。
+func ParseOrderBy(s any, param string) ([]string, error) {
+	r := []string{}
+	for _, p := range strings.Split(param, ",") {
+		p = strings.TrimSpace(p)
+		direction := "ASC"
+		if strings.HasPrefix(p, "-") {
+			p = p[1:]
+			direction = "DESC"
 		}
+		clause, err := OrderBy(s, p, direction)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, clause)
+	}
+	return r, nil
+}
 
-		if offset, ok := limitOffset["offset"]; ok {
-			offsetClause = " OFFSET ?"
-			values = append(values, offset)
+func getQuerySQL(s any, whereClauses []string, whereValues []any, orderByClauses []string, limitOffset LimitOffset) (string, []any) {
+	return selectQuery{
+		whereClauses:   whereClauses,
+		whereValues:    whereValues,
+		orderByClauses: orderByClauses,
+		limitOffset:    limitOffset,
+	}.build(s)
+}
+
+// SELECT文を構成する各句
+// プレースホルダーは"?"とし、値はWHERE、HAVING、LIMIT、OFFSETの順に並べる。
+type selectQuery struct {
+	// 空の場合は"*"とする。
+	columns        []string
+	distinct       bool
+	whereClauses   []string
+	whereValues    []any
+	groupBy        []string
+	havingClauses  []string
+	havingValues   []any
+	orderByClauses []string
+	limitOffset    LimitOffset
+}
+
+func (q selectQuery) build(s any) (string, []any) {
+	checkAndGetStructValue(s)
+
+	values := []any{}
+	values = append(values, q.whereValues...)
+	values = append(values, q.havingValues...)
+
+	selectClause := "SELECT "
+	if q.distinct {
+		selectClause += "DISTINCT "
+	}
+	if len(q.columns) > 0 {
+		selectClause += strings.Join(q.columns, ", ")
+	} else {
+		selectClause += "*"
+	}
+	whereClause := ""
+	if len(q.whereClauses) > 0 {
+		whereClause = " WHERE " + strings.Join(q.whereClauses, " AND ")
+	}
+	groupByClause := ""
+	if len(q.groupBy) > 0 {
+		groupByClause = " GROUP BY " + strings.Join(q.groupBy, ", ")
+	}
+	havingClause := ""
+	if len(q.havingClauses) > 0 {
+		if len(q.groupBy) == 0 {
+			panic("having must be used with group by")
 		}
+		havingClause = " HAVING " + strings.Join(q.havingClauses, " AND ")
+	}
+	orderByClause := ""
+	if len(q.orderByClauses) > 0 {
+		orderByClause = " ORDER BY " + strings.Join(q.orderByClauses, ", ")
+	}
+	limitClause := ""
+	offsetClause := ""
+	if q.limitOffset.Limit != nil {
+		limitClause = " LIMIT ?"
+		values = append(values, *q.limitOffset.Limit)
+	}
+	if q.limitOffset.Offset != nil {
+		offsetClause = " OFFSET ?"
+		values = append(values, *q.limitOffset.Offset)
 	}
 
-	tableName := toTableName(rt.Name())
-	query := "SELECT * FROM " + tableName + whereClause + orderByClause + limitClause + offsetClause
+	tableName := modelTableName(s)
+	query := selectClause + " FROM " + tableName + whereClause + groupByClause + havingClause + orderByClause + limitClause + offsetClause
 
 	// Replace placeholders with $1, $2, ...
 	query = replacePlaceholders(query, 0)
@@ -88,26 +485,191 @@ func replacePlaceholders(query string, startIdx int) string {
 	})
 }
 
-// updated_atは暗黙的に更新される。
-// valueを"NOW"にすると現在時刻が入る。（updated_atと同じ値が入る）
-func Update(tx HasExec, s any, whereClauses []string, whereValues []any, setMaps map[string]any) (sql.Result, error) {
+// updated_at（UpdatedAtColumn）は暗黙的に更新される。
+// valueをssql.Nowにすると現在時刻が入る。（updated_atと同じ値が入る）
+// カラムの値を元に更新する場合等は、valueにExprで式を指定する。
+func Update(tx Executor, s any, whereClauses []string, whereValues []any, setMaps map[string]any, opts ...Option) (sql.Result, error) {
+	return withUpdateHooks(hookContext(opts), tx, s, func() (sql.Result, error) {
+		if err := validateSetMaps(s, setMaps); err != nil {
+			return nil, err
+		}
+		setClauses, setValues := getSetClauses(s, setMaps)
+		sql, setValues := getUpdateSQL(ormTarget(s, opts), whereClauses, whereValues, setClauses, setValues)
+		debugSQL(sql, setValues)
+		return Exec(tx, sql, append(optionArgs(setValues, opts), withoutUpdatedAtCheck())...)
+	})
+}
+
+// Updateを実行し、更新後のレコードを返す。
+func UpdateReturning[M any](tx Executor, mp *M, whereClauses []string, whereValues []any, setMaps map[string]any, opts ...Option) ([]M, error) {
+	return withUpdateHooks(hookContext(opts), tx, mp, func() ([]M, error) {
+		if err := validateSetMaps(mp, setMaps); err != nil {
+			return nil, err
+		}
+		setClauses, setValues := getSetClauses(mp, setMaps)
+		sql, values := getUpdateSQL(ormTarget(mp, opts), whereClauses, whereValues, setClauses, setValues)
+		sql += " RETURNING *"
+		debugSQL(sql, values)
+		// 引数のモデルが1行目の値で上書きされないように、コピーを渡す。
+		m := *mp
+		return ExecReturning(tx, &m, sql, append(optionArgs(values, opts), withoutUpdatedAtCheck())...)
+	})
+}
+
+// カラム名と値のマップからSET句と値を生成する。
+func getSetClauses(s any, setMaps map[string]any) ([]string, []any) {
 	setClauses := []string{}
 	setValues := []any{}
+	rt := checkAndGetStructValue(s).Type()
 	setField := getOrderedKeys(setMaps)
 	for _, field := range setField {
+		checkIdentifier(field)
 		setClauses = append(setClauses, field+" = ?")
-		setValues = append(setValues, setMaps[field])
+		value := setMaps[field]
+		if _, isExpr := value.(inlineValue); isExpr {
+			setValues = append(setValues, value)
+			continue
+		}
+		if tag, ok := findFieldTag(rt, field); ok && tag.has(TagOptionJSON) {
+			value = marshalJSONColumn(value)
+		} else if ok && tag.has(TagOptionEncrypted) {
+			value = encryptColumn(value)
+		}
+		setValues = append(setValues, value)
+	}
+	return setClauses, setValues
+}
+
+// originalとmodifiedを比較し、値が変更されたカラムのみを更新する。
+// id, created_at, updated_atは比較の対象外で、updated_atは暗黙的に更新される。
+// 変更されたカラムが無い場合はSQLを実行せず、RowsAffectedが0の結果を返す。
+//
+// BeforeUpdateのフックはmodifiedに対して、変更の有無を比較する前に呼び出される。
+func UpdateStruct[M any](tx Executor, original *M, modified *M, whereClauses []string, whereValues []any, opts ...Option) (sql.Result, error) {
+	return withUpdateHooks(hookContext(opts), tx, modified, func() (sql.Result, error) {
+		if err := validateStruct(modified); err != nil {
+			return nil, err
+		}
+		setClauses, setValues := getChangedColumns(original, modified, generatedColumns(reflect.TypeFor[M]()))
+		if len(setClauses) == 0 {
+			return driver.RowsAffected(0), nil
+		}
+		return updateWithClauses(tx, modified, whereClauses, whereValues, setClauses, setValues, opts...)
+	})
+}
+
+// 値が異なるフィールドのSET句と値を返す。
+func getChangedColumns(original any, modified any, ignores []string) ([]string, []any) {
+	ov := checkAndGetStructValue(original)
+	mv := checkAndGetStructValue(modified)
+	rt := mv.Type()
+
+	setClauses := []string{}
+	setValues := []any{}
+	for i, tag := range columnFields(rt) {
+		if slices.Contains(ignores, tag.Column) || !tag.updatable() {
+			continue
+		}
+		if reflect.DeepEqual(ov.Field(i).Interface(), mv.Field(i).Interface()) {
+			continue
+		}
+		setClauses = append(setClauses, `"`+tag.Column+`" = ?`)
+		setValues = append(setValues, toColumnValue(tag, mv.Field(i)))
 	}
-	sql, setValues := getUpdateSQL(s, whereClauses, whereValues, setClauses, setValues)
-	debugSQL(sql, setValues)
-	return Exec(tx, sql, setValues...)
+	return setClauses, setValues
+}
+
+// 複数のレコードをそれぞれの値で1つのUPDATE文により更新する。
+// keyColumnの値が一致するレコードのsetColumnsのカラムを、各要素の値で更新する。
+// updated_atは暗黙的に更新される。
+//
+//	UPDATE users AS t SET "name" = v."name", updated_at = $7
+//	FROM (SELECT "id", "name" FROM users WHERE false UNION ALL VALUES ($1, $2), ($3, $4), ($5, $6)) AS v
+//	WHERE t."id" = v."id"
+//
+// VALUESの各値の型はUNION ALLによりテーブルのカラムの型に合わせられる。
+// パラメーター数の上限を避けるため、DefaultBatchSize件ずつに分割して実行する。
+// 分割したそれぞれのSQLは個別にコミットされるため、全体の原子性が必要な場合はトランザクションをtxに指定すること。
+//
+// 戻り値のRowsAffectedは更新した件数の合計となる。
+func UpdateBulk[T any](tx Executor, items []T, keyColumn string, setColumns []string, opts ...Option) (sql.Result, error) {
+	if len(items) == 0 {
+		return driver.RowsAffected(0), nil
+	}
+	return withBulkUpdateHooks(hookContext(opts), tx, items, func() (sql.Result, error) {
+		var total int64
+		for chunk := range slices.Chunk(items, DefaultBatchSize) {
+			sql, values := getUpdateBulkSQL(chunk, keyColumn, setColumns, opts...)
+			debugSQL(sql, values)
+			result, err := Exec(tx, sql, append(optionArgs(values, opts), withoutUpdatedAtCheck())...)
+			if err != nil {
+				return nil, err
+			}
+			n, err := result.RowsAffected()
+			if err != nil {
+				panic(err)
+			}
+			total += n
+		}
+		return driver.RowsAffected(total), nil
+	})
+}
+
+func getUpdateBulkSQL[T any](items []T, keyColumn string, setColumns []string, opts ...Option) (string, []any) {
+	if len(setColumns) == 0 {
+		panic("set columns must be specified")
+	}
+	rt := checkAndGetStructValue(items[0]).Type()
+	columns := append([]string{keyColumn}, setColumns...)
+	indices := make([]int, len(columns))
+	tags := make([]fieldTag, len(columns))
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		indices[i] = columnIndex(rt, c)
+		tags[i] = parseFieldTag(rt.Field(indices[i]))
+		quoted[i] = `"` + c + `"`
+	}
+
+	values := []any{}
+	rows := []string{}
+	for _, item := range items {
+		rv := checkAndGetStructValue(item)
+		placeholders := []string{}
+		for i := range columns {
+			values = append(values, toColumnValue(tags[i], rv.Field(indices[i])))
+			placeholders = append(placeholders, "$"+strconv.Itoa(len(values)))
+		}
+		rows = append(rows, "("+strings.Join(placeholders, ", ")+")")
+	}
+
+	setClauses := []string{}
+	for _, c := range setColumns {
+		setClauses = append(setClauses, `"`+c+`" = v."`+c+`"`)
+	}
+	if c, ok := updatedAtColumn(rt); ok {
+		values = append(values, time.Now())
+		setClauses = append(setClauses, QuoteIdentifier(c)+" = $"+strconv.Itoa(len(values)))
+	}
+
+	tableName := modelTableName(ormTarget(items[0], opts))
+	query := "UPDATE " + tableName + " AS t SET " + strings.Join(setClauses, ", ") +
+		" FROM (SELECT " + strings.Join(quoted, ", ") + " FROM " + tableName + " WHERE false UNION ALL VALUES " + strings.Join(rows, ", ") + ") AS v" +
+		` WHERE t."` + keyColumn + `" = v."` + keyColumn + `"`
+	return query, values
 }
 
 // Updateするフィールドに式を指定したい場合に利用する
-func UpdateWithClauses(tx HasExec, s any, whereClauses []string, whereValues []any, setClauses []string, setValues []any) (sql.Result, error) {
-	sql, values := getUpdateSQL(s, whereClauses, whereValues, setClauses, setValues)
+func UpdateWithClauses(tx Executor, s any, whereClauses []string, whereValues []any, setClauses []string, setValues []any, opts ...Option) (sql.Result, error) {
+	return withUpdateHooks(hookContext(opts), tx, s, func() (sql.Result, error) {
+		return updateWithClauses(tx, s, whereClauses, whereValues, setClauses, setValues, opts...)
+	})
+}
+
+// フックを呼び出さずに更新する。
+func updateWithClauses(tx Executor, s any, whereClauses []string, whereValues []any, setClauses []string, setValues []any, opts ...Option) (sql.Result, error) {
+	sql, values := getUpdateSQL(ormTarget(s, opts), whereClauses, whereValues, setClauses, setValues)
 	debugSQL(sql, values)
-	return Exec(tx, sql, values...)
+	return Exec(tx, sql, append(optionArgs(values, opts), withoutUpdatedAtCheck())...)
 }
 
 // マップはループで順番が保障されないため、順番を保証するためにキーを取得する
@@ -129,20 +691,25 @@ func getUpdateSQL(s any, whereClauses []string, whereValues []any, setClauses []
 	values := slices.Clone(setValues)
 
 	for i, setValue := range setValues {
-		if strVal, ok := setValue.(string); ok && strVal == "NOW" {
+		if _, ok := setValue.(NowValue); ok {
+			values[i] = now
+		}
+		if strVal, ok := setValue.(string); ok && strVal == "NOW" && TreatNowStringAsCurrentTime {
 			values[i] = now
 		}
 	}
 
-	setClauses2 = append(setClauses2, "updated_at = ?")
-	values = append(values, now)
+	if c, ok := updatedAtColumn(rt); ok {
+		setClauses2 = append(setClauses2, QuoteIdentifier(c)+" = ?")
+		values = append(values, now)
+	}
 	values = append(values, whereValues...)
 
 	whereClause := ""
 	if len(whereClauses) > 0 {
 		whereClause = " WHERE " + strings.Join(whereClauses, " AND ")
 	}
-	tableName := toTableName(rt.Name())
+	tableName := modelTableName(s)
 	query := "UPDATE " + tableName + " SET " + strings.Join(setClauses2, ", ") + whereClause
 
 	// Replace placeholders with $1, $2, ...
@@ -151,21 +718,130 @@ func getUpdateSQL(s any, whereClauses []string, whereValues []any, setClauses []
 	return query, values
 }
 
-func Delete(tx HasExec, s any, whereClauses []string, whereValues []any) (sql.Result, error) {
-	sql := getDeleteSQL(s, whereClauses)
+// モデルに論理削除のカラム（soft_deleteオプション）がある場合は、
+// レコードを削除せずにそのカラムへ現在時刻をセットする。
+// 論理削除の場合もBeforeUpdateではなくBeforeDeleteのフックが呼び出される。
+func Delete(tx Executor, s any, whereClauses []string, whereValues []any, opts ...Option) (sql.Result, error) {
+	return withDeleteHooks(hookContext(opts), tx, s, func() (sql.Result, error) {
+		if column, ok := softDeleteColumn(checkAndGetStructValue(s).Type()); ok {
+			// 論理削除の条件が追加されることでWHEREのチェックをすり抜けないように、ここでチェックする。
+			if applyOptions(opts).whereCheck() && len(whereClauses) == 0 {
+				reportGuardViolation(context.Background(), PanicDeleteSQLMustUseWhere)
+			}
+			return updateWithClauses(tx, s, scopeSoftDelete(s, whereClauses, opts...), whereValues, []string{`"` + column + `" = ?`}, []any{Now}, opts...)
+		}
+		return hardDelete(tx, s, whereClauses, whereValues, opts...)
+	})
+}
+
+// Deleteを実行し、削除（論理削除の場合は更新）したレコードを返す。
+func DeleteReturning[M any](tx Executor, mp *M, whereClauses []string, whereValues []any, opts ...Option) ([]M, error) {
+	return withDeleteHooks(hookContext(opts), tx, mp, func() ([]M, error) {
+		var sql string
+		var values []any
+		if column, ok := softDeleteColumn(reflect.TypeFor[M]()); ok {
+			if applyOptions(opts).whereCheck() && len(whereClauses) == 0 {
+				reportGuardViolation(context.Background(), PanicDeleteSQLMustUseWhere)
+			}
+			sql, values = getUpdateSQL(ormTarget(mp, opts), scopeSoftDelete(mp, whereClauses, opts...), whereValues, []string{`"` + column + `" = ?`}, []any{Now})
+			values = append(values, withoutUpdatedAtCheck())
+		} else {
+			sql, values = getDeleteSQL(ormTarget(mp, opts), whereClauses), whereValues
+		}
+		sql += " RETURNING *"
+		debugSQL(sql, values)
+		// 引数のモデルが1行目の値で上書きされないように、コピーを渡す。
+		m := *mp
+		return ExecReturning(tx, &m, sql, optionArgs(values, opts)...)
+	})
+}
+
+// 論理削除のカラムの有無に関わらず、レコードを物理削除する。
+func HardDelete(tx Executor, s any, whereClauses []string, whereValues []any, opts ...Option) (sql.Result, error) {
+	return withDeleteHooks(hookContext(opts), tx, s, func() (sql.Result, error) {
+		return hardDelete(tx, s, whereClauses, whereValues, opts...)
+	})
+}
+
+func hardDelete(tx Executor, s any, whereClauses []string, whereValues []any, opts ...Option) (sql.Result, error) {
+	sql := getDeleteSQL(ormTarget(s, opts), whereClauses)
 	debugSQL(sql, whereValues)
-	return Exec(tx, sql, whereValues...)
+	return Exec(tx, sql, optionArgs(whereValues, opts)...)
+}
+
+// 論理削除されたレコードを復元する。
+func Restore(tx Executor, s any, whereClauses []string, whereValues []any, opts ...Option) (sql.Result, error) {
+	rt := checkAndGetStructValue(s).Type()
+	column, ok := softDeleteColumn(rt)
+	if !ok {
+		panic(fmt.Sprintf("%s does not have soft delete field", rt.Name()))
+	}
+	if applyOptions(opts).whereCheck() && len(whereClauses) == 0 {
+		reportGuardViolation(context.Background(), PanicUpdateSQLMustUseWhere)
+	}
+	whereClauses = append(slices.Clone(whereClauses), `"`+column+`" IS NOT NULL`)
+	return UpdateWithClauses(tx, s, whereClauses, whereValues, []string{`"` + column + `" = NULL`}, nil, opts...)
+}
+
+// モデルに論理削除のカラムがある場合は、論理削除されていない条件を追加する。
+// プレースホルダーの順番が変わらないように末尾に追加する。
+func scopeSoftDelete(s any, whereClauses []string, opts ...Option) []string {
+	column, ok := softDeleteColumn(checkAndGetStructValue(s).Type())
+	if !ok {
+		return whereClauses
+	}
+	// 論理削除の条件が追加されることでWHEREのチェックをすり抜けないように、ここでチェックする。
+	if applyOptions(opts).whereCheck() && len(whereClauses) == 0 {
+		reportGuardViolation(context.Background(), PanicSelectSQLMustUseWhere)
+	}
+	return append(slices.Clone(whereClauses), `"`+column+`" IS NULL`)
+}
+
+// 主キーを指定して複数のレコードを削除する。
+// 1つのSQLで削除する件数とロックの保持時間を抑えるため、DefaultBatchSize件ずつに分割して実行する。
+// 分割したそれぞれのSQLは個別にコミットされるため、全体の原子性が必要な場合はトランザクションをtxに指定すること。
+//
+// 戻り値のRowsAffectedは削除した件数の合計となる。
+func DeleteByIDs[K any](tx Executor, s any, ids []K, opts ...Option) (sql.Result, error) {
+	var total int64
+	for chunk := range slices.Chunk(ids, DefaultBatchSize) {
+		result, err := Delete(tx, s, []string{primaryKeyCondition(s, "= ANY(?)")}, []any{chunk}, opts...)
+		if err != nil {
+			return nil, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			panic(err)
+		}
+		total += n
+	}
+	return driver.RowsAffected(total), nil
+}
+
+// 主キーを指定して1件のレコードを削除する。（Deleteと同様に論理削除のカラムがある場合は論理削除する）
+func DeleteByID(tx Executor, s any, id any, opts ...Option) (sql.Result, error) {
+	return Delete(tx, s, []string{primaryKeyCondition(s, "= ?")}, []any{id}, opts...)
+}
+
+// 主キーのカラムに対する条件を返す。
+// 主キーのフィールドが無いモデルの場合はpanicとする。
+func primaryKeyCondition(s any, cond string) string {
+	rt := checkAndGetStructValue(s).Type()
+	pk := primaryKeyColumn(rt)
+	if _, ok := findFieldTag(rt, pk); !ok {
+		panic(fmt.Sprintf("%s does not have field: %s", rt.Name(), pk))
+	}
+	return QuoteIdentifier(pk) + " " + cond
 }
 
 func getDeleteSQL(s any, whereClauses []string) string {
-	rv := checkAndGetStructValue(s)
-	rt := rv.Type()
+	checkAndGetStructValue(s)
 
 	whereClause := ""
 	if len(whereClauses) > 0 {
 		whereClause = " WHERE " + strings.Join(whereClauses, " AND ")
 	}
-	tableName := toTableName(rt.Name())
+	tableName := modelTableName(s)
 	query := "DELETE FROM " + tableName + whereClause
 
 	// Replace placeholders with $1, $2, ...
@@ -175,46 +851,294 @@ func getDeleteSQL(s any, whereClauses []string) string {
 }
 
 // id, created_at, updated_atには値はセットされず、データベース側のデフォルト値に委ねる。
-func Insert(tx HasExec, s any) (sql.Result, error) {
-	sql, values := getInsertSQL(s, []string{"id", "created_at", "updated_at"})
-	debugSQL(sql, values)
-	return Exec(tx, sql, values...)
+func Insert(tx Executor, s any, opts ...Option) (sql.Result, error) {
+	return InsertWithIgnores(tx, s, generatedColumns(checkAndGetStructValue(s).Type()), opts...)
+}
+
+// Insertを実行し、データベース側で生成されたid, created_at, updated_atを構造体へ格納する。
+// pgxではLastInsertIdが利用できないため、挿入したレコードのidが必要な場合に利用する。
+func InsertReturning[M any](tx Executor, mp *M, opts ...Option) (*M, error) {
+	return withInsertHooks(hookContext(opts), tx, mp, func() (*M, error) {
+		sql, values := getInsertReturningSQL(ormTarget(mp, opts), generatedColumns(reflect.TypeFor[M]()))
+		debugSQL(sql, values)
+		// 返されるのは一部のカラムのみのため、ColumnMappingStrictが設定されていても通常のモードで格納する。
+		values = append(optionArgs(values, opts), WithColumnMapping(ColumnMappingDefault))
+		if _, err := ExecReturning(tx, mp, sql, values...); err != nil {
+			return nil, err
+		}
+		return mp, nil
+	})
+}
+
+// 主キー（id）がゼロ値の場合はInsertReturningを実行し、生成された値を構造体へ格納する。
+// それ以外の場合はid, created_at, updated_at以外のすべてのカラムをidを条件として更新し、
+// 更新後の値を構造体へ格納する。
+// 更新対象のレコードが存在しない場合はnilを返す。
+func Save[M any](tx Executor, mp *M, opts ...Option) (*M, error) {
+	rv := checkAndGetStructValue(mp)
+	id, ok := primaryKeyValue(rv)
+	if !ok {
+		panic(fmt.Sprintf("%s does not have field: %s", rv.Type().Name(), primaryKeyColumn(rv.Type())))
+	}
+	if id.IsZero() {
+		return InsertReturning(tx, mp, opts...)
+	}
+
+	return withUpdateHooks(hookContext(opts), tx, mp, func() (*M, error) {
+		if err := validateStruct(mp); err != nil {
+			return nil, err
+		}
+		sql, values := getSaveUpdateSQL(ormTarget(mp, opts), generatedColumns(reflect.TypeFor[M]()))
+		debugSQL(sql, values)
+		r, err := ExecReturning(tx, mp, sql, append(optionArgs(values, opts), withoutUpdatedAtCheck())...)
+		if err != nil {
+			return nil, err
+		}
+		if len(r) == 0 {
+			return nil, nil
+		}
+		return mp, nil
+	})
+}
+
+// 主キーのフィールドを返す。
+func primaryKeyValue(rv reflect.Value) (reflect.Value, bool) {
+	rt := rv.Type()
+	pk := primaryKeyColumn(rt)
+	for i, tag := range columnFields(rt) {
+		if tag.Column == pk {
+			return rv.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// ignores以外のすべてのカラムを、主キーを条件として更新するSQLを生成する。
+func getSaveUpdateSQL(s any, ignores []string) (string, []any) {
+	rv := checkAndGetStructValue(s)
+	rt := rv.Type()
+	id, _ := primaryKeyValue(rv)
+	pk := primaryKeyColumn(rt)
+
+	setClauses := []string{}
+	setValues := []any{}
+	if cv, ok := asColumnValuer(s); ok {
+		columns, v := cv.UpdateColumnValues()
+		fields, values := excludeColumns(columns, v, append(slices.Clone(ignores), pk))
+		for _, f := range fields {
+			setClauses = append(setClauses, f+" = ?")
+		}
+		setValues = values
+	} else {
+		for i, tag := range columnFields(rt) {
+			if slices.Contains(ignores, tag.Column) || tag.Column == pk || !tag.updatable() {
+				continue
+			}
+			setClauses = append(setClauses, `"`+tag.Column+`" = ?`)
+			setValues = append(setValues, toColumnValue(tag, rv.Field(i)))
+		}
+	}
+	sql, values := getUpdateSQL(s, []string{QuoteIdentifier(pk) + " = ?"}, []any{fieldValue(id)}, setClauses, setValues)
+	return sql + " RETURNING *", values
+}
+
+// ignoresのうちモデルに存在するカラムをRETURNINGで返すINSERT文を生成する。
+func getInsertReturningSQL(s any, ignores []string) (string, []any) {
+	rt := checkAndGetStructValue(s).Type()
+	returning := []string{}
+	for _, c := range ignores {
+		if _, ok := findFieldTag(rt, c); ok {
+			returning = append(returning, `"`+c+`"`)
+		}
+	}
+	if len(returning) == 0 {
+		panic(fmt.Sprintf("%s does not have generated field: %s", rt.Name(), strings.Join(ignores, ", ")))
+	}
+	sql, values := getInsertSQL(s, ignores)
+	return sql + " RETURNING " + strings.Join(returning, ", "), values
 }
 
 // 複数のデータを一度に挿入する。
 // id, created_at, updated_atには値はセットされず、データベース側のデフォルト値に委ねる。
-func InsertBulk[T any](tx HasExec, items []T) (sql.Result, error) {
+func InsertBulk[T any](tx Executor, items []T, opts ...Option) (sql.Result, error) {
 	if len(items) == 0 {
 		return nil, nil
 	}
-	sql, values := getBulkInsertSQL(items, []string{"id", "created_at", "updated_at"})
-	debugSQL(sql, values)
-	return Exec(tx, sql, values...)
+	return InsertBulkWithIgnores(tx, items, generatedColumns(checkAndGetStructValue(items[0]).Type()), opts...)
 }
 
 // セットしないフィールドを明示的に指定する。
-func InsertWithIgnores(tx HasExec, s any, ignores []string) (sql.Result, error) {
-	sql, values := getInsertSQL(s, ignores)
+func InsertWithIgnores(tx Executor, s any, ignores []string, opts ...Option) (sql.Result, error) {
+	return withInsertHooks(hookContext(opts), tx, s, func() (sql.Result, error) {
+		sql, values := getInsertSQL(ormTarget(s, opts), ignores)
+		debugSQL(sql, values)
+		return Exec(tx, sql, optionArgs(values, opts)...)
+	})
+}
+
+// カラム名と値のマップで指定したカラムのみを挿入する。
+// 実行時に決まる一部のカラムのみを挿入する場合に利用する。
+// マップのキーはモデルに存在するカラムのみ指定でき、存在しない場合はpanicとなる。
+// 値はUpdateと同様にssql.NowやExprを指定できる。
+//
+// 構造体を経由しないため、フックは呼び出されない。validateタグによる検証は行われる。
+//
+//	ssql.InsertMap(tx, &User{}, map[string]any{"uid": uid, "name": name})
+func InsertMap(tx Executor, s any, m map[string]any, opts ...Option) (sql.Result, error) {
+	if err := validateSetMaps(s, m); err != nil {
+		return nil, err
+	}
+	sql, values := getInsertMapSQL(ormTarget(s, opts), m)
 	debugSQL(sql, values)
-	return Exec(tx, sql, values...)
+	return Exec(tx, sql, optionArgs(values, opts)...)
+}
+
+func getInsertMapSQL(s any, m map[string]any) (string, []any) {
+	if len(m) == 0 {
+		panic("insert map must not be empty")
+	}
+	rt := checkAndGetStructValue(s).Type()
+	now := time.Now()
+
+	fields := []string{}
+	placeholders := []string{}
+	values := []any{}
+	for _, column := range getOrderedKeys(m) {
+		tag, ok := findFieldTag(rt, column)
+		if !ok {
+			panic(fmt.Sprintf("%s does not have field: %s", rt.Name(), column))
+		}
+		value := m[column]
+		switch value.(type) {
+		case NowValue:
+			value = now
+		case inlineValue:
+		default:
+			if tag.has(TagOptionJSON) {
+				value = marshalJSONColumn(value)
+			} else if tag.has(TagOptionEncrypted) {
+				value = encryptColumn(value)
+			}
+		}
+		fields = append(fields, QuoteIdentifier(column))
+		values = append(values, value)
+		placeholders = append(placeholders, "$"+strconv.Itoa(len(values)))
+	}
+
+	query := "INSERT INTO " + modelTableName(s) + " (" + strings.Join(fields, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ")"
+	return query, values
 }
 
 // 複数のデータを一度に挿入する。セットしないフィールドを明示的に指定する。
-func InsertBulkWithIgnores[T any](tx HasExec, items []T, ignores []string) (sql.Result, error) {
+func InsertBulkWithIgnores[T any](tx Executor, items []T, ignores []string, opts ...Option) (sql.Result, error) {
 	if len(items) == 0 {
 		return nil, nil
 	}
-	sql, values := getBulkInsertSQL(items, ignores)
-	debugSQL(sql, values)
-	return Exec(tx, sql, values...)
+	return withBulkInsertHooks(hookContext(opts), tx, items, func() (sql.Result, error) {
+		sql, values := getBulkInsertSQL(items, ignores, opts...)
+		debugSQL(sql, values)
+		return Exec(tx, sql, optionArgs(values, opts)...)
+	})
+}
+
+// INSERT ... ON CONFLICT (conflictColumns) DO UPDATE SETを実行する。
+// 競合した場合はupdateColumnsのカラムを挿入しようとした値で更新する。
+// updated_atは暗黙的に更新される。
+// id, created_at, updated_atには値はセットされず、データベース側のデフォルト値に委ねる。
+//
+// 競合の有無に関わらず、Insertのフックが呼び出される。
+func Upsert(tx Executor, s any, conflictColumns []string, updateColumns []string, opts ...Option) (sql.Result, error) {
+	return withInsertHooks(hookContext(opts), tx, s, func() (sql.Result, error) {
+		sql, values := getUpsertSQL(ormTarget(s, opts), generatedColumns(checkAndGetStructValue(s).Type()), conflictColumns, updateColumns)
+		debugSQL(sql, values)
+		return Exec(tx, sql, append(optionArgs(values, opts), withoutUpdatedAtCheck())...)
+	})
+}
+
+// INSERT ... ON CONFLICT DO NOTHINGを実行する。
+// 競合した場合は何もしない。（RowsAffectedが0となる）
+// conflictColumnsが空の場合は、いずれかの制約に競合した場合に何もしない。
+func InsertOrIgnore(tx Executor, s any, conflictColumns []string, opts ...Option) (sql.Result, error) {
+	return withInsertHooks(hookContext(opts), tx, s, func() (sql.Result, error) {
+		sql, values := getUpsertSQL(ormTarget(s, opts), generatedColumns(checkAndGetStructValue(s).Type()), conflictColumns, nil)
+		debugSQL(sql, values)
+		return Exec(tx, sql, optionArgs(values, opts)...)
+	})
+}
+
+// 条件に一致するレコードを返す。存在しない場合は構造体の値で挿入して返す。
+// 挿入した場合はcreatedがtrueとなる。
+//
+// 挿入はON CONFLICT DO NOTHINGで行うため、同時に挿入されてユニーク制約に競合した場合も
+// エラーとはならず、先に挿入されたレコードを取得して返す。
+// whereClausesにはユニーク制約のあるカラムの条件を指定すること。
+func FirstOrCreate[M any](tx Executor, mp *M, whereClauses []string, whereValues []any, opts ...Option) (m *M, created bool, err error) {
+	insert := *mp
+	r, err := First(tx, mp, whereClauses, whereValues, opts...)
+	if err != nil || r != nil {
+		return r, false, err
+	}
+
+	l, err := withInsertHooks(hookContext(opts), tx, &insert, func() ([]M, error) {
+		sql, values := getUpsertSQL(ormTarget(&insert, opts), generatedColumns(reflect.TypeFor[M]()), nil, nil)
+		sql += " RETURNING *"
+		debugSQL(sql, values)
+		return ExecReturning(tx, &insert, sql, optionArgs(values, opts)...)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if len(l) > 0 {
+		*mp = insert
+		return mp, true, nil
+	}
+
+	// 他のトランザクションが先に挿入した場合
+	r, err = First(tx, mp, whereClauses, whereValues, opts...)
+	return r, false, err
+}
+
+// updateColumnsが空の場合はDO NOTHINGとなる。
+func getUpsertSQL(s any, ignores []string, conflictColumns []string, updateColumns []string) (string, []any) {
+	query, values := getInsertSQL(s, ignores)
+
+	conflictTarget := ""
+	if len(conflictColumns) > 0 {
+		quoted := []string{}
+		for _, c := range conflictColumns {
+			quoted = append(quoted, QuoteIdentifier(c))
+		}
+		conflictTarget = " (" + strings.Join(quoted, ", ") + ")"
+	}
+	if len(updateColumns) == 0 {
+		return query + " ON CONFLICT" + conflictTarget + " DO NOTHING", values
+	}
+	if conflictTarget == "" {
+		panic("conflict columns must be specified for upsert")
+	}
+
+	setClauses := []string{}
+	for _, c := range updateColumns {
+		setClauses = append(setClauses, QuoteIdentifier(c)+" = EXCLUDED."+QuoteIdentifier(c))
+	}
+	if c, ok := updatedAtColumn(checkAndGetStructValue(s).Type()); ok {
+		values = append(values, time.Now())
+		setClauses = append(setClauses, QuoteIdentifier(c)+" = $"+strconv.Itoa(len(values)))
+	}
+
+	return query + " ON CONFLICT" + conflictTarget + " DO UPDATE SET " + strings.Join(setClauses, ", "), values
 }
 
 // 複数のデータを一括挿入するためのSQLを生成する
-func getBulkInsertSQL[T any](items []T, ignores []string) (string, []any) {
+func getBulkInsertSQL[T any](items []T, ignores []string, opts ...Option) (string, []any) {
 	if len(items) == 0 {
 		return "", nil
 	}
 
+	if _, ok := asColumnValuer(items[0]); ok {
+		return getGeneratedBulkInsertSQL(items, ignores, opts...)
+	}
+
 	// 最初の要素から構造体の型情報を取得
 	item0 := items[0]
 	rv := checkAndGetStructValue(item0)
@@ -223,19 +1147,26 @@ func getBulkInsertSQL[T any](items []T, ignores []string) (string, []any) {
 	// フィールド情報を取得
 	fields := []string{}
 	fieldIndices := []int{}
+	fieldTags := []fieldTag{}
 
-	for i := 0; i < rt.NumField(); i++ {
-		fieldName := rt.Field(i).Tag.Get("database")
-		if slices.Contains(ignores, fieldName) {
+	for i, tag := range columnFields(rt) {
+		if slices.Contains(ignores, tag.Column) || tag.has(TagOptionReadOnly) {
+			continue
+		}
+		// カラムは全ての行で揃える必要があるため、全ての行でゼロ値の場合のみ省略する。
+		if tag.has(TagOptionOmitEmpty) && !tag.hasDefault() && !slices.ContainsFunc(items, func(item T) bool {
+			return !checkAndGetStructValue(item).Field(i).IsZero()
+		}) {
 			continue
 		}
 
-		fields = append(fields, `"`+fieldName+`"`)
+		fields = append(fields, `"`+tag.Column+`"`)
 		fieldIndices = append(fieldIndices, i)
+		fieldTags = append(fieldTags, tag)
 	}
 
 	// テーブル名を取得
-	tableName := toTableName(rt.Name())
+	tableName := modelTableName(ormTarget(item0, opts))
 
 	// カラム部分の生成
 	query := "INSERT INTO " + tableName + " (" + strings.Join(fields, ", ") + ") VALUES "
@@ -249,19 +1180,11 @@ func getBulkInsertSQL[T any](items []T, ignores []string) (string, []any) {
 		rv := checkAndGetStructValue(item)
 
 		placeholders := []string{}
-		for _, idx := range fieldIndices {
+		for i, idx := range fieldIndices {
 			placeholders = append(placeholders, "$"+strconv.Itoa(paramCount))
 			paramCount++
 
-			if rv.Field(idx).Kind() == reflect.Ptr {
-				if rv.Field(idx).IsNil() {
-					values = append(values, nil)
-				} else {
-					values = append(values, rv.Field(idx).Elem().Interface())
-				}
-			} else {
-				values = append(values, rv.Field(idx).Interface())
-			}
+			values = append(values, toInsertValue(fieldTags[i], rv.Field(idx)))
 		}
 
 		valueGroups = append(valueGroups, "("+strings.Join(placeholders, ", ")+")")
@@ -279,26 +1202,25 @@ func getInsertSQL(s any, ignores []string) (string, []any) {
 	fields := []string{}
 	values := []any{}
 
-	for i := range rt.NumField() {
-		fieldName := rt.Field(i).Tag.Get("database")
-		if slices.Contains(ignores, fieldName) {
-			continue
-		}
+	if cv, ok := asColumnValuer(s); ok {
+		columns, v := cv.InsertColumnValues()
+		fields, values = excludeColumns(columns, v, ignores)
+	} else {
+		for i, tag := range columnFields(rt) {
+			if slices.Contains(ignores, tag.Column) || tag.has(TagOptionReadOnly) {
+				continue
+			}
+			if tag.has(TagOptionOmitEmpty) && !tag.hasDefault() && rv.Field(i).IsZero() {
+				continue
+			}
 
-		fields = append(fields, `"`+fieldName+`"`)
+			fields = append(fields, `"`+tag.Column+`"`)
 
-		if rv.Field(i).Kind() == reflect.Ptr {
-			if rv.Field(i).IsNil() {
-				values = append(values, nil)
-			} else {
-				values = append(values, rv.Field(i).Elem().Interface())
-			}
-		} else {
-			values = append(values, rv.Field(i).Interface())
+			values = append(values, toInsertValue(tag, rv.Field(i)))
 		}
 	}
 
-	tableName := toTableName(rt.Name())
+	tableName := modelTableName(s)
 
 	query := "INSERT INTO " + tableName + " (" + strings.Join(fields, ", ") + ") VALUES ("
 	placeholders := []string{}
@@ -310,6 +1232,75 @@ func getInsertSQL(s any, ignores []string) (string, []any) {
 	return query, values
 }
 
+// モデルのテーブル名を変更する場合に実装する。
+//
+//	func (Person) TableName() string { return "people" }
+type TableNamer interface {
+	TableName() string
+}
+
+// モデルに対応するテーブル名を返す。
+// 以下の優先順位で決定する。
+// ・TableNamerを実装している場合はその戻り値
+// ・"_"のフィールドのtableタグ（_ struct{} `table:"people"`）
+// ・構造体の名前をスネークケースにして"s"を付けたもの
+func tableName(rt reflect.Type) string {
+	if reflect.PointerTo(rt).Implements(tableNamerType) {
+		return reflect.New(rt).Interface().(TableNamer).TableName()
+	}
+	for i := range rt.NumField() {
+		f := rt.Field(i)
+		if f.Name == "_" {
+			if t := f.Tag.Get("table"); t != "" {
+				return t
+			}
+		}
+	}
+	return toTableName(rt.Name())
+}
+
+var tableNamerType = reflect.TypeFor[TableNamer]()
+
+// WithTableが指定された場合に、SQLを生成する関数へモデルの代わりに渡す。
+type tableOverride struct {
+	model any
+	table string
+}
+
+// WithTableが指定されている場合はモデルをtableOverrideで包んで返す。
+func ormTarget(s any, opts []Option) any {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.table == "" {
+		return s
+	}
+	return tableOverride{model: s, table: o.table}
+}
+
+// SQLの生成に利用するテーブル名を返す。
+// WithTable、TableNamer、tableタグの値はSQLへそのまま連結されるため、識別子であることをチェックする。
+func modelTableName(s any) string {
+	var name string
+	if t, ok := s.(tableOverride); ok {
+		name = t.table
+	} else {
+		name = tableName(checkAndGetStructValue(s).Type())
+	}
+	checkTableName(name)
+	return name
+}
+
+// 呼び出し元のスライスを変更しないように、コピーした上でオプションを追加する。
+func optionArgs(values []any, opts []Option) []any {
+	r := slices.Clone(values)
+	for _, opt := range opts {
+		r = append(r, opt)
+	}
+	return r
+}
+
 // toTableName converts a CamelCase string to snake_case.
 func toTableName(str string) string {
 	re := regexp.MustCompile("([a-z0-9])([A-Z])")
@@ -318,6 +1309,9 @@ func toTableName(str string) string {
 }
 
 func checkAndGetStructValue(s any) reflect.Value {
+	if t, ok := s.(tableOverride); ok {
+		s = t.model
+	}
 	rv := reflect.ValueOf(s)
 	if rv.Kind() == reflect.Ptr {
 		rv = rv.Elem()
@@ -329,6 +1323,32 @@ func checkAndGetStructValue(s any) reflect.Value {
 	return rv
 }
 
+// 構造体のフィールドの値をSQLの引数として渡せる値に変換する。
+//
+// driver.Valuerを実装している型はそのまま渡し、database/sql側でValue()を呼ばせる。
+// ポインタレシーバで実装されている場合も考慮し、アドレスを取得して渡す。
+// それ以外のポインタはnilの場合はnil、そうでない場合は参照先の値を渡す。
+func fieldValue(v reflect.Value) any {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil
+	}
+	if v.Type().Implements(valuerType) {
+		return v.Interface()
+	}
+	if reflect.PointerTo(v.Type()).Implements(valuerType) {
+		// 構造体が値で渡された場合はフィールドのアドレスを取得できないためコピーする。
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		return p.Interface()
+	}
+	if v.Kind() == reflect.Ptr {
+		return v.Elem().Interface()
+	}
+	return v.Interface()
+}
+
+var valuerType = reflect.TypeFor[driver.Valuer]()
+
 func debugSQL(sql string, values []any) {
 	if DebugSQL {
 		l.Debug(context.Background(), sql, values)
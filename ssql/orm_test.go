@@ -1,9 +1,17 @@
 package ssql
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/megur0/testutil"
 )
 
@@ -19,6 +27,36 @@ type TestStructWithMap struct {
 	Data map[string]string `database:"data"`
 }
 
+type TestStructWithJSON struct {
+	ID      int               `database:"id"`
+	Payload map[string]string `database:"payload,json"`
+	Tags    []string          `database:"tags, json"`
+}
+
+type TestStructWithArray struct {
+	Names []string `database:"names,array"`
+	Nums  []int64  `database:"nums,array"`
+}
+
+// ポインタレシーバでdriver.Valuerを実装した型
+type testMoney struct {
+	Amount int64
+}
+
+func (m *testMoney) Value() (driver.Value, error) {
+	return fmt.Sprintf("%d JPY", m.Amount), nil
+}
+
+func (m *testMoney) Scan(src any) error {
+	_, err := fmt.Sscanf(fmt.Sprint(src), "%d JPY", &m.Amount)
+	return err
+}
+
+type TestStructWithValuer struct {
+	Price    testMoney  `database:"price"`
+	Discount *testMoney `database:"discount"`
+}
+
 // env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGetInsertSQL$ ./ssql
 func TestGetInsertSQL(t *testing.T) {
 	tests := []struct {
@@ -86,6 +124,288 @@ func TestGetInsertSQL(t *testing.T) {
 	}
 }
 
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestFieldValue$ ./ssql
+func TestFieldValue(t *testing.T) {
+	t.Run("valuer_with_pointer_receiver", func(t *testing.T) {
+		_, values := getInsertSQL(TestStructWithValuer{Price: testMoney{Amount: 100}}, nil)
+		testutil.AssertEqual(t, len(values), 2)
+		v, err := values[0].(driver.Valuer).Value()
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, v, "100 JPY")
+		testutil.AssertEqual(t, values[1], nil)
+	})
+
+	t.Run("valuer_pointer_field", func(t *testing.T) {
+		_, values := getBulkInsertSQL([]TestStructWithValuer{{Discount: &testMoney{Amount: 10}}}, []string{"price"})
+		testutil.AssertEqual(t, len(values), 1)
+		v, err := values[0].(driver.Valuer).Value()
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, v, "10 JPY")
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestJSONTag$ ./ssql
+func TestJSONTag(t *testing.T) {
+	t.Run("insert_marshal", func(t *testing.T) {
+		sql, values := getInsertSQL(TestStructWithJSON{Payload: map[string]string{"a": "b"}}, []string{"id"})
+		testutil.AssertEqual(t, sql, `INSERT INTO test_struct_with_jsons ("payload", "tags") VALUES ($1, $2)`)
+		testutil.AssertDeepEqual(t, values, []any{`{"a":"b"}`, nil})
+	})
+
+	t.Run("scan_unmarshal", func(t *testing.T) {
+		m := TestStructWithJSON{}
+		rv := reflect.ValueOf(&m).Elem()
+		dest := toScanDest(parseFieldTag(rv.Type().Field(1)), rv.Field(1))
+		testutil.AssertEqual(t, dest.(*jsonScanner).Scan([]byte(`{"x":"y"}`)), nil)
+		testutil.AssertDeepEqual(t, m.Payload, map[string]string{"x": "y"})
+		testutil.AssertEqual(t, dest.(*jsonScanner).Scan(nil), nil)
+		testutil.AssertEqual(t, m.Payload == nil, true)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestArrayTag$ ./ssql
+func TestArrayTag(t *testing.T) {
+	t.Run("insert_slice", func(t *testing.T) {
+		_, values := getInsertSQL(TestStructWithArray{Names: []string{"a", "b"}}, nil)
+		testutil.AssertDeepEqual(t, values, []any{[]string{"a", "b"}, []int64(nil)})
+	})
+
+	t.Run("scan_array", func(t *testing.T) {
+		m := TestStructWithArray{}
+		rv := reflect.ValueOf(&m).Elem()
+		names := toScanDest(parseFieldTag(rv.Type().Field(0)), rv.Field(0)).(interface{ Scan(any) error })
+		testutil.AssertEqual(t, names.Scan(`{a,"b c"}`), nil)
+		testutil.AssertDeepEqual(t, m.Names, []string{"a", "b c"})
+		nums := toScanDest(parseFieldTag(rv.Type().Field(1)), rv.Field(1)).(interface{ Scan(any) error })
+		testutil.AssertEqual(t, nums.Scan([]byte(`{1,2,3}`)), nil)
+		testutil.AssertDeepEqual(t, m.Nums, []int64{1, 2, 3})
+	})
+}
+
+type TestStructWithSoftDelete struct {
+	ID        int        `database:"id"`
+	Name      string     `database:"name"`
+	DeletedAt *time.Time `database:"deleted_at,soft_delete"`
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestSoftDeleteTag$ ./ssql
+func TestSoftDeleteTag(t *testing.T) {
+	t.Run("column", func(t *testing.T) {
+		c, ok := softDeleteColumn(reflect.TypeFor[TestStructWithSoftDelete]())
+		testutil.AssertEqual(t, ok, true)
+		testutil.AssertEqual(t, c, "deleted_at")
+		_, ok = softDeleteColumn(reflect.TypeFor[TestStruct]())
+		testutil.AssertEqual(t, ok, false)
+	})
+
+	t.Run("scope", func(t *testing.T) {
+		m := TestStructWithSoftDelete{}
+		sql, _ := getQuerySQL(m, scopeSoftDelete(m, []string{"name = ?"}), []any{"a"}, nil, LimitOffset{})
+		testutil.AssertEqual(t, sql, `SELECT * FROM test_struct_with_soft_deletes WHERE name = $1 AND "deleted_at" IS NULL`)
+		testutil.AssertDeepEqual(t, scopeSoftDelete(TestStruct{}, []string{"name = ?"}), []string{"name = ?"})
+	})
+
+	t.Run("scope without where", func(t *testing.T) {
+		defer func() {
+			testutil.AssertEqual(t, recover(), PanicSelectSQLMustUseWhere)
+		}()
+		scopeSoftDelete(TestStructWithSoftDelete{}, nil)
+	})
+
+	t.Run("delete without where", func(t *testing.T) {
+		defer func() {
+			testutil.AssertEqual(t, recover(), PanicDeleteSQLMustUseWhere)
+		}()
+		Delete(nil, TestStructWithSoftDelete{}, nil, nil)
+	})
+}
+
+type TestPerson struct {
+	ID   int    `database:"id"`
+	Name string `database:"name"`
+}
+
+func (TestPerson) TableName() string {
+	return "people"
+}
+
+type TestStatusHistory struct {
+	_    struct{} `table:"audit.status_history"`
+	ID   int      `database:"id"`
+	Name string   `database:"name"`
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestTableName$ ./ssql
+func TestTableName(t *testing.T) {
+	testutil.AssertEqual(t, tableName(reflect.TypeFor[TestStruct]()), "test_structs")
+	testutil.AssertEqual(t, tableName(reflect.TypeFor[TestPerson]()), "people")
+	testutil.AssertEqual(t, tableName(reflect.TypeFor[TestStatusHistory]()), "audit.status_history")
+
+	sql, values := getInsertSQL(TestStatusHistory{ID: 1, Name: "a"}, []string{"id"})
+	testutil.AssertEqual(t, sql, `INSERT INTO audit.status_history ("name") VALUES ($1)`)
+	testutil.AssertDeepEqual(t, values, []any{"a"})
+
+	sql, _ = getQuerySQL(&TestPerson{}, []string{"name = ?"}, []any{"a"}, nil, LimitOffset{})
+	testutil.AssertEqual(t, sql, "SELECT * FROM people WHERE name = $1")
+}
+
+type TestStructWithWriteOption struct {
+	ID       int    `database:"id"`
+	Name     string `database:"name,omitempty"`
+	Token    string `database:"token,insert_only"`
+	Computed string `database:"computed,readonly"`
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestWriteTagOptions$ ./ssql
+func TestWriteTagOptions(t *testing.T) {
+	ignores := []string{"id"}
+
+	t.Run("insert", func(t *testing.T) {
+		sql, values := getInsertSQL(TestStructWithWriteOption{Token: "t", Computed: "c"}, ignores)
+		testutil.AssertEqual(t, sql, `INSERT INTO test_struct_with_write_options ("token") VALUES ($1)`)
+		testutil.AssertDeepEqual(t, values, []any{"t"})
+
+		sql, values = getInsertSQL(TestStructWithWriteOption{Name: "n", Token: "t"}, ignores)
+		testutil.AssertEqual(t, sql, `INSERT INTO test_struct_with_write_options ("name", "token") VALUES ($1, $2)`)
+		testutil.AssertDeepEqual(t, values, []any{"n", "t"})
+	})
+
+	t.Run("bulk insert", func(t *testing.T) {
+		sql, values := getBulkInsertSQL([]TestStructWithWriteOption{{Token: "t1"}, {Name: "n2", Token: "t2"}}, ignores)
+		testutil.AssertEqual(t, sql, `INSERT INTO test_struct_with_write_options ("name", "token") VALUES ($1, $2), ($3, $4)`)
+		testutil.AssertDeepEqual(t, values, []any{"", "t1", "n2", "t2"})
+
+		sql, _ = getBulkInsertSQL([]TestStructWithWriteOption{{Token: "t1"}, {Token: "t2"}}, ignores)
+		testutil.AssertEqual(t, sql, `INSERT INTO test_struct_with_write_options ("token") VALUES ($1), ($2)`)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		sql, _ := getSaveUpdateSQL(TestStructWithWriteOption{ID: 1, Name: "n", Token: "t", Computed: "c"}, ignores)
+		testutil.AssertEqual(t, sql, `UPDATE test_struct_with_write_options SET "name" = $1, "updated_at" = $2 WHERE "id" = $3 RETURNING *`)
+
+		setClauses, _ := getChangedColumns(&TestStructWithWriteOption{}, &TestStructWithWriteOption{Name: "n", Token: "t", Computed: "c"}, ignores)
+		testutil.AssertDeepEqual(t, setClauses, []string{`"name" = ?`})
+	})
+}
+
+type TestStructWithModifiedAt struct {
+	_          struct{} `created_at:"inserted_at" updated_at:"modified_at"`
+	ID         int      `database:"id"`
+	Name       string   `database:"name"`
+	InsertedAt string   `database:"inserted_at"`
+	ModifiedAt string   `database:"modified_at"`
+}
+
+type TestStructWithoutTimestamp struct {
+	_    struct{} `created_at:"-" updated_at:"-"`
+	ID   int      `database:"id"`
+	Name string   `database:"name"`
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestTimestampColumns$ ./ssql
+func TestTimestampColumns(t *testing.T) {
+	t.Run("custom columns", func(t *testing.T) {
+		testutil.AssertDeepEqual(t, generatedColumns(reflect.TypeFor[TestStructWithModifiedAt]()), []string{"id", "inserted_at", "modified_at"})
+		sql, _ := getUpdateSQL(&TestStructWithModifiedAt{}, []string{"id = ?"}, []any{1}, []string{"name = ?"}, []any{"a"})
+		testutil.AssertEqual(t, sql, `UPDATE test_struct_with_modified_ats SET name = $1, "modified_at" = $2 WHERE id = $3`)
+		sql, _ = getUpsertSQL(&TestStructWithModifiedAt{Name: "a"}, generatedColumns(reflect.TypeFor[TestStructWithModifiedAt]()), []string{"name"}, []string{"name"})
+		testutil.AssertEqual(t, sql, `INSERT INTO test_struct_with_modified_ats ("name") VALUES ($1) ON CONFLICT ("name") DO UPDATE SET "name" = EXCLUDED."name", "modified_at" = $2`)
+	})
+
+	t.Run("quoted column", func(t *testing.T) {
+		org := UpdatedAtColumn
+		defer func() { UpdatedAtColumn = org }()
+		UpdatedAtColumn = "ModifiedAt"
+		sql, _ := getUpdateSQL(&TestStruct{}, []string{"id = ?"}, []any{1}, []string{"name = ?"}, []any{"a"})
+		testutil.AssertEqual(t, sql, `UPDATE test_structs SET name = $1, "ModifiedAt" = $2 WHERE id = $3`)
+		sql, _ = getUpdateBulkSQL([]TestStruct{{ID: 1, Name: "a"}}, "id", []string{"name"})
+		testutil.AssertContainStr(t, sql, `"ModifiedAt" = $3`)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		testutil.AssertDeepEqual(t, generatedColumns(reflect.TypeFor[TestStructWithoutTimestamp]()), []string{"id"})
+		sql, values := getUpdateSQL(&TestStructWithoutTimestamp{}, []string{"id = ?"}, []any{1}, []string{"name = ?"}, []any{"a"})
+		testutil.AssertEqual(t, sql, "UPDATE test_struct_without_timestamps SET name = $1 WHERE id = $2")
+		testutil.AssertDeepEqual(t, values, []any{"a", 1})
+	})
+
+	t.Run("orm update skips updated_at check", func(t *testing.T) {
+		query := "UPDATE test_struct_without_timestamps SET name = $1 WHERE id = $2"
+		func() {
+			defer func() {
+				testutil.AssertEqual(t, recover(), PanicUpdateSQLMustHaveUpdatedAt)
+			}()
+			_, opt := splitArgs(nil)
+			checkExecQuery(query, []any{"a", 1}, opt)
+		}()
+		_, opt := splitArgs([]any{withoutUpdatedAtCheck()})
+		checkExecQuery(query, []any{"a", 1}, opt)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGeneratedColumns$ ./ssql
+func TestGeneratedColumns(t *testing.T) {
+	type generatedModel struct {
+		ID        int    `database:"id"`
+		Price     int    `database:"price"`
+		Quantity  int    `database:"quantity"`
+		Total     int    `database:"total,generated"`
+		CreatedAt string `database:"created_at"`
+		UpdatedAt string `database:"updated_at"`
+	}
+	rt := reflect.TypeFor[generatedModel]()
+	testutil.AssertDeepEqual(t, generatedColumns(rt), []string{"id", "created_at", "updated_at", "total"})
+
+	m := &generatedModel{Price: 100, Quantity: 2, Total: 200}
+	sql, values := getInsertSQL(m, generatedColumns(rt))
+	testutil.AssertEqual(t, sql, `INSERT INTO generated_models ("price", "quantity") VALUES ($1, $2)`)
+	testutil.AssertDeepEqual(t, values, []any{100, 2})
+
+	sql, _ = getInsertReturningSQL(m, generatedColumns(rt))
+	testutil.AssertEqual(t, sql, `INSERT INTO generated_models ("price", "quantity") VALUES ($1, $2) RETURNING "id", "created_at", "updated_at", "total"`)
+
+	setClauses, _ := getChangedColumns(&generatedModel{}, m, generatedColumns(rt))
+	testutil.AssertDeepEqual(t, setClauses, []string{`"price" = ?`, `"quantity" = ?`})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestDefaultTag$ ./ssql
+func TestDefaultTag(t *testing.T) {
+	type status string
+	type defaultModel struct {
+		Status   *status        `database:"status,default=pending"`
+		Priority *int           `database:"priority,default=3"`
+		Rate     *float64       `database:"rate,default=0.5"`
+		Enabled  *bool          `database:"enabled,omitempty,default=true"`
+		Note     sql.NullString `database:"note,default=none"`
+	}
+
+	query, values := getInsertSQL(&defaultModel{}, nil)
+	testutil.AssertEqual(t, query, `INSERT INTO default_models ("status", "priority", "rate", "enabled", "note") VALUES ($1, $2, $3, $4, $5)`)
+	testutil.AssertDeepEqual(t, values, []any{status("pending"), 3, 0.5, true, sql.NullString{String: "none", Valid: true}})
+
+	// ゼロ値を明示的に指定した場合はdefaultを利用しない
+	_, values = getInsertSQL(&defaultModel{Status: Ptr(status("")), Priority: Ptr(0), Rate: Ptr(0.1), Enabled: Ptr(false), Note: sql.NullString{Valid: true}}, nil)
+	testutil.AssertDeepEqual(t, values, []any{status(""), 0, 0.1, false, sql.NullString{Valid: true}})
+
+	_, values = getBulkInsertSQL([]defaultModel{{Priority: Ptr(5)}, {Status: Ptr(status("active"))}}, nil)
+	testutil.AssertDeepEqual(t, values, []any{status("pending"), 5, 0.5, true, sql.NullString{String: "none", Valid: true}, status("active"), 3, 0.5, true, sql.NullString{String: "none", Valid: true}})
+
+	t.Run("not nullable", func(t *testing.T) {
+		type invalidModel struct {
+			Enabled bool `database:"enabled,default=true"`
+		}
+		defer func() {
+			testutil.AssertEqual(t, recover(), any("default option requires pointer or nullable field: Enabled"))
+		}()
+		getInsertSQL(&invalidModel{}, nil)
+	})
+
+	defer func() {
+		testutil.AssertEqual(t, recover(), any("invalid default value: abc"))
+	}()
+	parseDefaultValue("abc", reflect.TypeFor[int]())
+}
+
 // env `cat .env` go test -v -count=1 -timeout 60s -run ^TestToTableName$ ./ssql
 func TestToTableName(t *testing.T) {
 	tests := []struct {
@@ -186,7 +506,7 @@ func TestGetQuerySQL(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			sql, values := getQuerySQL(tt.input, tt.whereClauses, tt.whereValues, tt.orderByClauses, tt.limitOffset)
+			sql, values := getQuerySQL(tt.input, tt.whereClauses, tt.whereValues, tt.orderByClauses, toLimitOffset(tt.limitOffset))
 
 			if sql != tt.expected {
 				t.Errorf("expected %v, got %v", tt.expected, sql)
@@ -199,6 +519,334 @@ func TestGetQuerySQL(t *testing.T) {
 	}
 }
 
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestLimitOffset$ ./ssql
+func TestLimitOffset(t *testing.T) {
+	t.Run("zero limit", func(t *testing.T) {
+		sql, values := getQuerySQL(TestStruct{}, nil, nil, nil, LimitOffset{Limit: Ptr(0)})
+		testutil.AssertEqual(t, sql, "SELECT * FROM test_structs LIMIT $1")
+		testutil.AssertDeepEqual(t, values, []any{0})
+	})
+
+	t.Run("typo in deprecated map", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("expected panic, but got none")
+			}
+		}()
+		toLimitOffset(map[string]int{"limt": 10})
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGetExistsSQL$ ./ssql
+func TestGetExistsSQL(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        any
+		whereClauses []string
+		expected     string
+	}{
+		{
+			name:         "single where clause",
+			input:        TestStruct{},
+			whereClauses: []string{"id = ?"},
+			expected:     "SELECT EXISTS(SELECT 1 FROM test_structs WHERE id = $1)",
+		},
+		{
+			name:         "multiple where clauses",
+			input:        &TestStruct{},
+			whereClauses: []string{"name = ?", "age > ?"},
+			expected:     "SELECT EXISTS(SELECT 1 FROM test_structs WHERE name = $1 AND age > $2)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql := getExistsSQL(tt.input, tt.whereClauses)
+			if sql != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, sql)
+			}
+		})
+	}
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGetCountSQL$ ./ssql
+func TestGetCountSQL(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        any
+		whereClauses []string
+		expected     string
+	}{
+		{
+			name:     "no where clause",
+			input:    TestStruct{},
+			expected: "SELECT COUNT(*) FROM test_structs",
+		},
+		{
+			name:         "multiple where clauses",
+			input:        TestStruct{},
+			whereClauses: []string{"name = ?", "age > ?"},
+			expected:     "SELECT COUNT(*) FROM test_structs WHERE name = $1 AND age > $2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql := getCountSQL(tt.input, tt.whereClauses)
+			if sql != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, sql)
+			}
+		})
+	}
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGetAggregateSQL$ ./ssql
+func TestGetAggregateSQL(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        any
+		expr         string
+		whereClauses []string
+		expected     string
+	}{
+		{
+			name:     "no where clause",
+			input:    TestStruct{},
+			expr:     "MAX(age)",
+			expected: "SELECT MAX(age) FROM test_structs",
+		},
+		{
+			name:         "with where clause",
+			input:        TestStruct{},
+			expr:         "COALESCE(SUM(age), 0)",
+			whereClauses: []string{"name = ?"},
+			expected:     "SELECT COALESCE(SUM(age), 0) FROM test_structs WHERE name = $1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql := getAggregateSQL(tt.input, tt.expr, tt.whereClauses)
+			if sql != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, sql)
+			}
+		})
+	}
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGetPluckSQL$ ./ssql
+func TestGetPluckSQL(t *testing.T) {
+	t.Run("with where clause", func(t *testing.T) {
+		sql := getPluckSQL(TestStruct{}, "name", []string{"age > ?"})
+		testutil.AssertEqual(t, sql, `SELECT "name" FROM test_structs WHERE age > $1`)
+	})
+
+	t.Run("unknown column", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("expected panic, but got none")
+			}
+		}()
+		getPluckSQL(TestStruct{}, "unknown", nil)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGetKeysetSQL$ ./ssql
+func TestGetKeysetSQL(t *testing.T) {
+	tests := []struct {
+		name           string
+		cursorValue    any
+		whereClauses   []string
+		whereValues    []any
+		expected       string
+		expectedValues []any
+	}{
+		{
+			name:           "first page",
+			cursorValue:    nil,
+			expected:       `SELECT * FROM test_structs ORDER BY "id" LIMIT $1`,
+			expectedValues: []any{11},
+		},
+		{
+			name:           "next page with where clause",
+			cursorValue:    5,
+			whereClauses:   []string{"age > ?"},
+			whereValues:    []any{20},
+			expected:       `SELECT * FROM test_structs WHERE age > $1 AND "id" > $2 ORDER BY "id" LIMIT $3`,
+			expectedValues: []any{20, 5, 11},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, values := getKeysetSQL(TestStruct{}, "id", tt.cursorValue, 10, tt.whereClauses, tt.whereValues)
+			testutil.AssertEqual(t, sql, tt.expected)
+			testutil.AssertDeepEqual(t, values, tt.expectedValues)
+		})
+	}
+
+	t.Run("next cursor", func(t *testing.T) {
+		l := []TestStruct{{ID: 1}, {ID: 2}, {ID: 3}}
+		page, cursor := keysetPage(l, "id", 2)
+		testutil.AssertEqual(t, len(page), 2)
+		testutil.AssertEqual(t, cursor, 2)
+
+		// pageSize件ちょうどの場合は次のページが無い
+		page, cursor = keysetPage(l, "id", 3)
+		testutil.AssertEqual(t, len(page), 3)
+		testutil.AssertEqual(t, cursor, nil)
+
+		page, cursor = keysetPage([]TestStruct{}, "id", 2)
+		testutil.AssertEqual(t, len(page), 0)
+		testutil.AssertEqual(t, cursor, nil)
+	})
+
+	t.Run("invalid page size", func(t *testing.T) {
+		_, _, err := FindKeyset(nil, &TestStruct{}, "id", nil, 0, nil, nil)
+		testutil.AssertTrue(t, errors.Is(err, ErrInvalidPagination))
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestPaginateInvalid$ ./ssql
+func TestPaginateInvalid(t *testing.T) {
+	_, err := Paginate(nil, &TestStruct{}, 0, 10, nil, nil, nil)
+	testutil.AssertTrue(t, errors.Is(err, ErrInvalidPagination))
+	_, err = Paginate(nil, &TestStruct{}, 1, 0, nil, nil, nil)
+	testutil.AssertTrue(t, errors.Is(err, ErrInvalidPagination))
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestToPtrs$ ./ssql
+func TestToPtrs(t *testing.T) {
+	l := []TestStruct{{ID: 1}, {ID: 2}}
+	r := toPtrs(l)
+	testutil.AssertEqual(t, len(r), 2)
+	r[1].Name = "changed"
+	testutil.AssertEqual(t, l[1].Name, "changed")
+	testutil.AssertEqual(t, len(toPtrs([]TestStruct{})), 0)
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestPageTotalPages$ ./ssql
+func TestPageTotalPages(t *testing.T) {
+	testutil.AssertEqual(t, (&Page[TestStruct]{Total: 0, PerPage: 10}).TotalPages(), 0)
+	testutil.AssertEqual(t, (&Page[TestStruct]{Total: 10, PerPage: 10}).TotalPages(), 1)
+	testutil.AssertEqual(t, (&Page[TestStruct]{Total: 11, PerPage: 10}).TotalPages(), 2)
+	testutil.AssertEqual(t, (&Page[TestStruct]{Total: 11, PerPage: 0}).TotalPages(), 0)
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGetUpsertSQL$ ./ssql
+func TestGetUpsertSQL(t *testing.T) {
+	ignores := []string{"id", "created_at", "updated_at"}
+
+	t.Run("do update", func(t *testing.T) {
+		sql, values := getUpsertSQL(TestStruct{Name: "John", Age: 30}, ignores, []string{"name"}, []string{"age"})
+		testutil.AssertEqual(t, sql, `INSERT INTO test_structs ("name", "age") VALUES ($1, $2) ON CONFLICT ("name") DO UPDATE SET "age" = EXCLUDED."age", "updated_at" = $3`)
+		testutil.AssertEqual(t, len(values), 3)
+	})
+
+	t.Run("do nothing", func(t *testing.T) {
+		sql, values := getUpsertSQL(TestStruct{Name: "John", Age: 30}, ignores, nil, nil)
+		testutil.AssertEqual(t, sql, `INSERT INTO test_structs ("name", "age") VALUES ($1, $2) ON CONFLICT DO NOTHING`)
+		testutil.AssertDeepEqual(t, values, []any{"John", 30})
+	})
+
+	t.Run("do update without conflict columns", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("expected panic, but got none")
+			}
+		}()
+		getUpsertSQL(TestStruct{Name: "John", Age: 30}, ignores, nil, []string{"age"})
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGetInsertReturningSQL$ ./ssql
+func TestGetInsertReturningSQL(t *testing.T) {
+	sql, values := getInsertReturningSQL(TestStruct{Name: "John", Age: 30}, []string{"id", "created_at", "updated_at"})
+	testutil.AssertEqual(t, sql, `INSERT INTO test_structs ("name", "age") VALUES ($1, $2) RETURNING "id", "created_at", "updated_at"`)
+	testutil.AssertDeepEqual(t, values, []any{"John", 30})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGetSaveUpdateSQL$ ./ssql
+func TestGetSaveUpdateSQL(t *testing.T) {
+	sql, values := getSaveUpdateSQL(TestStruct{ID: 1, Name: "John", Age: 30}, []string{"id", "created_at", "updated_at"})
+	testutil.AssertEqual(t, sql, `UPDATE test_structs SET "name" = $1, "age" = $2, "updated_at" = $3 WHERE "id" = $4 RETURNING *`)
+	testutil.AssertEqual(t, len(values), 4)
+	testutil.AssertEqual(t, values[0], "John")
+	testutil.AssertEqual(t, values[3], 1)
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestPrimaryKeyCondition$ ./ssql
+func TestPrimaryKeyCondition(t *testing.T) {
+	type codeModel struct {
+		Code string `database:"code,primary_key"`
+		Name string `database:"name"`
+	}
+	testutil.AssertEqual(t, primaryKeyCondition(&TestStruct{}, "= ?"), `"id" = ?`)
+	testutil.AssertEqual(t, primaryKeyCondition(&codeModel{}, "= ANY(?)"), `"code" = ANY(?)`)
+
+	sql, values := getSaveUpdateSQL(&codeModel{Code: "a", Name: "n"}, nil)
+	testutil.AssertEqual(t, sql, `UPDATE code_models SET "name" = $1, "updated_at" = $2 WHERE "code" = $3 RETURNING *`)
+	testutil.AssertEqual(t, values[2], any("a"))
+
+	defer func() {
+		testutil.AssertEqual(t, recover(), any("TestStructWithMap does not have field: id"))
+	}()
+	primaryKeyCondition(&TestStructWithMap{}, "= ?")
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGetChangedColumns$ ./ssql
+func TestGetChangedColumns(t *testing.T) {
+	ignores := []string{"id", "created_at", "updated_at"}
+	original := TestStruct{ID: 1, Name: "John", Age: 30, UpdatedAt: "2023-10-01"}
+
+	t.Run("changed", func(t *testing.T) {
+		modified := original
+		modified.Age = 31
+		modified.UpdatedAt = "2023-10-02"
+		setClauses, setValues := getChangedColumns(&original, &modified, ignores)
+		testutil.AssertDeepEqual(t, setClauses, []string{`"age" = ?`})
+		testutil.AssertDeepEqual(t, setValues, []any{31})
+	})
+
+	t.Run("not changed", func(t *testing.T) {
+		modified := original
+		setClauses, _ := getChangedColumns(&original, &modified, ignores)
+		testutil.AssertEqual(t, len(setClauses), 0)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestOrderBy$ ./ssql
+func TestOrderBy(t *testing.T) {
+	tests := []struct {
+		column    string
+		direction string
+		expected  string
+		err       error
+	}{
+		{column: "name", direction: "", expected: `"name" ASC`},
+		{column: "age", direction: "desc", expected: `"age" DESC`},
+		{column: "unknown", direction: "ASC", err: ErrInvalidOrderBy},
+		{column: "name; DROP TABLE users", direction: "ASC", err: ErrInvalidOrderBy},
+		{column: "name", direction: "ASC, (SELECT 1)", err: ErrInvalidOrderBy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.column+" "+tt.direction, func(t *testing.T) {
+			r, err := OrderBy(&TestStruct{}, tt.column, tt.direction)
+			testutil.AssertEqual(t, err, tt.err)
+			testutil.AssertEqual(t, r, tt.expected)
+		})
+	}
+
+	t.Run("parse", func(t *testing.T) {
+		r, err := ParseOrderBy(&TestStruct{}, "name, -age")
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertDeepEqual(t, r, []string{`"name" ASC`, `"age" DESC`})
+
+		_, err = ParseOrderBy(&TestStruct{}, "name,-password")
+		testutil.AssertEqual(t, err, ErrInvalidOrderBy)
+	})
+}
+
 // env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGetUpdateSQL$ ./ssql
 func TestGetUpdateSQL(t *testing.T) {
 	tests := []struct {
@@ -217,7 +865,7 @@ func TestGetUpdateSQL(t *testing.T) {
 			whereValues:  []any{1},
 			setClauses:   []string{"name = ?", "age = ?"},
 			setValues:    []any{"John", 30, "2023-10-01"},
-			expected:     "UPDATE test_structs SET name = $1, age = $2, updated_at = $3 WHERE id = $4",
+			expected:     `UPDATE test_structs SET name = $1, age = $2, "updated_at" = $3 WHERE id = $4`,
 		},
 		{
 			name:         "struct with where clause",
@@ -226,14 +874,14 @@ func TestGetUpdateSQL(t *testing.T) {
 			whereValues:  []any{"John", 30},
 			setClauses:   []string{"name = ?", "age = ?"},
 			setValues:    []any{"John", 30, "2023-10-01"},
-			expected:     "UPDATE test_structs SET name = $1, age = $2, updated_at = $3 WHERE name = $4 AND age = $5",
+			expected:     `UPDATE test_structs SET name = $1, age = $2, "updated_at" = $3 WHERE name = $4 AND age = $5`,
 		},
 		{
 			name:       "struct with map",
 			input:      TestStructWithMap{},
 			setClauses: []string{"data = ?"},
 			setValues:  []any{map[string]string{"data": "value"}, "2023-10-01"},
-			expected:   "UPDATE test_struct_with_maps SET data = $1, updated_at = $2",
+			expected:   `UPDATE test_struct_with_maps SET data = $1, "updated_at" = $2`,
 		},
 		{
 			name:         "struct with complex set clause",
@@ -242,7 +890,7 @@ func TestGetUpdateSQL(t *testing.T) {
 			whereValues:  []any{1},
 			setClauses:   []string{"age = (age + 1)"},
 			setValues:    []any{"2023-10-01"},
-			expected:     "UPDATE test_structs SET age = (age + 1), updated_at = $1 WHERE id = $2",
+			expected:     `UPDATE test_structs SET age = (age + 1), "updated_at" = $1 WHERE id = $2`,
 		},
 	}
 
@@ -257,6 +905,67 @@ func TestGetUpdateSQL(t *testing.T) {
 	}
 }
 
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestNow$ ./ssql
+func TestNow(t *testing.T) {
+	t.Run("sentinel", func(t *testing.T) {
+		_, values := getUpdateSQL(&TestStruct{}, []string{"id = ?"}, []any{1}, []string{"name = ?"}, []any{Now})
+		testutil.AssertEqual(t, values[0], values[1])
+	})
+
+	t.Run("deprecated string", func(t *testing.T) {
+		_, values := getUpdateSQL(&TestStruct{}, []string{"id = ?"}, []any{1}, []string{"name = ?"}, []any{"NOW"})
+		testutil.AssertEqual(t, values[0], values[1])
+
+		TreatNowStringAsCurrentTime = false
+		defer func() { TreatNowStringAsCurrentTime = true }()
+		_, values = getUpdateSQL(&TestStruct{}, []string{"id = ?"}, []any{1}, []string{"name = ?"}, []any{"NOW"})
+		testutil.AssertEqual(t, values[0], any("NOW"))
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGetUpdateBulkSQL$ ./ssql
+func TestGetUpdateBulkSQL(t *testing.T) {
+	sql, values := getUpdateBulkSQL([]TestStruct{{ID: 1, Name: "a", Age: 10}, {ID: 2, Name: "b", Age: 20}}, "id", []string{"name", "age"})
+	testutil.AssertEqual(t, sql, `UPDATE test_structs AS t SET "name" = v."name", "age" = v."age", "updated_at" = $7`+
+		` FROM (SELECT "id", "name", "age" FROM test_structs WHERE false UNION ALL VALUES ($1, $2, $3), ($4, $5, $6)) AS v WHERE t."id" = v."id"`)
+	testutil.AssertDeepEqual(t, values[:6], []any{1, "a", 10, 2, "b", 20})
+
+	defer func() {
+		testutil.AssertEqual(t, recover(), "TestStruct does not have field: unknown")
+	}()
+	getUpdateBulkSQL([]TestStruct{{ID: 1}}, "id", []string{"unknown"})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestWithTable$ ./ssql
+func TestWithTable(t *testing.T) {
+	opts := []Option{WithTable("test_structs_2024_06")}
+
+	sql, _ := getQuerySQL(ormTarget(&TestStruct{}, opts), []string{"id = ?"}, []any{1}, nil, LimitOffset{})
+	testutil.AssertEqual(t, sql, "SELECT * FROM test_structs_2024_06 WHERE id = $1")
+
+	sql = getCountSQL(ormTarget(&TestStruct{}, opts), []string{"id = ?"})
+	testutil.AssertEqual(t, sql, "SELECT COUNT(*) FROM test_structs_2024_06 WHERE id = $1")
+
+	sql, _ = getInsertSQL(ormTarget(&TestStruct{Name: "a", Age: 1}, opts), generatedColumns(reflect.TypeFor[TestStruct]()))
+	testutil.AssertEqual(t, sql, `INSERT INTO test_structs_2024_06 ("name", "age") VALUES ($1, $2)`)
+
+	sql, _ = getBulkInsertSQL([]TestStruct{{Name: "a", Age: 1}}, generatedColumns(reflect.TypeFor[TestStruct]()), opts...)
+	testutil.AssertEqual(t, sql, `INSERT INTO test_structs_2024_06 ("name", "age") VALUES ($1, $2)`)
+
+	sql, _ = getUpdateSQL(ormTarget(&TestStruct{}, opts), []string{"id = ?"}, []any{1}, []string{"name = ?"}, []any{"a"})
+	testutil.AssertEqual(t, sql, `UPDATE test_structs_2024_06 SET name = $1, "updated_at" = $2 WHERE id = $3`)
+
+	sql = getDeleteSQL(ormTarget(&TestStruct{}, opts), []string{"id = ?"})
+	testutil.AssertEqual(t, sql, "DELETE FROM test_structs_2024_06 WHERE id = $1")
+
+	// 指定しない場合はモデルのテーブル名となる。
+	sql = getDeleteSQL(ormTarget(&TestStruct{}, nil), []string{"id = ?"})
+	testutil.AssertEqual(t, sql, "DELETE FROM test_structs WHERE id = $1")
+
+	sql, _ = Model(&TestStruct{}).Table("test_structs_2024_06").Where("id = ?", 1).SQL()
+	testutil.AssertEqual(t, sql, "SELECT * FROM test_structs_2024_06 WHERE (id = $1)")
+}
+
 // env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGetDeleteSQL$ ./ssql
 func TestGetDeleteSQL(t *testing.T) {
 	tests := []struct {
@@ -300,6 +1009,26 @@ func TestGetDeleteSQL(t *testing.T) {
 	}
 }
 
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGetInsertMapSQL$ ./ssql
+func TestGetInsertMapSQL(t *testing.T) {
+	sql, values := getInsertMapSQL(&TestStruct{}, map[string]any{"name": "a", "age": 1})
+	testutil.AssertEqual(t, sql, `INSERT INTO test_structs ("age", "name") VALUES ($1, $2)`)
+	testutil.AssertDeepEqual(t, values, []any{1, "a"})
+
+	sql, values = getInsertMapSQL(&TestStruct{}, map[string]any{"name": "a", "created_at": Now})
+	testutil.AssertEqual(t, sql, `INSERT INTO test_structs ("created_at", "name") VALUES ($1, $2)`)
+	_, isTime := values[0].(time.Time)
+	testutil.AssertEqual(t, isTime, true)
+
+	sql, _ = getInsertMapSQL(ormTarget(&TestStruct{}, []Option{WithTable("test_structs_2024_06")}), map[string]any{"name": "a"})
+	testutil.AssertEqual(t, sql, `INSERT INTO test_structs_2024_06 ("name") VALUES ($1)`)
+
+	defer func() {
+		testutil.AssertEqual(t, recover(), any("TestStruct does not have field: name) VALUES (1); --"))
+	}()
+	getInsertMapSQL(&TestStruct{}, map[string]any{"name) VALUES (1); --": "a"})
+}
+
 // env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGetBulkInsertSQL$ ./ssql
 func TestGetBulkInsertSQL(t *testing.T) {
 	tests := []struct {
@@ -444,6 +1173,20 @@ func TestInsertBulk(t *testing.T) {
 			t.Errorf("expected nil result, got: %v", result)
 		}
 	})
+
+	t.Run("fail_too_many_parameters", func(t *testing.T) {
+		// 上限を超えるパラメーターは送信前にerrorとなる。
+		testData := make([]TableForTest, MaxBindParameters/2+1)
+		for i := range testData {
+			testData[i] = TableForTest{Name: Ptr("many"), UID: fmt.Sprintf("many-%d", i)}
+		}
+		_, err := InsertBulk(nil, testData)
+		testutil.AssertTrue(t, errors.Is(err, ErrTooManyParameters))
+
+		n, err := Count(nil, &TableForTest{}, []string{"name = ?"}, []any{"many"}, AllowSeqScan())
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, n, int64(0))
+	})
 }
 
 // env `cat .env` go test -v -count=1 -timeout 60s -run ^TestORM$ ./ssql
@@ -592,6 +1335,353 @@ func TestORM(t *testing.T) {
 		}
 	})
 
+	t.Run("success_exists", func(t *testing.T) {
+		b, err := Exists(nil, &TableForTest{}, []string{"uid = ?"}, []any{"aaa"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertTrue(t, b)
+		b, err = Exists(nil, &TableForTest{}, []string{"uid = ?"}, []any{"bbb"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertFalse(t, b)
+	})
+
+	t.Run("success_count", func(t *testing.T) {
+		c, err := Count(nil, &TableForTest{}, []string{"uid = Any(?)"}, []any{[]string{"aaa", "bbb"}})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, c, int64(1))
+	})
+
+	t.Run("success_aggregate", func(t *testing.T) {
+		m, err := Max[*string](nil, &TableForTest{}, "uid", []string{"uid = Any(?)"}, []any{[]string{"aaa", "bbb"}})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, *m, "aaa")
+
+		m, err = Max[*string](nil, &TableForTest{}, "uid", []string{"uid = ?"}, []any{"not-exists"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, m == nil, true)
+	})
+
+	t.Run("success_pluck", func(t *testing.T) {
+		uids, err := Pluck[string](nil, &TableForTest{}, "uid", []string{"uid = Any(?)"}, []any{[]string{"aaa", "bbb"}})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertDeepEqual(t, uids, []string{"aaa"})
+	})
+
+	t.Run("success_find_keyset", func(t *testing.T) {
+		l, cursor, err := FindKeyset(nil, &TableForTest{}, "uid", nil, 1, []string{"uid = Any(?)"}, []any{[]string{"aaa", "bbb"}})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, len(l), 1)
+		// 該当するレコードが1件のみのため、次のページは無い
+		testutil.AssertEqual(t, cursor, nil)
+
+		l, cursor, err = FindKeyset(nil, &TableForTest{}, "uid", "aaa", 1, []string{"uid = Any(?)"}, []any{[]string{"aaa", "bbb"}})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, len(l), 0)
+		testutil.AssertEqual(t, cursor, nil)
+	})
+
+	t.Run("success_paginate", func(t *testing.T) {
+		p, err := Paginate(nil, &TableForTest{}, 1, 10, []string{"uid = Any(?)"}, []any{[]string{"aaa", "bbb"}}, []string{"uid"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, p.Total, int64(1))
+		testutil.AssertEqual(t, len(p.Items), 1)
+
+		p, err = Paginate(nil, &TableForTest{}, 2, 10, []string{"uid = Any(?)"}, []any{[]string{"aaa", "bbb"}}, []string{"uid"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, p.Total, int64(1))
+		testutil.AssertEqual(t, len(p.Items), 0)
+	})
+
+	t.Run("success_upsert", func(t *testing.T) {
+		result, err := InsertOrIgnore(nil, &TableForTest{UID: "aaa", Name: Ptr("ignored")}, []string{"uid"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		c, _ := result.RowsAffected()
+		testutil.AssertEqual(t, c, int64(0))
+
+		result, err = Upsert(nil, &TableForTest{UID: "upsert", Name: Ptr("new")}, []string{"uid"}, []string{"name"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		c, _ = result.RowsAffected()
+		testutil.AssertEqual(t, c, int64(1))
+
+		_, err = Upsert(nil, &TableForTest{UID: "upsert", Name: Ptr("updated")}, []string{"uid"}, []string{"name"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		r, _ := First(nil, &TableForTest{}, []string{"uid = ?"}, []any{"upsert"})
+		testutil.AssertEqual(t, *r.Name, "updated")
+	})
+
+	t.Run("success_insert_returning", func(t *testing.T) {
+		m := &TableForTest{UID: "returning", Name: Ptr("returning")}
+		r, err := InsertReturning(nil, m)
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertNotEqual(t, r.ID, uuid.Nil)
+		testutil.AssertFalse(t, r.CreatedAt.IsZero())
+		testutil.AssertEqual(t, m.UID, "returning")
+		testutil.AssertEqual(t, *m.Name, "returning")
+	})
+
+	t.Run("success_save", func(t *testing.T) {
+		m := &TableForTest{UID: "save", Name: Ptr("save")}
+		if _, err := Save(nil, m); err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertNotEqual(t, m.ID, uuid.Nil)
+
+		m.Name = Ptr("saved")
+		r, err := Save(nil, m)
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, *r.Name, "saved")
+		f, _ := First(nil, &TableForTest{}, []string{"id = ?"}, []any{m.ID})
+		testutil.AssertEqual(t, *f.Name, "saved")
+
+		r, err = Save(nil, &TableForTest{ID: uuid.New(), UID: "save-not-found"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, r == nil, true)
+	})
+
+	t.Run("success_first_or_create", func(t *testing.T) {
+		m := &TableForTest{UID: "first-or-create", Name: Ptr("created")}
+		r, created, err := FirstOrCreate(nil, m, []string{"uid = ?"}, []any{"first-or-create"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, created, true)
+		testutil.AssertNotEqual(t, r.ID, uuid.Nil)
+
+		m2 := &TableForTest{UID: "first-or-create", Name: Ptr("not created")}
+		r2, created, err := FirstOrCreate(nil, m2, []string{"uid = ?"}, []any{"first-or-create"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, created, false)
+		testutil.AssertEqual(t, r2.ID, r.ID)
+		testutil.AssertEqual(t, *r2.Name, "created")
+	})
+
+	t.Run("success_update_struct", func(t *testing.T) {
+		original, _ := First(nil, &TableForTest{}, []string{"uid = ?"}, []any{"aaa"})
+		modified := *original
+		result, err := UpdateStruct(nil, original, &modified, []string{"id = ?"}, []any{original.ID})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		c, _ := result.RowsAffected()
+		testutil.AssertEqual(t, c, int64(0))
+
+		modified.IsActive = !original.IsActive
+		result, err = UpdateStruct(nil, original, &modified, []string{"id = ?"}, []any{original.ID})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		c, _ = result.RowsAffected()
+		testutil.AssertEqual(t, c, int64(1))
+	})
+
+	t.Run("success_delete_by_ids", func(t *testing.T) {
+		org := DefaultBatchSize
+		defer func() { DefaultBatchSize = org }()
+		DefaultBatchSize = 1
+
+		ids := []uuid.UUID{}
+		for _, uid := range []string{"delete-1", "delete-2", "delete-3"} {
+			m, err := InsertReturning(nil, &TableForTest{UID: uid})
+			if err != nil {
+				t.Fatal("got error")
+			}
+			ids = append(ids, m.ID)
+		}
+		result, err := DeleteByIDs(nil, &TableForTest{}, append(ids, uuid.New()))
+		if err != nil {
+			t.Fatal("got error")
+		}
+		c, _ := result.RowsAffected()
+		testutil.AssertEqual(t, c, int64(3))
+	})
+
+	t.Run("success_find_by_id", func(t *testing.T) {
+		m, err := InsertReturning(nil, &TableForTest{UID: "find-by-id"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		r, err := FindByID(nil, &TableForTest{}, m.ID)
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, r.UID, "find-by-id")
+
+		result, err := DeleteByID(nil, &TableForTest{}, m.ID)
+		if err != nil {
+			t.Fatal("got error")
+		}
+		c, _ := result.RowsAffected()
+		testutil.AssertEqual(t, c, int64(1))
+
+		r, err = FindByID(nil, &TableForTest{}, m.ID)
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, r == nil, true)
+	})
+
+	t.Run("success_insert_map", func(t *testing.T) {
+		_, err := InsertMap(nil, &TableForTest{}, map[string]any{"uid": "insert-map", "name": "map"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		r, err := First(nil, &TableForTest{}, []string{"uid = ?"}, []any{"insert-map"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, *r.Name, "map")
+	})
+
+	t.Run("success_count_estimate", func(t *testing.T) {
+		exact, err := QueryScalar[int64](nil, "SELECT COUNT(*) FROM table_for_tests WHERE '"+DisableWhereCheckClause+"' = '"+DisableWhereCheckClause+"'")
+		if err != nil {
+			t.Fatal("got error")
+		}
+		// 件数が少ないため正確な件数となる。
+		n, err := CountEstimate(nil, &TableForTest{})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, n, exact)
+	})
+
+	t.Run("success_find_ptrs", func(t *testing.T) {
+		l, err := FindPtrs(nil, &TableForTest{}, []string{"uid = ?"}, []any{"aaa"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, len(l), 1)
+		testutil.AssertEqual(t, l[0].UID, "aaa")
+	})
+
+	t.Run("success_builder", func(t *testing.T) {
+		l, err := Model(&TableForTest{}).Where("uid = ?", "aaa").Order("created_at DESC").Limit(10).Find(context.Background())
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, len(l), 1)
+
+		c, err := Model(&TableForTest{}).Where("uid = ?", "aaa").Count(context.Background())
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, c, int64(1))
+	})
+
+	t.Run("success_builder_group_by", func(t *testing.T) {
+		type uidCount struct {
+			UID   string `database:"uid"`
+			Count int64  `database:"count"`
+		}
+		b := Model(&TableForTest{}).Select("uid", "COUNT(*) AS count").Where("uid = ?", "aaa").GroupBy("uid").Having("COUNT(*) >= ?", 1)
+		l, err := FindAs(context.Background(), b, &uidCount{})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertDeepEqual(t, l, []uidCount{{UID: "aaa", Count: 1}})
+
+		c, err := b.Count(context.Background())
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, c, int64(1))
+	})
+
+	t.Run("success_subquery", func(t *testing.T) {
+		sub := Subquery(Model(&TableForTest{}).Select("id").Where("uid = ?", "aaa"))
+		l, err := Find(nil, &TableForTest{}, []string{"id IN ?"}, []any{sub})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, len(l), 1)
+		testutil.AssertEqual(t, l[0].UID, "aaa")
+	})
+
+	t.Run("success_update_expr", func(t *testing.T) {
+		_, err := Update(nil, &TableForTest{}, []string{"uid = ?"}, []any{"aaa"}, map[string]any{"name": Expr("name || ?", "_x")})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		m, _ := First(nil, &TableForTest{}, []string{"uid = ?", "updated_at <= ?"}, []any{"aaa", Expr("now()")})
+		testutil.AssertEqual(t, strings.HasSuffix(*m.Name, "_x"), true)
+	})
+
+	t.Run("success_update_bulk", func(t *testing.T) {
+		l, err := Find(nil, &TableForTest{}, []string{"uid = ?"}, []any{"aaa"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		for i := range l {
+			name := "bulk_" + l[i].UID
+			l[i].Name = &name
+		}
+		result, err := UpdateBulk(nil, l, "id", []string{"name"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		c, _ := result.RowsAffected()
+		testutil.AssertEqual(t, c, int64(len(l)))
+		m, _ := First(nil, &TableForTest{}, []string{"uid = ?"}, []any{"aaa"})
+		testutil.AssertEqual(t, *m.Name, "bulk_aaa")
+	})
+
+	t.Run("success_update_returning", func(t *testing.T) {
+		mp := &TableForTest{}
+		l, err := UpdateReturning(nil, mp, []string{"uid = ?"}, []any{"aaa"}, map[string]any{"name": "returning"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, len(l), 1)
+		testutil.AssertEqual(t, *l[0].Name, "returning")
+		testutil.AssertEqual(t, mp.Name == nil, true)
+	})
+
+	t.Run("success_delete_returning", func(t *testing.T) {
+		name := "to_be_deleted"
+		if _, err := Insert(nil, &TableForTest{UID: "delete_returning", Name: &name}); err != nil {
+			t.Fatal("got error")
+		}
+		l, err := DeleteReturning(nil, &TableForTest{}, []string{"uid = ?"}, []any{"delete_returning"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, len(l), 1)
+		testutil.AssertEqual(t, *l[0].Name, "to_be_deleted")
+	})
+
 	t.Run("success_update", func(t *testing.T) {
 		result, err := Update(nil, &TableForTest{}, []string{"uid = ?"}, []any{"aaa"}, map[string]any{"name": "bbbbbb"})
 		if err != nil {
@@ -0,0 +1,51 @@
+package ssql
+
+// 実行計画のチェック（GuardConfig.PlanRules）のルール
+//
+// Seq Scanのチェックと同じく、enable_seqscanをoffにした状態の実行計画に対してチェックする。
+// アプリケーション固有のルールはMatchを実装して追加できる。
+//
+//	ssql.PlanRule{Name: "no hash join", Match: func(n *ssql.PlanNode) bool { return n.NodeType == "Hash Join" }}
+type PlanRule struct {
+	// panicのメッセージに含めるルールの名前
+	Name string
+	// ノードがルールに違反している場合はtrueを返す。
+	Match func(n *PlanNode) bool
+}
+
+// 推定行数がmaxRowsを超えるNested Loopを違反とする。
+// 結合条件にインデックスが無い場合や統計情報が古い場合に、行数の積に比例して遅くなる。
+func NestedLoopRowsRule(maxRows float64) PlanRule {
+	return PlanRule{
+		Name: "nested loop rows",
+		Match: func(n *PlanNode) bool {
+			return n.NodeType == "Nested Loop" && n.PlanRows > maxRows
+		},
+	}
+}
+
+// ディスクを利用した外部ソートとなるSortを違反とする。
+// 推定のデータ量（Plan Rows × Plan Width）がmaxBytes（通常はwork_memの値）を超える場合、
+// またはANALYZEの結果のソート方式が"external merge"等の場合が該当する。
+func ExternalSortRule(maxBytes float64) PlanRule {
+	return PlanRule{
+		Name: "external sort",
+		Match: func(n *PlanNode) bool {
+			if n.NodeType != "Sort" && n.NodeType != "Incremental Sort" {
+				return false
+			}
+			return StrContainWithIgnoreCase(n.SortMethod, "external") || n.PlanRows*float64(n.PlanWidth) > maxBytes
+		},
+	}
+}
+
+// Parallel Seq Scanを違反とする。
+// SeqScanRowsThreshold、SeqScanCostThresholdの指定に関わらず、並列での全件検索が必要となる大きなテーブルの検索を検出する。
+func ParallelSeqScanRule() PlanRule {
+	return PlanRule{
+		Name: "parallel seq scan",
+		Match: func(n *PlanNode) bool {
+			return n.NodeType == "Parallel Seq Scan"
+		},
+	}
+}
@@ -0,0 +1,31 @@
+package ssql
+
+import (
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestPlanRule$ ./ssql
+func TestPlanRule(t *testing.T) {
+	t.Run("nested_loop_rows", func(t *testing.T) {
+		r := NestedLoopRowsRule(1000)
+		testutil.AssertTrue(t, r.Match(&PlanNode{NodeType: "Nested Loop", PlanRows: 5000}))
+		testutil.AssertFalse(t, r.Match(&PlanNode{NodeType: "Nested Loop", PlanRows: 10}))
+		testutil.AssertFalse(t, r.Match(&PlanNode{NodeType: "Hash Join", PlanRows: 5000}))
+	})
+
+	t.Run("external_sort", func(t *testing.T) {
+		r := ExternalSortRule(4 * 1024 * 1024)
+		testutil.AssertTrue(t, r.Match(&PlanNode{NodeType: "Sort", PlanRows: 1000000, PlanWidth: 64}))
+		testutil.AssertFalse(t, r.Match(&PlanNode{NodeType: "Sort", PlanRows: 100, PlanWidth: 64}))
+		testutil.AssertTrue(t, r.Match(&PlanNode{NodeType: "Sort", PlanRows: 100, PlanWidth: 64, SortMethod: "external merge"}))
+		testutil.AssertFalse(t, r.Match(&PlanNode{NodeType: "Seq Scan", PlanRows: 1000000, PlanWidth: 64}))
+	})
+
+	t.Run("parallel_seq_scan", func(t *testing.T) {
+		r := ParallelSeqScanRule()
+		testutil.AssertTrue(t, r.Match(&PlanNode{NodeType: "Parallel Seq Scan"}))
+		testutil.AssertFalse(t, r.Match(&PlanNode{NodeType: "Seq Scan"}))
+	})
+}
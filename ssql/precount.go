@@ -0,0 +1,112 @@
+package ssql
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// デバッグモードで、UPDATE、DELETEの実行前にWHEREに一致する行数を数え、
+// GuardConfig.MaxAffectedRowsを超える場合はpanic（または警告）とする。
+// "WHERE tenant_id = $1"のように、条件の不足によって大量の行を更新してしまう誤りを検出する。
+//
+// 件数を数えるSQLを組み立てられない文（WITH句、UPDATE ... FROM、DELETE ... USING等）はチェックしない。
+func checkAffectedRowsOnDebug(ctx context.Context, tx Executor, query string, args []any, opt *options) {
+	max := opt.guard.MaxAffectedRows
+	if !IsDebugMode() || max <= 0 {
+		return
+	}
+	countQuery, countArgs, ok := preCountSQL(query, args)
+	if !ok {
+		return
+	}
+	var n int64
+	if err := getExecutor(tx).QueryRowContext(ctx, countQuery, countArgs...).Scan(&n); err != nil {
+		panic(fmt.Sprintf("query failed: %s, failed query: %s", err, countQuery))
+	}
+	if n <= int64(max) {
+		return
+	}
+	if opt.guard.MaxAffectedRowsWarnOnly {
+		l.Warn(ctx, fmt.Sprintf(PanicTooManyAffectedRows, n, max, query))
+		return
+	}
+	reportGuardViolation(ctx, fmt.Sprintf(PanicTooManyAffectedRows, n, max, query))
+}
+
+// UPDATE、DELETEの対象となる行数を数えるSELECT文と、その引数を返す。
+// 組み立てられない場合はokがfalseとなる。（プレースホルダーはcheckPlaceholdersでチェック済みであること）
+func preCountSQL(query string, args []any) (countQuery string, countArgs []any, ok bool) {
+	s := analyzeStatement(query)
+	if len(s.statementKeywords()) != 1 {
+		return "", nil, false
+	}
+	// 最上位（括弧の外）のキーワードの位置
+	top := map[string]int{}
+	depth := 0
+	for i, t := range s.tokens {
+		switch {
+		case t.text == "(":
+			depth++
+		case t.text == ")":
+			depth--
+		case depth == 0 && t.kind == tokenWord:
+			k := strings.ToUpper(t.text)
+			if _, ok := top[k]; !ok {
+				top[k] = i
+			}
+		}
+	}
+	where, ok := top["WHERE"]
+	if !ok || s.isKeyword(where+1, "CURRENT") {
+		return "", nil, false
+	}
+
+	var targetStart, targetEnd int
+	switch s.kind {
+	case "UPDATE":
+		set, ok := top["SET"]
+		if !ok || s.tokens[0].kind != tokenWord || !strings.EqualFold(s.tokens[0].text, "UPDATE") {
+			return "", nil, false
+		}
+		// UPDATE ... FROMは結合により行数が変わるため対象外
+		if from, ok := top["FROM"]; ok && from < where {
+			return "", nil, false
+		}
+		targetStart, targetEnd = s.tokens[1].pos, s.tokens[set].pos
+	case "DELETE":
+		from, ok := top["FROM"]
+		if !ok || from != 1 || !strings.EqualFold(s.tokens[0].text, "DELETE") {
+			return "", nil, false
+		}
+		if using, ok := top["USING"]; ok && using < where {
+			return "", nil, false
+		}
+		targetStart, targetEnd = s.tokens[from+1].pos, s.tokens[where].pos
+	default:
+		return "", nil, false
+	}
+
+	condEnd := len(query)
+	if returning, ok := top["RETURNING"]; ok && returning > where {
+		condEnd = s.tokens[returning].pos
+	}
+	for _, t := range s.tokens[where:] {
+		if t.text == ";" {
+			condEnd = min(condEnd, t.pos)
+			break
+		}
+	}
+	// WHERE内で使われない引数はPostgreSQLで型を決定できずにエラーとなるため、出現順に振り直して含めない。
+	numbers := map[int]string{}
+	cond := renumberPlaceholders(query[s.tokens[where].pos+len("WHERE"):condEnd], func(n int) string {
+		if _, ok := numbers[n]; !ok {
+			countArgs = append(countArgs, args[n-1])
+			numbers[n] = "$" + strconv.Itoa(len(countArgs))
+		}
+		return numbers[n]
+	})
+	target := strings.TrimSpace(query[targetStart:targetEnd])
+	return "SELECT COUNT(*) FROM " + target + " WHERE " + strings.TrimSpace(cond), countArgs, true
+}
@@ -0,0 +1,68 @@
+package ssql
+
+import (
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestPreCountSQL$ ./ssql
+func TestPreCountSQL(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		query        string
+		args         []any
+		expected     string
+		expectedArgs []any
+		ok           bool
+	}{
+		{
+			name:         "update",
+			query:        "UPDATE users SET name = $1, updated_at = now() WHERE tenant_id = $2",
+			args:         []any{"a", 1},
+			expected:     "SELECT COUNT(*) FROM users WHERE tenant_id = $1",
+			expectedArgs: []any{1},
+			ok:           true,
+		},
+		{
+			name:         "update with alias and returning",
+			query:        `UPDATE "users" AS u SET name = $2 WHERE u.id = ANY($1) AND u.name <> $2 RETURNING *`,
+			args:         []any{[]int{1, 2}, "a"},
+			expected:     `SELECT COUNT(*) FROM "users" AS u WHERE u.id = ANY($1) AND u.name <> $2`,
+			expectedArgs: []any{[]int{1, 2}, "a"},
+			ok:           true,
+		},
+		{
+			name:         "delete",
+			query:        "DELETE FROM users WHERE created_at < $1 AND note <> 'RETURNING'",
+			args:         []any{"2024-01-01"},
+			expected:     "SELECT COUNT(*) FROM users WHERE created_at < $1 AND note <> 'RETURNING'",
+			expectedArgs: []any{"2024-01-01"},
+			ok:           true,
+		},
+		{
+			name:         "subquery in where",
+			query:        "DELETE FROM logs WHERE user_id IN (SELECT id FROM users WHERE tenant_id = $1)",
+			args:         []any{1},
+			expected:     "SELECT COUNT(*) FROM logs WHERE user_id IN (SELECT id FROM users WHERE tenant_id = $1)",
+			expectedArgs: []any{1},
+			ok:           true,
+		},
+		{name: "update from", query: "UPDATE users SET name = t.name FROM tmp t WHERE users.id = t.id", ok: false},
+		{name: "delete using", query: "DELETE FROM users USING banned b WHERE users.id = b.id", ok: false},
+		{name: "with", query: "WITH t AS (SELECT 1) DELETE FROM users WHERE id IN (SELECT * FROM t)", ok: false},
+		{name: "insert", query: "INSERT INTO users (name) VALUES ($1)", args: []any{"a"}, ok: false},
+		{name: "without where", query: "DELETE FROM users", ok: false},
+		{name: "multiple statements", query: "DELETE FROM users WHERE id = 1; DELETE FROM logs WHERE id = 1", ok: false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			q, args, ok := preCountSQL(tt.query, tt.args)
+			testutil.AssertEqual(t, ok, tt.ok)
+			if !tt.ok {
+				return
+			}
+			testutil.AssertEqual(t, q, tt.expected)
+			testutil.AssertDeepEqual(t, args, tt.expectedArgs)
+		})
+	}
+}
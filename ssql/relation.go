@@ -0,0 +1,260 @@
+package ssql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// リレーションの種類
+// relationタグで指定する。
+//
+//	type User struct {
+//		ID     uuid.UUID `database:"id"`
+//		Orders []Order   `relation:"has_many,foreign_key=user_id"`
+//	}
+//
+//	type Order struct {
+//		ID     uuid.UUID `database:"id"`
+//		UserID uuid.UUID `database:"user_id"`
+//		User   *User     `relation:"belongs_to,foreign_key=user_id"`
+//	}
+//
+// 参照先のカラムはreferencesで指定できる。（省略時は"id"）
+// relationタグのフィールドはカラムとしては扱わず、Preloadでのみ値がセットされる。
+const (
+	// 子のモデルのスライスのフィールドに指定する。foreign_keyは子のモデルのカラム。
+	RelationHasMany = "has_many"
+	// 親のモデル（またはそのポインタ）のフィールドに指定する。foreign_keyは自身のカラム。
+	RelationBelongsTo = "belongs_to"
+)
+
+type relation struct {
+	kind       string
+	foreignKey string
+	references string
+	// 関連先のモデルの型
+	target reflect.Type
+	index  int
+}
+
+func parseRelation(rt reflect.Type, name string) relation {
+	f, ok := rt.FieldByName(name)
+	if !ok || f.Tag.Get("relation") == "" {
+		panic(fmt.Sprintf("%s does not have relation: %s", rt.Name(), name))
+	}
+	parts := strings.Split(f.Tag.Get("relation"), ",")
+	r := relation{kind: strings.TrimSpace(parts[0]), references: "id", index: f.Index[0]}
+	for _, p := range parts[1:] {
+		k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		switch k {
+		case "foreign_key":
+			r.foreignKey = v
+		case "references":
+			r.references = v
+		}
+	}
+	if r.foreignKey == "" {
+		panic(fmt.Sprintf("relation %s must have foreign_key", name))
+	}
+
+	switch r.kind {
+	case RelationHasMany:
+		if f.Type.Kind() != reflect.Slice {
+			panic(fmt.Sprintf("has_many relation %s must be slice", name))
+		}
+		r.target = f.Type.Elem()
+	case RelationBelongsTo:
+		r.target = f.Type
+		if r.target.Kind() == reflect.Ptr {
+			r.target = r.target.Elem()
+		}
+	default:
+		panic(fmt.Sprintf("invalid relation: %s", r.kind))
+	}
+	return r
+}
+
+// itemsのrelationタグのフィールドに関連するレコードを読み込む。
+// リレーションごとに"= ANY($1)"で1回（キーが多い場合はDefaultBatchSize件ごと）クエリを実行するため、
+// レコードごとにクエリを実行するN+1問題を避けられる。
+//
+// 関連先のモデルに論理削除のカラムがある場合は、論理削除されたレコードは除外する。
+//
+//	users, err := ssql.Find(tx, &User{}, where, values)
+//	err = ssql.Preload(tx, users, "Orders")
+func Preload[M any](tx Executor, items []M, names ...string) error {
+	return preload(context.Background(), tx, items, names...)
+}
+
+func preload[M any](c context.Context, tx Executor, items []M, names ...string) error {
+	if len(items) == 0 {
+		return nil
+	}
+	rv := reflect.ValueOf(items)
+	rt := rv.Type().Elem()
+	for _, name := range names {
+		r := parseRelation(rt, name)
+		var err error
+		switch r.kind {
+		case RelationHasMany:
+			err = preloadHasMany(c, tx, rv, rt, r)
+		case RelationBelongsTo:
+			err = preloadBelongsTo(c, tx, rv, rt, r)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func preloadHasMany(c context.Context, tx Executor, items reflect.Value, rt reflect.Type, r relation) error {
+	parentKey := columnIndex(rt, r.references)
+	childKey := columnIndex(r.target, r.foreignKey)
+
+	keys := []any{}
+	for i := range items.Len() {
+		if k, ok := keyOf(items.Index(i).Field(parentKey)); ok {
+			keys = append(keys, k)
+		}
+	}
+	children, err := queryRelated(c, tx, r.target, r.foreignKey, keys)
+	if err != nil {
+		return err
+	}
+
+	grouped := map[any][]reflect.Value{}
+	for _, child := range children {
+		if k, ok := keyOf(child.Field(childKey)); ok {
+			grouped[k] = append(grouped[k], child)
+		}
+	}
+	for i := range items.Len() {
+		item := items.Index(i)
+		l := reflect.MakeSlice(item.Field(r.index).Type(), 0, 0)
+		if k, ok := keyOf(item.Field(parentKey)); ok {
+			l = reflect.Append(l, grouped[k]...)
+		}
+		item.Field(r.index).Set(l)
+	}
+	return nil
+}
+
+func preloadBelongsTo(c context.Context, tx Executor, items reflect.Value, rt reflect.Type, r relation) error {
+	foreignKey := columnIndex(rt, r.foreignKey)
+	parentKey := columnIndex(r.target, r.references)
+
+	keys := []any{}
+	for i := range items.Len() {
+		if k, ok := keyOf(items.Index(i).Field(foreignKey)); ok && !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+	parents, err := queryRelated(c, tx, r.target, r.references, keys)
+	if err != nil {
+		return err
+	}
+
+	byKey := map[any]reflect.Value{}
+	for _, parent := range parents {
+		if k, ok := keyOf(parent.Field(parentKey)); ok {
+			byKey[k] = parent
+		}
+	}
+	for i := range items.Len() {
+		item := items.Index(i)
+		field := item.Field(r.index)
+		field.Set(reflect.Zero(field.Type()))
+		k, ok := keyOf(item.Field(foreignKey))
+		if !ok {
+			continue
+		}
+		parent, ok := byKey[k]
+		if !ok {
+			continue
+		}
+		if field.Kind() == reflect.Ptr {
+			p := reflect.New(r.target)
+			p.Elem().Set(parent)
+			field.Set(p)
+		} else {
+			field.Set(parent)
+		}
+	}
+	return nil
+}
+
+// columnの値がkeysのいずれかに一致するレコードを取得する。
+func queryRelated(c context.Context, tx Executor, rt reflect.Type, column string, keys []any) ([]reflect.Value, error) {
+	r := []reflect.Value{}
+	if len(keys) == 0 {
+		return r, nil
+	}
+	model := reflect.New(rt).Interface()
+	query, _ := getQuerySQL(model, scopeSoftDelete(model, []string{`"` + column + `" = ANY(?)`}), nil, nil, LimitOffset{})
+	debugSQL(query, []any{keys})
+
+	_, opt := splitArgs(nil)
+	for chunk := range slices.Chunk(keys, DefaultBatchSize) {
+		// ドライバーが配列として扱えるように、キーの型のスライスに変換する。
+		arg := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(chunk[0])), 0, len(chunk))
+		for _, k := range chunk {
+			arg = reflect.Append(arg, reflect.ValueOf(k))
+		}
+		args := []any{arg.Interface()}
+
+		rows, err := queryRows(c, tx, query, args, opt)
+		if err != nil {
+			return nil, err
+		}
+		err = func() error {
+			defer rows.Close()
+			columns, err := rows.Columns()
+			if err != nil {
+				panic(err)
+			}
+			for rows.Next() {
+				v := reflect.New(rt).Elem()
+				if err := rows.Scan(structScanTargets(v, columns, opt.columnMapping)...); err != nil {
+					panic(err)
+				}
+				r = append(r, v)
+			}
+			if err := rows.Err(); err != nil {
+				if e := isAssumedSQLError(err); e != nil {
+					return e
+				}
+				panic(err)
+			}
+			return nil
+		}()
+		if err != nil {
+			return nil, err
+		}
+		checkSeqScanOnDebug(tx, query, args, opt)
+	}
+	return r, nil
+}
+
+func columnIndex(rt reflect.Type, column string) int {
+	for i, tag := range columnFields(rt) {
+		if tag.Column == column {
+			return i
+		}
+	}
+	panic(fmt.Sprintf("%s does not have field: %s", rt.Name(), column))
+}
+
+// 関連付けに利用するキーの値を返す。ポインタの場合は参照先の値とし、nilの場合はokがfalseとなる。
+func keyOf(v reflect.Value) (any, bool) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	return v.Interface(), true
+}
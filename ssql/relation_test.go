@@ -0,0 +1,65 @@
+package ssql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/megur0/testutil"
+)
+
+type testRelationUser struct {
+	ID     uuid.UUID           `database:"id"`
+	Orders []testRelationOrder `relation:"has_many,foreign_key=user_id"`
+}
+
+type testRelationOrder struct {
+	ID     uuid.UUID         `database:"id"`
+	UserID uuid.UUID         `database:"user_id"`
+	User   *testRelationUser `relation:"belongs_to,foreign_key=user_id,references=id"`
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestParseRelation$ ./ssql
+func TestParseRelation(t *testing.T) {
+	t.Run("has_many", func(t *testing.T) {
+		r := parseRelation(reflect.TypeFor[testRelationUser](), "Orders")
+		testutil.AssertEqual(t, r.kind, RelationHasMany)
+		testutil.AssertEqual(t, r.foreignKey, "user_id")
+		testutil.AssertEqual(t, r.references, "id")
+		testutil.AssertEqual(t, r.target, reflect.TypeFor[testRelationOrder]())
+	})
+
+	t.Run("belongs_to", func(t *testing.T) {
+		r := parseRelation(reflect.TypeFor[testRelationOrder](), "User")
+		testutil.AssertEqual(t, r.kind, RelationBelongsTo)
+		testutil.AssertEqual(t, r.foreignKey, "user_id")
+		testutil.AssertEqual(t, r.target, reflect.TypeFor[testRelationUser]())
+	})
+
+	t.Run("relation field is not column", func(t *testing.T) {
+		columns := []string{}
+		for _, tag := range columnFields(reflect.TypeFor[testRelationOrder]()) {
+			columns = append(columns, tag.Column)
+		}
+		testutil.AssertDeepEqual(t, columns, []string{"id", "user_id"})
+	})
+
+	t.Run("unknown relation", func(t *testing.T) {
+		defer func() {
+			testutil.AssertEqual(t, recover(), "testRelationUser does not have relation: ID")
+		}()
+		parseRelation(reflect.TypeFor[testRelationUser](), "ID")
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestKeyOf$ ./ssql
+func TestKeyOf(t *testing.T) {
+	id := uuid.New()
+	k, ok := keyOf(reflect.ValueOf(&id))
+	testutil.AssertEqual(t, ok, true)
+	testutil.AssertEqual(t, k, any(id))
+
+	var nilID *uuid.UUID
+	_, ok = keyOf(reflect.ValueOf(nilID))
+	testutil.AssertEqual(t, ok, false)
+}
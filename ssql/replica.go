@@ -0,0 +1,63 @@
+package ssql
+
+import (
+	"database/sql"
+	"sync/atomic"
+	"time"
+)
+
+// 読み取り専用のレプリカ
+// 設定した場合、txにnilを指定したQuery、QueryFirst、Find等の読み取りはレプリカへ振り分けられる。
+// ReadTransactionもレプリカで実行される。
+// Exec、Transaction、ロッキングリード、txを指定した読み取りは従来通りDB（プライマリ）で実行される。
+//
+// 起動時にDBと合わせて設定し、実行中には変更しないこと。
+var Replicas []*sql.DB
+
+// 書き込み後、この時間が経過するまでは読み取りもプライマリで実行する。
+// レプリケーションの遅延によって、書き込んだ内容が読み取れない事を避けるため。
+// 0の場合はこの制御を行わない。
+var ReplicaStickyWindow = 1 * time.Second
+
+// 最後に書き込みを行った時刻（UnixNano）
+var lastWriteAt atomic.Int64
+
+// ラウンドロビンでレプリカを選択するためのカウンタ
+var replicaCounter atomic.Uint64
+
+// 書き込みを行ったことを記録する。
+func markWrite() {
+	if len(Replicas) > 0 {
+		lastWriteAt.Store(time.Now().UnixNano())
+	}
+}
+
+// 読み取りに利用するExecutorを返す。
+// txが指定されている場合はそのまま返す。
+// txを指定せずにPreparedを利用した場合は、振り分け先のDB上でプリペアする。
+func getReadExecutor(tx Executor, query string, opt *options) Executor {
+	if p, ok := tx.(*preparedExecutor); ok && p.tx == nil {
+		return &preparedExecutor{tx: getReadExecutor(nil, query, opt)}
+	}
+	if tx != nil || len(Replicas) == 0 || opt.primary {
+		return getExecutor(tx)
+	}
+	// ロッキングリードはプライマリでのみ可能。
+	if analyzeStatement(query).hasLockingClause() {
+		return DB
+	}
+	return readDB()
+}
+
+// 読み取りに利用するDBを返す。
+// レプリカが無い場合、または直前に書き込みを行った場合はプライマリを返す。
+func readDB() *sql.DB {
+	if len(Replicas) == 0 {
+		return DB
+	}
+	if ReplicaStickyWindow > 0 && time.Since(time.Unix(0, lastWriteAt.Load())) < ReplicaStickyWindow {
+		return DB
+	}
+	n := replicaCounter.Add(1)
+	return Replicas[int(n%uint64(len(Replicas)))]
+}
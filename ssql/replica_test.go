@@ -0,0 +1,81 @@
+package ssql
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestGetReadExecutor$ ./ssql
+func TestGetReadExecutor(t *testing.T) {
+	primary := &sql.DB{}
+	replica1 := &sql.DB{}
+	replica2 := &sql.DB{}
+
+	orgDB, orgReplicas, orgWindow := DB, Replicas, ReplicaStickyWindow
+	defer func() {
+		DB, Replicas, ReplicaStickyWindow = orgDB, orgReplicas, orgWindow
+		lastWriteAt.Store(0)
+	}()
+	DB = primary
+	lastWriteAt.Store(0)
+
+	query := "SELECT * FROM users WHERE id = $1"
+
+	t.Run("no replicas", func(t *testing.T) {
+		Replicas = nil
+		_, opt := splitArgs(nil)
+		testutil.AssertEqual(t, getReadExecutor(nil, query, opt), Executor(primary))
+	})
+
+	Replicas = []*sql.DB{replica1, replica2}
+
+	t.Run("round robin", func(t *testing.T) {
+		_, opt := splitArgs(nil)
+		e1 := getReadExecutor(nil, query, opt)
+		e2 := getReadExecutor(nil, query, opt)
+		testutil.AssertEqual(t, e1 != e2, true)
+		testutil.AssertEqual(t, e1 == Executor(replica1) || e1 == Executor(replica2), true)
+		testutil.AssertEqual(t, e2 == Executor(replica1) || e2 == Executor(replica2), true)
+	})
+
+	t.Run("tx is specified", func(t *testing.T) {
+		_, opt := splitArgs(nil)
+		tx := &sql.Tx{}
+		testutil.AssertEqual(t, getReadExecutor(tx, query, opt), Executor(tx))
+	})
+
+	t.Run("with primary", func(t *testing.T) {
+		_, opt := splitArgs([]any{WithPrimary()})
+		testutil.AssertEqual(t, getReadExecutor(nil, query, opt), Executor(primary))
+	})
+
+	t.Run("locking read", func(t *testing.T) {
+		_, opt := splitArgs(nil)
+		testutil.AssertEqual(t, getReadExecutor(nil, query+" FOR UPDATE NOWAIT", opt), Executor(primary))
+		testutil.AssertEqual(t, getReadExecutor(nil, query+"\nFOR UPDATE", opt), Executor(primary))
+		testutil.AssertEqual(t, getReadExecutor(nil, query+"\tFOR NO KEY UPDATE", opt), Executor(primary))
+		testutil.AssertEqual(t, getReadExecutor(nil, "SELECT * FROM users WHERE note = ' FOR SHARE'", opt) != Executor(primary), true)
+	})
+
+	t.Run("prepared without tx", func(t *testing.T) {
+		_, opt := splitArgs(nil)
+		e := unwrapPrepared(getReadExecutor(Prepared(nil), query, opt))
+		testutil.AssertEqual(t, e == Executor(replica1) || e == Executor(replica2), true)
+
+		tx := &sql.Tx{}
+		testutil.AssertEqual(t, unwrapPrepared(getReadExecutor(Prepared(tx), query, opt)) == Executor(tx), true)
+	})
+
+	t.Run("sticky after write", func(t *testing.T) {
+		_, opt := splitArgs(nil)
+		ReplicaStickyWindow = time.Minute
+		markWrite()
+		testutil.AssertEqual(t, getReadExecutor(nil, query, opt), Executor(primary))
+
+		ReplicaStickyWindow = 0
+		testutil.AssertEqual(t, getReadExecutor(nil, query, opt) != Executor(primary), true)
+	})
+}
@@ -0,0 +1,111 @@
+package ssql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// 一時的なエラーが発生した場合の再試行の設定
+//
+// IsRetryableを満たすエラー（デッドロック、シリアライゼーションの失敗、コネクションの切断等）が発生した場合に、
+// ジッターを加えた指数バックオフで待機した上で再試行する。
+// txを指定した呼び出しはトランザクション自体が失敗しているため再試行しない。
+type RetryPolicy struct {
+	// 最初の実行を含めた最大の試行回数
+	MaxAttempts int
+	// 1回目の再試行までの待機時間の基準値。再試行ごとに2倍となる。
+	BaseDelay time.Duration
+	// 待機時間の上限
+	MaxDelay time.Duration
+}
+
+// txにnilを指定したQuery、Exec等に適用する再試行の設定
+// nilの場合は再試行しない。
+var DefaultRetryPolicy *RetryPolicy
+
+// attempt回目の試行が失敗した後の待機時間を返す。（Full Jitter）
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d)
+}
+
+// 再試行によって成功する可能性のあるエラーかどうかを返す。
+//
+// デッドロック、シリアライゼーションの失敗、ロック待ちのタイムアウト、接続の失敗（ErrConnUnavailable）が対象となる。
+// Query、Exec等が返したerrorとドライバーが返したerrorのいずれにも利用できる。
+// WithRetryやTransactionWithRetryによる再試行もこの関数で判定している。
+//
+// 再試行はトランザクション全体で行う必要がある。（トランザクション内の1文のみを再実行しないこと）
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	// ドライバーのerrorの場合はSQLErrorに変換して判定する。
+	if e := isAssumedSQLError(err); e != nil {
+		err = e
+	}
+	return errors.Is(err, ErrDeadLock) || errors.Is(err, ErrSerializationFailure) || errors.Is(err, ErrLockNotAvailable) ||
+		errors.Is(err, ErrConnUnavailable)
+}
+
+// 一時的なエラーの場合はポリシーに従ってfnを再試行する。
+func retry[T any](ctx context.Context, tx Executor, opt *options, fn func() (T, error)) (T, error) {
+	p := opt.retryPolicy
+	for attempt := 1; ; attempt++ {
+		v, err := fn()
+		if err == nil || unwrapPrepared(tx) != nil || p == nil || attempt >= p.MaxAttempts || !IsRetryable(err) {
+			return v, err
+		}
+		t := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return v, err
+		case <-t.C:
+		}
+	}
+}
+
+// トランザクション全体を再試行する。
+// 無名関数がIsRetryableを満たすerror（ErrSerializationFailure、ErrDeadLock等）を返した場合（コミット時に発生した場合を含む）は、
+// ロールバックした上でポリシーに従って新しいトランザクションで無名関数を再実行する。
+// SERIALIZABLEのトランザクションでは、シリアライゼーションの失敗に対する再実行が前提となる。
+//
+//	err := ssql.TransactionWithRetry(c, &ssql.TxOptions{Isolation: sql.LevelSerializable},
+//		ssql.RetryPolicy{MaxAttempts: 5, BaseDelay: 10 * time.Millisecond, MaxDelay: time.Second},
+//		func(tx *sql.Tx) error {
+//			...
+//		})
+//
+// 無名関数は複数回実行される可能性があるため、DB以外への副作用（外部APIの呼び出し等）を含めないこと。
+// 再試行の上限に達した場合は、最後に発生したerrorを返す。
+func TransactionWithRetry(c context.Context, opts *TxOptions, p RetryPolicy, f func(*sql.Tx) error) error {
+	return retryTransaction(c, p, func() error {
+		return TransactionWithOptions(c, opts, f)
+	})
+}
+
+func retryTransaction(c context.Context, p RetryPolicy, run func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := run()
+		if err == nil || attempt >= p.MaxAttempts || !IsRetryable(err) {
+			return err
+		}
+		t := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-c.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
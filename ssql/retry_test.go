@@ -0,0 +1,156 @@
+package ssql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestRetry$ ./ssql
+func TestRetry(t *testing.T) {
+	deadlock := &pgconn.PgError{Code: PostgresErrCodeDeadLock, Message: "deadlock detected"}
+	syntax := &pgconn.PgError{Code: "42601", Message: "syntax error"}
+
+	run := func(tx Executor, p *RetryPolicy, errs ...error) (int, error) {
+		opt := &options{retryPolicy: p}
+		n := 0
+		_, err := retry(context.Background(), tx, opt, func() (int, error) {
+			n++
+			if n <= len(errs) {
+				return 0, errs[n-1]
+			}
+			return n, nil
+		})
+		return n, err
+	}
+	p := &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+	t.Run("succeeds after transient errors", func(t *testing.T) {
+		n, err := run(nil, p, deadlock, driver.ErrBadConn)
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, n, 3)
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		n, err := run(nil, p, deadlock, deadlock, deadlock, deadlock)
+		testutil.AssertEqual(t, err, deadlock)
+		testutil.AssertEqual(t, n, 3)
+	})
+
+	t.Run("not transient", func(t *testing.T) {
+		n, err := run(nil, p, syntax)
+		testutil.AssertEqual(t, err, syntax)
+		testutil.AssertEqual(t, n, 1)
+	})
+
+	t.Run("no policy", func(t *testing.T) {
+		n, err := run(nil, nil, deadlock)
+		testutil.AssertEqual(t, err, deadlock)
+		testutil.AssertEqual(t, n, 1)
+	})
+
+	t.Run("in transaction", func(t *testing.T) {
+		n, err := run(&sql.Tx{}, p, deadlock)
+		testutil.AssertEqual(t, err, deadlock)
+		testutil.AssertEqual(t, n, 1)
+	})
+
+	t.Run("prepared", func(t *testing.T) {
+		n, err := run(Prepared(nil), p, deadlock)
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, n, 2)
+
+		n, err = run(Prepared(&sql.Tx{}), p, deadlock)
+		testutil.AssertEqual(t, err, deadlock)
+		testutil.AssertEqual(t, n, 1)
+	})
+
+	t.Run("backoff is capped", func(t *testing.T) {
+		for attempt := 1; attempt < 10; attempt++ {
+			d := p.backoff(attempt)
+			testutil.AssertEqual(t, d >= 0 && d < p.MaxDelay, true)
+		}
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestRetryTransaction$ ./ssql
+func TestRetryTransaction(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+	other := errors.New("other")
+
+	run := func(c context.Context, errs ...error) (int, error) {
+		n := 0
+		err := retryTransaction(c, p, func() error {
+			n++
+			if n <= len(errs) {
+				return errs[n-1]
+			}
+			return nil
+		})
+		return n, err
+	}
+
+	t.Run("succeeds after serialization failure", func(t *testing.T) {
+		n, err := run(context.Background(), ErrSerializationFailure, fmt.Errorf("wrapped: %w", ErrDeadLock))
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, n, 3)
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		n, err := run(context.Background(), ErrSerializationFailure, ErrSerializationFailure, ErrSerializationFailure)
+		testutil.AssertEqual(t, err, ErrSerializationFailure)
+		testutil.AssertEqual(t, n, 3)
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		n, err := run(context.Background(), other)
+		testutil.AssertEqual(t, err, other)
+		testutil.AssertEqual(t, n, 1)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		cancel()
+		n, err := run(c, ErrSerializationFailure)
+		testutil.AssertEqual(t, err, ErrSerializationFailure)
+		testutil.AssertEqual(t, n, 1)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestIsRetryable$ ./ssql
+func TestIsRetryable(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"nil", nil, false},
+		{"deadlock", &pgconn.PgError{Code: PostgresErrCodeDeadLock}, true},
+		{"serialization failure", &pgconn.PgError{Code: PostgresErrCodeSerializationFailure}, true},
+		{"lock not available", &pgconn.PgError{Code: PostgresErrCodeLockNotAvailable}, true},
+		{"uniq constraint", &pgconn.PgError{Code: PostgresErrCodeUniqConstraint}, false},
+		{"syntax error", &pgconn.PgError{Code: "42601"}, false},
+		{"mapped error", queryError(&pgconn.PgError{Code: PostgresErrCodeSerializationFailure}, "UPDATE users SET name = $1", []any{"a"}, time.Now()), true},
+		{"sentinel", fmt.Errorf("wrapped: %w", ErrDeadLock), true},
+		{"bad conn", driver.ErrBadConn, true},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"connection reset message", errors.New("read tcp: connection reset by peer"), true},
+		{"connection refused", fmt.Errorf("dial: %w", syscall.ECONNREFUSED), true},
+		{"admin shutdown", &pgconn.PgError{Code: PostgresErrCodeAdminShutdown}, true},
+		{"conn unavailable", fmt.Errorf("wrapped: %w", ErrConnUnavailable), true},
+		{"canceled", context.Canceled, false},
+		{"other", errors.New("other"), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.AssertEqual(t, IsRetryable(tt.err), tt.expected)
+		})
+	}
+}
@@ -3,11 +3,12 @@ package ssql
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 )
@@ -21,27 +22,72 @@ const (
 	MODE_DEBUG      = "debug"
 )
 
-// デバッグモードの際にSQLのExpalinをチェックして"Seq Scan"を含む場合にpanicとさせる。
-// これを利用することでインデックスの設定漏れを回避できる。
-var UseSeqScanCheck = true
+// 接続先のデータベースの種類
+// SQLiteはリポジトリのユニットテスト等の軽量な用途を想定しており、
+// PostgreSQL固有のチェック（Seq Scanのチェック、ロッキングリードのNOWAITのチェック）は行わない。
+// ドライバーは利用側でimportしてDBへセットする。
+var Dialect = DIALECT_POSTGRES
+
+const (
+	DIALECT_POSTGRES = "postgres"
+	DIALECT_SQLITE   = "sqlite"
+)
+
+func IsPostgres() bool {
+	if Dialect == DIALECT_POSTGRES {
+		return true
+	} else if Dialect == DIALECT_SQLITE {
+		return false
+	} else {
+		panic("invalid Dialect")
+	}
+}
 
 // Seq Scanのチェックを個別に外したい場合は、以下のようにする。
 // WHERE 'seq scan check disable'='seq scan check disable' AND (以降条件文)
+//
+// Deprecated: SQLを変更せずにチェックを外せるAllowSeqScanを利用すること。
 const SeqScanCheckDisableClause = "seq scan check disable"
 
-// デバッグモードの際にWHEREが含まれない検索をpanicとさせる。
-// これによってデータの全検索を回避する。
-var UseWhereCheck = true
-
 // WHEREのチェックを個別に外したい場合は、以下のようにする。
 // WHERE 'where check disable'='where check disable' AND (以降条件文)
+//
+// Deprecated: SQLを変更せずにチェックを外せるAllowNoWhereを利用すること。
 const DisableWhereCheckClause = "where check disable"
 
-// FOR SELECTやFOR UPDATEの際はNOWAITが付与されている事を矯正する
-var ForceNowaitOnLockingRead = true
+// 作成日時と更新日時のカラム名
+// ORMはInsertの際にこれらのカラムをセットせずデータベース側のデフォルト値に委ね、
+// Updateの際は更新日時のカラムに現在時刻をセットする。
+// モデルごとに変更する場合は"_"のフィールドのタグで指定する。（"-"の場合はそのモデルでは扱わない）
+//
+//	_ struct{} `updated_at:"modified_at"`
+//
+// 空文字にした場合はすべてのモデルでそのカラムを扱わない。
+var (
+	CreatedAtColumn = "created_at"
+	UpdatedAtColumn = "updated_at"
+)
+
+// trueの場合、SQLのチェック（WHERE句の必須化、プレースホルダーの個数等）に違反した際に
+// panicではなく*GuardError（ErrGuardViolation）を返す。
+// Webサービス等で、各ハンドラーでrecoverせずにエラーとして扱いたい場合に利用する。
+// 対象はQuery、Exec等の実行前のチェック（GuardConfig.SeqScanCheckBeforeExecの場合のSeq Scanのチェックを含む）のみで、
+// 実行後のSeq Scanのチェック、MaxAffectedRowsのチェック、ORMのWHEREのチェックはpanicとなる。
+// GuardViolationLogOnlyがtrueの場合はそちらが優先され、errorとせずに警告の出力のみとなる。
+//
+// プロセス全体で共有される値で、WithGuardConfigを指定しない呼び出しに適用される。
+// 呼び出しごとに指定する場合はGuardConfig.ViolationAsErrorをWithGuardConfigで指定すること。
+var GuardViolationAsError = false
 
-// UPDATE文の際は"updated_at"が含まれている事を強制する
-var ForceUpdatedAtCheck = true
+// trueの場合、SQLのチェックに違反してもpanicやerrorとせず、スタックトレースを含めてLoggerで警告を出力して実行を続ける。
+// 既存のコードベースでチェックを有効にする際に、違反箇所を洗い出して段階的に修正するために利用する。
+// 実行後のSeq Scanのチェック、MaxAffectedRowsのチェック、ORMのWHEREのチェックも対象となる。
+// 違反したSQL（WHEREの無いDELETE等）もそのまま実行されるため、本番環境で利用する場合は注意すること。
+// GuardViolationAsErrorより優先される。
+//
+// ただし、本番モードで危険な文（DangerousStatementsのTRUNCATE、DROP等）を禁止するチェックは対象外で、
+// trueの場合も実行せずにpanic（GuardViolationAsErrorの場合はerror）とする。
+var GuardViolationLogOnly = false
 
 // トランザクションにおいてロールバックが発生した際のログの出力有無
 var DumpTransactionRollbackLog = true
@@ -56,12 +102,39 @@ func IsDebugMode() bool {
 	}
 }
 
-type HasQuery interface {
-	Query(query string, args ...any) (*sql.Rows, error)
+// *sql.DB, *sql.Tx, *sql.Connのいずれもこのインターフェースを満たす。
+// QueryやExec、ORMの各関数はこれを受け取るため、用途に応じて使い分けられる。
+// nilを渡した場合はパッケージ変数のDBが利用される。
+type Executor interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
 
-type HasExec interface {
-	Exec(query string, args ...any) (sql.Result, error)
+// Deprecated: Executorを利用する。
+type HasQuery = Executor
+
+// Deprecated: Executorを利用する。
+type HasExec = Executor
+
+// nilの場合はDBを返す。
+func getExecutor(tx Executor) Executor {
+	if tx == nil {
+		return DB
+	}
+	return tx
+}
+
+// txがトランザクション（*sql.Tx、*Tx）の場合は、プリペアドステートメントを経由せずに実行するExecutorを返す。
+// それ以外の場合はnilを返す。
+func transactionOf(tx Executor) Executor {
+	switch e := tx.(type) {
+	case *sql.Tx, *Tx:
+		return e
+	case *preparedExecutor:
+		return transactionOf(e.tx)
+	}
+	return nil
 }
 
 func doAndRecover(c context.Context, tx *sql.Tx, f func(*sql.Tx) error) error {
@@ -70,8 +143,9 @@ func doAndRecover(c context.Context, tx *sql.Tx, f func(*sql.Tx) error) error {
 			if DumpTransactionRollbackLog {
 				l.Warn(c, "rollback start because panic occured")
 			}
-			if err := tx.Rollback(); err != nil {
-				panic(err)
+			// ロールバックにも失敗した場合は、元のpanicの値が失われないように両方を含めてpanicとする。
+			if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+				panic(&RollbackError{Cause: r, Err: err})
 			}
 			if DumpTransactionRollbackLog {
 				l.Warn(c, "rollback end")
@@ -89,7 +163,7 @@ func doAndRecover(c context.Context, tx *sql.Tx, f func(*sql.Tx) error) error {
 
 // 取得したデータの先頭を返す。
 // 受け取ったポインタの値も変更する。
-func QueryFirst[M any](tx HasQuery, mp *M, query string, args ...any) (*M, error) {
+func QueryFirst[M any](tx Executor, mp *M, query string, args ...any) (*M, error) {
 	result, err := Query(tx, mp, query, args...)
 	if err != nil {
 		return nil, err
@@ -105,42 +179,82 @@ func QueryFirst[M any](tx HasQuery, mp *M, query string, args ...any) (*M, error
 //
 // 1件もデータが存在しない場合は空の配列を返す。
 // エラーの場合はnilとerrorを返す。
-func Query[M any](tx HasQuery, mp *M, query string, args ...any) ([]M, error) {
-	// モデルがnilだとランタイムエラーとなるため、ここでチェックする
-	if mp == nil {
-		panic("arg mp must not be null")
+//
+// WithCacheを指定した場合は結果をキャッシュし、キャッシュがある場合はそれを返す。
+func Query[M any](tx Executor, mp *M, query string, args ...any) ([]M, error) {
+	values, opt := splitArgs(args)
+	tx = contextTx(tx, opt)
+	if err := beforeTxStatement(tx); err != nil {
+		return nil, err
+	}
+	useCache := opt.cacheTTL > 0 && unwrapPrepared(tx) == nil && QueryCache != nil
+	key := ""
+	// サブクエリのテーブルも無効化の対象とするため、展開後のSQLを利用する。
+	inlined, values := inlineSQLValues(query, values)
+	if useCache {
+		key = cacheKey(reflect.TypeFor[M](), inlined, values)
+		if v, ok := QueryCache.Get(key); ok {
+			if cached, ok := v.([]M); ok {
+				return slices.Clone(cached), nil
+			}
+		}
 	}
 
-	// プレースホルダー（$）とargsの個数が一致しない場合はエラーとする。
-	// ※ この仕様上、同じSQL内に$xを複数回使うことはできない。
-	if strings.Count(query, "$") != len(args) {
-		panic(PanicPlaceHolderNumberNotMatch)
+	r := []M{}
+	err := QueryEach(tx, mp, func(m M) error {
+		if err := checkResultRows(len(r)+1, inlined, opt); err != nil {
+			return err
+		}
+		r = append(r, m)
+		return nil
+	}, query, args...)
+	if err != nil {
+		return nil, err
 	}
 
-	// db.Queryはselect以外を実行しても問題なく動作する。
-	// 意図せず事故を起こさないように、この関数ではSELECTのみ許容する。
-	if !StrContainWithIgnoreCase(query, "SELECT ") {
-		panic(PanicQueryNotContanSelect)
+	if useCache {
+		QueryCache.Set(key, slices.Clone(r), tablesInQuery(inlined), opt.cacheTTL)
 	}
+	return r, nil
+}
 
-	if UseWhereCheck && !StrContainWithIgnoreCase(query, " WHERE ") && !StrContainWithIgnoreCase(query, DisableWhereCheckClause) {
-		panic(PanicSelectSQLMustUseWhere)
+// Queryの結果を構造体のポインタのリストとして返す。
+// 各要素は同じ配列を指すため、要素数に応じた構造体のコピーは発生しない。
+// 列の多いモデルで結果をそのまま変更する場合等に利用する。
+func QueryPtrs[M any](tx Executor, mp *M, query string, args ...any) ([]*M, error) {
+	l, err := Query(tx, mp, query, args...)
+	if err != nil {
+		return nil, err
 	}
+	return toPtrs(l), nil
+}
 
-	if ForceNowaitOnLockingRead && (StrContainWithIgnoreCase(query, " FOR SELECT") || StrContainWithIgnoreCase(query, " FOR UPDATE")) && !StrContainWithIgnoreCase(query, " NOWAIT") {
-		panic(PanicLockingReadMustUseNowait)
+// スライスの各要素へのポインタのスライスを返す。
+func toPtrs[M any](l []M) []*M {
+	r := make([]*M, len(l))
+	for i := range l {
+		r[i] = &l[i]
 	}
+	return r
+}
 
-	if tx == nil {
-		tx = DB
+// 取得したレコードを1行ずつ構造体へ格納してfnへ渡す。
+// 結果をスライスとして保持しないため、大量の行を順次処理する場合に利用する。
+//
+// fnがerrorを返した場合はその時点で処理を中断し、そのerrorを返す。
+func QueryEach[M any](tx Executor, mp *M, fn func(M) error, query string, args ...any) error {
+	// モデルがnilだとランタイムエラーとなるため、ここでチェックする
+	if mp == nil {
+		panic("arg mp must not be null")
 	}
 
-	rows, err := tx.Query(query, args...)
+	args, opt := splitArgs(args)
+	query, args = inlineSQLValues(query, args)
+	ctx, cancel := opt.context()
+	defer cancel()
+	rows, err := queryRows(ctx, tx, query, args, opt)
 	if err != nil {
-		if e := isAssumedSQLError(err); e != nil {
-			return nil, e
-		}
-		panic(fmt.Sprintf("query failed: %s, failed query: %s", err, query))
+		return err
 	}
 
 	// rowsの処理はクエリの実行後のエラーチェックが完了した後に呼ぶ。
@@ -157,6 +271,57 @@ func Query[M any](tx HasQuery, mp *M, query string, args ...any) ([]M, error) {
 	// なお、deferはpanicの際も必ず実行される。
 	defer rows.Close()
 
+	if err := scanStructRows(rows, mp, opt.columnMapping, fn); err != nil {
+		return err
+	}
+
+	checkSeqScanOnDebug(tx, query, args, opt)
+
+	return nil
+}
+
+// 結果セットの各行を構造体へ格納してfnへ渡す。
+// fnがerrorを返した場合はその時点で処理を中断し、そのerrorを返す。
+func scanStructRows[M any](rows *sql.Rows, mp *M, mapping ColumnMapping, fn func(M) error) error {
+	structValue := *mp
+	columns, err := rows.Columns()
+	if err != nil {
+		panic(err)
+	}
+	structFieldValuePtrInterfaces := structScanTargets(reflect.ValueOf(&structValue).Elem(), columns, mapping)
+
+	// rows.Next()は全ての行を繰り返し処理すると、
+	// 最終的には最後の行が読み込まれ、rows.Next()内部でEOFエラーが発生し、
+	// rows.Close()を呼び出す。
+	// rows.Next()で何らかのエラーが発生した場合もrows.Close()が呼ばれる。
+	for rows.Next() {
+		structValue = *mp
+
+		// ※ Scanは内部で型変換をしてくれる
+		if err := rows.Scan(structFieldValuePtrInterfaces...); err != nil {
+			panic(err)
+		}
+		if err := fn(structValue); err != nil {
+			return err
+		}
+	}
+
+	// rows.Err() からのエラーはループ内のさまざまなエラーの結果である可能性があるため、
+	// ここで必ずチェックしておく必要がある。
+	err = rows.Err()
+	if err != nil {
+		if e := isAssumedSQLError(err); e != nil {
+			return e
+		}
+		panic(err)
+	}
+
+	return nil
+}
+
+// Scanへ渡すstructの各フィールドへのポインタ配列を、結果セットのカラムの順番で作成する。
+// structElemはアドレスを取得可能な構造体の値であること。
+func structScanTargets(structElem reflect.Value, columns []string, mapping ColumnMapping) []any {
 	// 以下の情報を利用してScanへ渡すstructの各フィールドへのポインタ配列を作成する。
 	// ・モデルで定義したstructのフィールドの型とタグ情報
 	// ・結果セット（rows）のフィールド名
@@ -164,131 +329,409 @@ func Query[M any](tx HasQuery, mp *M, query string, args ...any) ([]M, error) {
 	// ※ この処理の目的: Scan関数へ渡すポインタ配列の順番を、DBからの取得結果（rows）の
 	//   各フィールドの順番と合わせる必要があるため。
 	//  （そのまま構造体の各フィールドを渡すと順番が不一致となってしまう）
-	structValue := *mp
-	structElem := reflect.ValueOf(&structValue).Elem()
 	structType := structElem.Type()
 	if structType.Kind() != reflect.Struct {
 		panic("model mubt be struct.")
 	}
+	if components := joinComponents(structType); components != nil {
+		return joinScanTargets(structElem, components, columns, mapping)
+	}
+	// ssqlgenで生成されたメソッドがある場合はそれを利用する。
+	// Strictの場合はモデルのフィールドが揃っているかのチェックが必要なため利用しない。
+	if cs, ok := structElem.Addr().Interface().(ColumnScanner); ok && mapping != ColumnMappingStrict {
+		if targets, ok := cs.ScanTargets(columns); ok {
+			return targets
+		}
+	}
 	// 計算量をO(構造体のフィールド数+結果セットのカラム数)とするため、mapにしておく。
 	structFieldNameToTypeMap := make(map[string]any)
-	for i := range structType.NumField() {
-		columnName := structType.Field(i).Tag.Get("database")
+	for i, tag := range columnFields(structType) {
 		// タグはすべてのフィールドに設定されている必要がある。
-		if columnName == "" {
+		if tag.Column == "" {
 			n := structType.Field(i).Name
 			panic(fmt.Sprintf("%s has no database label.", n))
 		}
 		// Scan等のinterface{}を受け取る関数は、内部で型情報を復元するため、
 		// ここではすべてのフィールドはその型に関係なく最後にinterface{}にしておけば良い。
-		structFieldNameToTypeMap[columnName] = structElem.Field(i).Addr().Interface()
-	}
-	ct, err := rows.ColumnTypes()
-	if err != nil {
-		panic(err)
+		structFieldNameToTypeMap[tag.Column] = toScanDest(tag, structElem.Field(i))
 	}
-	structFieldValuePtrInterfaces := make([]any, len(ct))
-	for i, c := range ct {
-		structFieldAddr, ok := structFieldNameToTypeMap[c.Name()]
-		// 結果セットのフィールドが、モデルのタグに含まれていない場合はpanic
+	structFieldValuePtrInterfaces := make([]any, len(columns))
+	resultColumns := make(map[string]struct{}, len(columns))
+	for i, c := range columns {
+		resultColumns[c] = struct{}{}
+		structFieldAddr, ok := structFieldNameToTypeMap[c]
 		if !ok {
-			panic(fmt.Sprint("model does not have result field: ", c.Name()))
+			// Lenientの場合は読み捨てる。
+			if mapping == ColumnMappingLenient {
+				structFieldValuePtrInterfaces[i] = new(any)
+				continue
+			}
+			// 結果セットのフィールドが、モデルのタグに含まれていない場合はpanic
+			panic(fmt.Sprint("model does not have result field: ", c))
 		}
 		structFieldValuePtrInterfaces[i] = structFieldAddr
 	}
+	if mapping == ColumnMappingStrict {
+		for _, tag := range columnFields(structType) {
+			// モデルのフィールドが、結果セットに含まれていない場合はpanic
+			if c := tag.Column; !hasKey(resultColumns, c) {
+				panic(fmt.Sprint("result does not have model field: ", c))
+			}
+		}
+	}
+	return structFieldValuePtrInterfaces
+}
 
-	// rows.Next()は全ての行を繰り返し処理すると、
-	// 最終的には最後の行が読み込まれ、rows.Next()内部でEOFエラーが発生し、
-	// rows.Close()を呼び出す。
-	// rows.Next()で何らかのエラーが発生した場合もrows.Close()が呼ばれる。
+// QueryInBatchesで分割する件数のデフォルト値
+var DefaultBatchSize = 1000
+
+// 大量のキーを分割してクエリを実行し、結果をまとめて返す。
+// 巨大なIN句やパラメーター数の上限を避けるために利用する。
+//
+// キーのスライスは$1に渡されるため、クエリでは"= ANY($1)"の形で利用する。
+// 残りのargsは$2以降に対応する。
+// batchSizeが0以下の場合はDefaultBatchSizeで分割する。
+//
+//	ssql.QueryInBatches(tx, &User{}, "SELECT * FROM users WHERE id = ANY($1) AND is_active = $2", ids, 0, true)
+func QueryInBatches[M any, K any](tx Executor, mp *M, query string, keys []K, batchSize int, args ...any) ([]M, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
 	r := []M{}
-	for rows.Next() {
-		structValue = *mp
+	for chunk := range slices.Chunk(keys, batchSize) {
+		l, err := Query(tx, mp, query, append([]any{chunk}, args...)...)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, l...)
+	}
+	return r, nil
+}
 
-		// ※ Scanは内部で型変換をしてくれる
-		if err := rows.Scan(structFieldValuePtrInterfaces...); err != nil {
+// 1行1列の結果を取得する。
+// COUNTやSUM、EXISTS等の集計結果を取得する場合に利用する。
+//
+// 結果が0行の場合はTのゼロ値を返す。
+// 結果がNULLとなり得る場合はTにポインタ型またはsql.NullXXX型を指定する。
+func QueryScalar[T any](tx Executor, query string, args ...any) (T, error) {
+	var v T
+	args, opt := splitArgs(args)
+	query, args = inlineSQLValues(query, args)
+	ctx, cancel := opt.context()
+	defer cancel()
+	rows, err := queryRows(ctx, tx, query, args, opt)
+	if err != nil {
+		return v, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		if err := rows.Scan(&v); err != nil {
 			panic(err)
 		}
-		r = append(r, structValue)
+	}
+	if err := rows.Err(); err != nil {
+		panic(err)
 	}
 
-	// rows.Err() からのエラーはループ内のさまざまなエラーの結果である可能性があるため、
-	// ここで必ずチェックしておく必要がある。
-	err = rows.Err()
+	checkSeqScanOnDebug(tx, query, args, opt)
+
+	return v, nil
+}
+
+// 1列の結果をスライスとして返す。
+// IDの一覧など単一のカラムを取得する場合に、構造体を定義せずに利用できる。
+//
+// 1件もデータが存在しない場合は空の配列を返す。
+func QueryColumn[T any](tx Executor, query string, args ...any) ([]T, error) {
+	args, opt := splitArgs(args)
+	query, args = inlineSQLValues(query, args)
+	ctx, cancel := opt.context()
+	defer cancel()
+	rows, err := queryRows(ctx, tx, query, args, opt)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	defer rows.Close()
 
-	// デバッグモードの場合はExplainによるチェックを行う
-	if IsDebugMode() && !CheckSeqScan(query, args...) {
-		panic(fmt.Sprintf(PanicSQLIsSeqScan, query))
+	r := []T{}
+	for rows.Next() {
+		if err := checkResultRows(len(r)+1, query, opt); err != nil {
+			return nil, err
+		}
+		var v T
+		if err := rows.Scan(&v); err != nil {
+			panic(err)
+		}
+		r = append(r, v)
+	}
+	if err := rows.Err(); err != nil {
+		panic(err)
 	}
 
+	checkSeqScanOnDebug(tx, query, args, opt)
+
 	return r, nil
 }
 
-// "Seq Scan"のSQLが存在する場合はただちにpanicで処理を止めて出力。
-func CheckSeqScan(query string, args ...any) bool {
-	if !UseSeqScanCheck || StrContainWithIgnoreCase(query, SeqScanCheckDisableClause) {
-		return true
+// 取得したレコードをカラム名をキーとしたマップのリストとして返す。
+// 対応する構造体が存在しない動的なクエリ（集計レポート等）で利用する。
+//
+// 1件もデータが存在しない場合は空の配列を返す。
+// 値の型はドライバーが返す型となる。
+func QueryMaps(tx Executor, query string, args ...any) ([]map[string]any, error) {
+	args, opt := splitArgs(args)
+	query, args = inlineSQLValues(query, args)
+	ctx, cancel := opt.context()
+	defer cancel()
+	rows, err := queryRows(ctx, tx, query, args, opt)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	if !IsDebugMode() {
-		panic("not use this function without debug mode")
+	columns, err := rows.Columns()
+	if err != nil {
+		panic(err)
+	}
+	values := make([]any, len(columns))
+	valuePtrs := make([]any, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
 	}
-	tx, err := DB.Begin()
 
-	if err != nil {
+	r := []map[string]any{}
+	for rows.Next() {
+		if err := checkResultRows(len(r)+1, query, opt); err != nil {
+			return nil, err
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			panic(err)
+		}
+		m := make(map[string]any, len(columns))
+		for i, c := range columns {
+			m[c] = values[i]
+		}
+		r = append(r, m)
+	}
+	if err := rows.Err(); err != nil {
 		panic(err)
 	}
 
-	// データが少ない場合でも"Seq Scan"に最適化されないように`enable_seqscan`をoffにしておく。
-	// LOCAL: トランザクション単位
-	// デフォルトはSESSION単位だが同じコネクションを使っている他のSQLも全て
-	// 影響してしまうため、LOCALとしている。
-	_, err = tx.Exec("SET LOCAL enable_seqscan TO 'off'")
+	checkSeqScanOnDebug(tx, query, args, opt)
+
+	return r, nil
+}
+
+// 結果セットのカラムの情報
+type ColumnInfo struct {
+	Name string
+	// "INT8"、"TEXT"、"UUID"等のデータベース上の型名
+	DatabaseType string
+	// Scanに利用できるGoの型
+	ScanType reflect.Type
+	// NULLを許容するかどうか。ドライバーが判定できない場合はnil
+	Nullable *bool
+}
+
+// クエリの結果セットのカラム名と型を返す。
+// エクスポート処理や管理ツール等、汎用的な処理を実装する場合に利用する。
+//
+// クエリはLIMIT 0のサブクエリとして実行されるため、レコードは取得しない。
+// プレースホルダーがある場合は、型を決定するために値をargsへ指定する。
+func Describe(tx Executor, query string, args ...any) ([]ColumnInfo, error) {
+	args, opt := splitArgs(args)
+	tx = contextTx(tx, opt)
+	if err := beforeTxStatement(tx); err != nil {
+		return nil, err
+	}
+	query, args = inlineSQLValues(query, args)
+	ctx, cancel := opt.context()
+	defer cancel()
+
+	if err := checkParameterCount(args); err != nil {
+		return nil, err
+	}
+	err := guard(ctx, opt.guard, func() {
+		checkPlaceholders(query, args)
+		if analyzeStatement(query).kind != "SELECT" {
+			panic(PanicQueryNotContanSelect)
+		}
+	})
 	if err != nil {
-		panic(fmt.Sprintf("SET exec failed: %s", err))
+		return nil, err
 	}
 
-	// analyzeは実際にSQLが実行されてしまうためfalseとしている。
-	rows, err := tx.Query("EXPLAIN (ANALYZE false, FORMAT json) "+query, args...)
+	q := "SELECT * FROM (" + strings.TrimRight(query, " \t\r\n;") + ") AS describe LIMIT 0"
+	start := time.Now()
+	rows, err := getReadExecutor(tx, q, opt).QueryContext(ctx, q, args...)
 	if err != nil {
+		if e := queryError(err, q, args, start); e != nil {
+			return nil, e
+		}
 		panic(fmt.Sprintf("query failed: %s, failed query: %s", err, query))
 	}
 	defer rows.Close()
-	r := []string{}
-	for rows.Next() {
-		var s string
-		if err := rows.Scan(&s); err != nil {
-			panic(err)
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		panic(err)
+	}
+	r := []ColumnInfo{}
+	for _, ct := range types {
+		info := ColumnInfo{
+			Name:         ct.Name(),
+			DatabaseType: ct.DatabaseTypeName(),
+			ScanType:     ct.ScanType(),
+		}
+		if nullable, ok := ct.Nullable(); ok {
+			info.Nullable = &nullable
 		}
-		r = append(r, s)
+		r = append(r, info)
 	}
-	err = rows.Err()
+	return r, nil
+}
+
+// SELECT文のチェックを行った上でクエリを実行し、結果セットを返す。
+// 呼び出し側で必ずrows.Close()を呼ぶこと。
+// レプリカが設定されている場合は、getReadExecutorにより振り分けられる。
+func queryRows(ctx context.Context, tx Executor, query string, args []any, opt *options) (*sql.Rows, error) {
+	tx = contextTx(tx, opt)
+	if err := beforeTxStatement(tx); err != nil {
+		return nil, err
+	}
+	if err := checkParameterCount(args); err != nil {
+		return nil, err
+	}
+	if err := guard(ctx, opt.guard, func() { checkSelectQuery(query, args, opt) }); err != nil {
+		return nil, err
+	}
+	if err := checkSeqScanBeforeExecOnDebug(ctx, tx, query, args, opt); err != nil {
+		return nil, err
+	}
+	logExplainAnalyzeOnDebug(ctx, getReadExecutor(tx, query, opt), query, args, opt)
+
+	start := time.Now()
+	rows, err := retry(ctx, tx, opt, func() (*sql.Rows, error) {
+		return getReadExecutor(tx, query, opt).QueryContext(ctx, annotateQuery(ctx, query), args...)
+	})
+	recordQueryShape(query, args, time.Since(start))
 	if err != nil {
-		panic(err)
+		if e := queryError(err, query, args, start); e != nil {
+			return nil, e
+		}
+		panic(fmt.Sprintf("query failed: %s, failed query: %s", err, query))
 	}
-	// Explainでは特にコミットするものはないためロールバックをしている。
-	if err := tx.Rollback(); err != nil {
-		panic(err)
+	return rows, nil
+}
+
+// デバッグモードの場合はExplainによるチェックを行う
+// GuardConfig.SeqScanCheckBeforeExecの場合は実行前にチェック済みのため、何もしない。
+func checkSeqScanOnDebug(tx Executor, query string, args []any, opt *options) {
+	if opt.guard.SeqScanCheckBeforeExec {
+		return
+	}
+	checkPlanOnDebug(tx, query, args, opt)
+}
+
+// GuardConfig.SeqScanCheckBeforeExecの場合に、実行前にExplainによるチェックを行う
+// 実行前のため、他のチェックと同じくGuardConfig.ViolationAsErrorの場合はErrGuardViolationのerrorを返す。
+func checkSeqScanBeforeExecOnDebug(ctx context.Context, tx Executor, query string, args []any, opt *options) error {
+	if !opt.guard.SeqScanCheckBeforeExec || !IsDebugMode() {
+		return nil
+	}
+	msg := checkPlan(opt.guard, contextTx(tx, opt), query, args, opt.allowSeqScan)
+	if msg == "" {
+		return nil
+	}
+	return guard(ctx, opt.guard, func() { panic(msg) })
+}
+
+func checkPlanOnDebug(tx Executor, query string, args []any, opt *options) {
+	if !IsDebugMode() {
+		return
+	}
+	if msg := checkPlan(opt.guard, contextTx(tx, opt), query, args, opt.allowSeqScan); msg != "" {
+		reportGuardViolation(opt.ctx, msg)
+	}
+}
+
+// SELECT文に対する各種チェックを行い、違反している場合はpanicとする。
+func checkSelectQuery(query string, args []any, opt *options) {
+	// プレースホルダー（$1, $2...）とargsの個数が一致しない場合はエラーとする。
+	checkPlaceholders(query, args)
+
+	// db.Queryはselect以外を実行しても問題なく動作する。
+	// 意図せず事故を起こさないように、この関数ではSELECTのみ許容する。
+	// （INSERT ... SELECT等、SELECTを含むだけの文も許容しない）
+	s := analyzeStatement(query)
+	if s.kind != "SELECT" {
+		panic(PanicQueryNotContanSelect)
+	}
+
+	// サブクエリ内のWHEREも許容する。
+	if opt.whereCheck() && !s.hasKeyword("WHERE") && !StrContainWithIgnoreCase(query, DisableWhereCheckClause) {
+		panic(PanicSelectSQLMustUseWhere)
+	}
+
+	if opt.guard.ForceNowaitOnLockingRead && IsPostgres() && s.hasLockingClauseWithoutNowait() {
+		panic(PanicLockingReadMustUseNowait)
+	}
+
+	if opt.guard.ForceLimitWithOrderBy && IsDebugMode() && s.hasOrderByWithoutLimit() {
+		if !opt.guard.LimitWithOrderByWarnOnly {
+			panic(PanicOrderByWithoutLimit)
+		}
+		l.Warn(opt.ctx, PanicOrderByWithoutLimit+": "+truncateQuery(query))
+	}
+}
+
+// "Seq Scan"のSQLが存在する場合はただちにpanicで処理を止めて出力。
+// DefaultGuardConfigのUseSeqScanCheckがfalseの場合はチェックしない。
+func CheckSeqScan(query string, args ...any) bool {
+	return checkSeqScan(defaultGuardConfig(), nil, query, args...)
+}
+
+// Seq Scanのみをチェックし、含まれる場合はfalseを返す。（GuardConfig.PlanRulesはチェックしない）
+func checkSeqScan(cfg GuardConfig, tx Executor, query string, args ...any) bool {
+	cfg.PlanRules = nil
+	return checkPlan(cfg, tx, query, args, false) == ""
+}
+
+// Explainによる実行計画のチェック（Seq Scan、GuardConfig.PlanRules）を行い、
+// 違反している場合はpanicの値となるメッセージを、違反していない場合は空文字を返す。
+// allowSeqScanの場合はSeq Scanのチェックのみを行わない。
+//
+// txがトランザクションの場合は、そのトランザクション内でセーブポイントを利用してExplainを実行する。
+// それ以外の場合は新たにトランザクションを開始して実行する。
+func checkPlan(cfg GuardConfig, tx Executor, query string, args []any, allowSeqScan bool) string {
+	seqScan := cfg.UseSeqScanCheck && !allowSeqScan && !StrContainWithIgnoreCase(query, SeqScanCheckDisableClause)
+	if !IsPostgres() || (!seqScan && len(cfg.PlanRules) == 0) {
+		return ""
 	}
 
-	if len(r) != 1 {
-		panic("explain result is not 1 row")
+	if !IsDebugMode() {
+		panic("not use this function without debug mode")
 	}
-	p := []Plan{}
-	err = json.Unmarshal([]byte(r[0]), &p)
+
+	var raw string
+	if t := transactionOf(tx); t != nil {
+		var err error
+		raw, err = explainInSavepoint(context.Background(), t, query, args, false)
+		if err != nil {
+			panic(fmt.Sprintf("query failed: %s, failed query: %s", err, query))
+		}
+	} else {
+		raw = explainInNewTx(query, args)
+	}
+	r, err := parseExplainJSON(raw)
 	if err != nil {
 		panic(err)
 	}
-	if len(p) != 1 {
-		panic("explain result json is not 1 child")
-	}
 
-	// "Seq Scan"が含まれている場合はpanicとする。
-	// 構造体にマッピングせずに文字列による検索でも実現はできるが、
-	// 管理のしやすさのために構造体に格納している。
+	nodes := r.Plan.Nodes()
+
+	// "Seq Scan"が含まれている場合は違反とする。
 	//
 	// [参考]
 	// https://www.postgresql.jp/docs/14/using-explain.html
@@ -315,71 +758,92 @@ func CheckSeqScan(query string, args ...any) bool {
 	// そちらが選択される。（例えば xxx = 'a' OR xxx = 'b' 等の条件で確認できる）
 	// したがって本チェックでは冒頭で「enable_seqscan」をoffにすることで、どちらも選択
 	// 可能な際は"Seq Scan"を選択しないように設定している。
-	plansHaveSeqScan := false
-	for _, ps1 := range p[0].Plan.Plans {
-		if StrContainWithIgnoreCase(ps1.NodeType, "Seq Scan") {
-			plansHaveSeqScan = true
-		}
-		for _, ps2 := range ps1.Plans {
-			if StrContainWithIgnoreCase(ps2.NodeType, "Seq Scan") {
-				plansHaveSeqScan = true
-			}
-			for _, ps3 := range ps2.Plans {
-				if StrContainWithIgnoreCase(ps3.NodeType, "Seq Scan") {
-					plansHaveSeqScan = true
-				}
-				for _, ps4 := range ps3.Plans {
-					if StrContainWithIgnoreCase(ps4.NodeType, "Seq Scan") {
-						plansHaveSeqScan = true
-					}
-					for _, ps5 := range ps4.Plans {
-						if StrContainWithIgnoreCase(ps5.NodeType, "Seq Scan") {
-							plansHaveSeqScan = true
-						}
-						for _, ps6 := range ps5.Plans {
-							if StrContainWithIgnoreCase(ps6.NodeType, "Seq Scan") {
-								plansHaveSeqScan = true
-							}
-							for _, ps7 := range ps6.Plans {
-								if StrContainWithIgnoreCase(ps7.NodeType, "Seq Scan") {
-									plansHaveSeqScan = true
-								}
-								for _, ps8 := range ps7.Plans {
-									if StrContainWithIgnoreCase(ps8.NodeType, "Seq Scan") {
-										plansHaveSeqScan = true
-									}
-									for _, ps9 := range ps8.Plans {
-										if StrContainWithIgnoreCase(ps9.NodeType, "Seq Scan") {
-											plansHaveSeqScan = true
-										}
-										for _, ps10 := range ps9.Plans {
-											if StrContainWithIgnoreCase(ps10.NodeType, "Seq Scan") {
-												plansHaveSeqScan = true
-											}
-										}
-									}
-								}
-							}
-						}
-					}
-				}
+	//
+	// SeqScanRowsThreshold、SeqScanCostThresholdが指定されている場合は、閾値を超えるSeq Scanのみを対象とする。
+	if seqScan {
+		for _, n := range nodes {
+			if StrContainWithIgnoreCase(n.NodeType, "Seq Scan") && cfg.exceedsSeqScanThreshold(n) {
+				return seqScanMessage(query, n)
 			}
 		}
 	}
-	if StrContainWithIgnoreCase(p[0].Plan.NodeType, "Seq Scan") || plansHaveSeqScan {
-		m := []map[string]any{}
-		err = json.Unmarshal([]byte(r[0]), &m)
-		if err != nil {
-			panic(err)
+	for _, rule := range cfg.PlanRules {
+		for _, n := range nodes {
+			if rule.Match(n) {
+				return fmt.Sprintf(PanicPlanRuleViolation, rule.Name, n.NodeType, query)
+			}
 		}
-		{
-			return false
+	}
+	return ""
+}
+
+// Seq Scanによるpanicの値。インデックスの候補を組み立てられる場合は、そのCREATE INDEX文を付与する。
+func seqScanMessage(query string, n *PlanNode) string {
+	msg := fmt.Sprintf(PanicSQLIsSeqScan, query)
+	if index := suggestIndex(n); index != "" {
+		msg += " (suggested index: " + index + ")"
+	}
+	return msg
+}
+
+// 新たにトランザクションを開始してExplainを実行する。
+func explainInNewTx(query string, args []any) string {
+	tx, err := DB.Begin()
+
+	if err != nil {
+		panic(err)
+	}
+
+	// データが少ない場合でも"Seq Scan"に最適化されないように`enable_seqscan`をoffにしておく。
+	// LOCAL: トランザクション単位
+	// デフォルトはSESSION単位だが同じコネクションを使っている他のSQLも全て
+	// 影響してしまうため、LOCALとしている。
+	_, err = tx.Exec("SET LOCAL enable_seqscan TO 'off'")
+	if err != nil {
+		panic(fmt.Sprintf("SET exec failed: %s", err))
+	}
+
+	raw, err := explainJSON(context.Background(), tx, query, args, false)
+	if err != nil {
+		panic(fmt.Sprintf("query failed: %s, failed query: %s", err, query))
+	}
+	// Explainでは特にコミットするものはないためロールバックをしている。
+	if err := tx.Rollback(); err != nil {
+		panic(err)
+	}
+	return raw
+}
+
+// 呼び出し元のトランザクション内で、セーブポイントを利用してExplainを実行する。
+// 同じトランザクションで作成した一時テーブル、SET LOCALの設定、コミット前のDDLを参照するSQLもチェックできる。
+// analyzeがfalseの場合はSeq Scanのチェックのためにenable_seqscanをoffにする。（セーブポイントまでのロールバックにより元に戻る）
+// analyzeがtrueの場合は、EXPLAIN ANALYZEが失敗した場合や、実行による変更もロールバックされる。
+func explainInSavepoint(ctx context.Context, tx Executor, query string, args []any, analyze bool) (string, error) {
+	const savepoint = "ssql_explain"
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+		return "", err
+	}
+	raw, err := func() (string, error) {
+		if !analyze {
+			if _, err := tx.ExecContext(ctx, "SET LOCAL enable_seqscan TO 'off'"); err != nil {
+				return "", err
+			}
 		}
+		return explainJSON(ctx, tx, query, args, analyze)
+	}()
+	// Explainが失敗した場合もトランザクションを継続できるように、セーブポイントまでロールバックする。
+	if _, rerr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint); rerr != nil {
+		return "", rerr
+	}
+	if _, rerr := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint); rerr != nil {
+		return "", rerr
 	}
-	return true
+	return raw, err
 }
 
 // 注: これ以上ネストされた結果の場合は情報をロストする。
+//
+// Deprecated: ネストの深さに制限の無いPlanNode（Explainの結果）を利用すること。
 type Plan struct {
 	Plan struct {
 		NodeType string `json:"Node Type"`
@@ -416,56 +880,168 @@ type Plan struct {
 	} `json:"Plan"`
 }
 
-func Exec(tx HasExec, query string, args ...any) (sql.Result, error) {
-	// プレースホルダー（$）とargsの個数が一致しない場合はエラーとする。
-	if strings.Count(query, "$") != len(args) {
-		panic(PanicPlaceHolderNumberNotMatch)
+func Exec(tx Executor, query string, args ...any) (sql.Result, error) {
+	args, opt := splitArgs(args)
+	tx = contextTx(tx, opt)
+	if err := beforeTxStatement(tx); err != nil {
+		return nil, err
+	}
+	query, args = inlineSQLValues(query, args)
+	ctx, cancel := opt.context()
+	defer cancel()
+
+	if err := checkParameterCount(args); err != nil {
+		return nil, err
+	}
+	err := guard(ctx, opt.guard, func() {
+		checkExecQuery(query, args, opt)
+		checkWritableTx(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSeqScanBeforeExecOnDebug(ctx, tx, query, args, opt); err != nil {
+		return nil, err
+	}
+	checkAffectedRowsOnDebug(ctx, tx, query, args, opt)
+
+	start := time.Now()
+	result, err := retry(ctx, tx, opt, func() (sql.Result, error) {
+		return getExecutor(tx).ExecContext(ctx, annotateQuery(ctx, query), args...)
+	})
+	recordQueryShape(query, args, time.Since(start))
+	markWrite()
+	if err != nil {
+		if e := queryError(err, query, args, start); e != nil {
+			return nil, e
+		}
+		panic(fmt.Sprintf("query failed: %s, failed query: %s", err, query))
 	}
 
-	if UseWhereCheck && StrContainWithIgnoreCase(query, "DELETE ") && !StrContainWithIgnoreCase(query, " WHERE ") && !StrContainWithIgnoreCase(query, DisableWhereCheckClause) {
+	invalidateCacheAfterWrite(tx, query)
+
+	checkSeqScanOnDebug(tx, query, args, opt)
+
+	return result, nil
+}
+
+// Execを実行し、更新（挿入、削除）された行数がnと一致しない場合はErrUnexpectedRowCountをラップしたerrorを返す。
+// 「このUPDATEは必ず1行のみを更新する」といった前提をチェックする場合に利用する。
+//
+// 行数が一致しない場合も文は実行済みのため、トランザクション内で利用し、errorを返してロールバックさせること。
+//
+//	ssql.Transaction(c, func(tx *sql.Tx) error {
+//		_, err := ssql.ExecExpectRows(tx, 1, "UPDATE users SET name = $1, updated_at = now() WHERE id = $2", name, id)
+//		return err
+//	})
+func ExecExpectRows(tx Executor, n int64, query string, args ...any) (sql.Result, error) {
+	result, err := Exec(tx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		panic(err)
+	}
+	if affected != n {
+		return result, fmt.Errorf("%w: expected %d, got %d (query: %s)", ErrUnexpectedRowCount, n, affected, truncateQuery(query))
+	}
+	return result, nil
+}
+
+// Exec文に対する各種チェックを行い、違反している場合はpanicとする。
+func checkExecQuery(query string, args []any, opt *options) {
+	// プレースホルダー（$1, $2...）とargsの個数が一致しない場合はエラーとする。
+	checkPlaceholders(query, args)
+
+	// WITH句内のUPDATE、DELETEもそれぞれの文ごとにWHEREの有無をチェックする。
+	// サブクエリ内のWHEREは対象の行を絞り込まないため、チェックを満たさない。
+	s := analyzeStatement(query)
+	checkDangerousStatement(s, opt.guard)
+
+	if opt.whereCheck() && s.hasStatementWithoutWhere("DELETE") && !StrContainWithIgnoreCase(query, DisableWhereCheckClause) {
 		panic(PanicDeleteSQLMustUseWhere)
 	}
 
-	if StrContainWithIgnoreCase(query, "UPDATE ") {
-		if UseWhereCheck && !StrContainWithIgnoreCase(query, " WHERE ") && !StrContainWithIgnoreCase(query, DisableWhereCheckClause) {
+	if s.hasUpdate() {
+		// INSERT ... ON CONFLICT DO UPDATEは競合した行のみが対象となるため、WHEREは不要。
+		if opt.whereCheck() && s.hasStatementWithoutWhere("UPDATE") && !StrContainWithIgnoreCase(query, DisableWhereCheckClause) {
 			panic(PanicUpdateSQLMustUseWhere)
 		}
-		if ForceUpdatedAtCheck && !StrContainWithIgnoreCase(query, "updated_at") {
+		if opt.guard.ForceUpdatedAtCheck && !opt.skipUpdatedAtCheck && UpdatedAtColumn != "" && !s.hasIdentifier(UpdatedAtColumn) {
 			panic(PanicUpdateSQLMustHaveUpdatedAt)
 		}
 	}
+}
 
-	if tx == nil {
-		tx = DB
+// RETURNING句を含むINSERT、UPDATE、DELETEを実行し、返された行を構造体へ格納して返す。
+// 受け取ったポインタの値は1行目の値に変更する。（1行も返されない場合は変更しない）
+//
+// pgxではLastInsertIdが利用できないため、生成されたidやcreated_at等を取得する場合に利用する。
+func ExecReturning[M any](tx Executor, mp *M, query string, args ...any) ([]M, error) {
+	if mp == nil {
+		panic("arg mp must not be null")
+	}
+
+	args, opt := splitArgs(args)
+	tx = contextTx(tx, opt)
+	if err := beforeTxStatement(tx); err != nil {
+		return nil, err
 	}
+	query, args = inlineSQLValues(query, args)
+	ctx, cancel := opt.context()
+	defer cancel()
 
-	result, err := tx.Exec(query, args...)
+	if err := checkParameterCount(args); err != nil {
+		return nil, err
+	}
+	err := guard(ctx, opt.guard, func() {
+		checkExecQuery(query, args, opt)
+		checkWritableTx(tx)
+		if !analyzeStatement(query).hasKeyword("RETURNING") {
+			panic(PanicExecReturningMustHaveReturning)
+		}
+	})
 	if err != nil {
-		if e := isAssumedSQLError(err); e != nil {
+		return nil, err
+	}
+	if err := checkSeqScanBeforeExecOnDebug(ctx, tx, query, args, opt); err != nil {
+		return nil, err
+	}
+	checkAffectedRowsOnDebug(ctx, tx, query, args, opt)
+
+	start := time.Now()
+	rows, err := retry(ctx, tx, opt, func() (*sql.Rows, error) {
+		return getExecutor(tx).QueryContext(ctx, annotateQuery(ctx, query), args...)
+	})
+	recordQueryShape(query, args, time.Since(start))
+	markWrite()
+	if err != nil {
+		if e := queryError(err, query, args, start); e != nil {
 			return nil, e
 		}
 		panic(fmt.Sprintf("query failed: %s, failed query: %s", err, query))
 	}
+	defer rows.Close()
 
-	// デバッグモードの場合はExplainによるチェックを行う
-	if IsDebugMode() && !CheckSeqScan(query, args...) {
-		panic(fmt.Sprintf(PanicSQLIsSeqScan, query))
+	r := []M{}
+	// 制約違反等のエラーは結果の読み出し時に返される場合がある。
+	err = scanStructRows(rows, mp, opt.columnMapping, func(m M) error {
+		r = append(r, m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return result, nil
-}
+	invalidateCacheAfterWrite(tx, query)
 
-func isAssumedSQLError(err error) error {
-	if strings.Contains(err.Error(), PostgresErrCodeLockNotAvailable) {
-		return ErrLockNotAvailable
-	}
-	if strings.Contains(err.Error(), PostgresErrCodeUniqConstraint) {
-		return ErrUniqConstraint
-	}
-	if strings.Contains(err.Error(), PostgresErrCodeDeadLock) {
-		return ErrDeadLock
+	checkSeqScanOnDebug(tx, query, args, opt)
+
+	if len(r) > 0 {
+		*mp = r[0]
 	}
-	return nil
+	return r, nil
 }
 
 // トランザクションを生成して、受け取った無名関数へそのトランザクションを渡して実行する。
@@ -473,16 +1049,92 @@ func isAssumedSQLError(err error) error {
 // 無名関数の中でpanicが発生した場合はロールバックを実行する。
 // 無名関数がerrorを返した場合はロールバックを実行した上でそのerrorを返す。
 // この関数がerrorを返す場合は、それは無名関数が返したerrorとなる。
-// (この関数自体の処理によって発生するエラーは無く、それらは全てpanicとなる)
+// (この関数自体の処理によって発生するエラーは、コミット時のErrSerializationFailureを除き全てpanicとなる)
+// トランザクションの開始やコミットの失敗をerrorとして扱う場合はTryTransactionを利用する。
 //
 // 今のところトランザクションのネストは想定していないので、txの引数は取っていない。
 //
-// コンテキストはロールバック時のログ出力のために渡している。
+// コンテキストはトランザクションの開始（BeginTx）とロールバック時のログ出力に利用する。
+// コンテキストがキャンセルされた場合はトランザクションをロールバックしてコネクションとロックを解放し、
+// 以降のtxを利用したQuery、Exec等はコンテキストのerrorを返す。
+// 無名関数の終了時点でキャンセルされている場合はコミットせず、コンテキストのerrorを返す。
 func Transaction(c context.Context, f func(*sql.Tx) error) error {
-	tx, err := DB.Begin()
-	if err != nil {
+	return TransactionWithOptions(c, nil, f)
+}
+
+// 分離レベルや読み取り専用、タイムアウトを指定してトランザクションを実行する。
+// optsがnilの場合はTransactionと同じく、DBのデフォルトの設定となる。
+// それ以外の挙動はTransactionと同じ。
+//
+//	err := ssql.TransactionWithOptions(c, &ssql.TxOptions{Isolation: sql.LevelSerializable}, func(tx *sql.Tx) error {
+//		...
+//	})
+//
+// 読み取り専用のトランザクション内で書き込みを行った場合はDBのエラーとなる。
+func TransactionWithOptions(c context.Context, opts *TxOptions, f func(*sql.Tx) error) error {
+	err := transaction(c, DB, opts, f)
+	if isTxFailure(err) {
 		panic(err)
 	}
+	return err
+}
+
+// 読み取り専用のトランザクションを実行する。
+// 複数のSELECTで一貫した結果を得たい場合等、読み取りのみの処理であることを明示するために利用する。
+//
+// レプリカが設定されている場合はレプリカで実行する。（直前に書き込みを行った場合やWithPrimaryを指定した場合はプライマリ）
+// デバッグモードでは、トランザクション内でExec等の書き込みを行った場合はpanicとなる。
+// （本番モードでもDB側で読み取り専用のトランザクションへの書き込みはエラーとなる）
+//
+//	err := ssql.ReadTransaction(c, func(tx *sql.Tx) error {
+//		users, err := ssql.Find(tx, &User{}, ...)
+//		...
+//	})
+//
+// それ以外の挙動はTransactionと同じ。
+func ReadTransaction(c context.Context, f func(*sql.Tx) error, opts ...Option) error {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	db := DB
+	if !o.primary {
+		db = readDB()
+	}
+	err := transaction(c, db, &TxOptions{ReadOnly: true}, f)
+	if isTxFailure(err) {
+		panic(err)
+	}
+	return err
+}
+
+// Transactionと同じくトランザクションを実行するが、トランザクションの開始、コミット、ロールバックの失敗をpanicではなくerrorとして返す。
+// DBが一時的に利用できない場合でも処理を継続（縮退運転）する必要があるサービスで利用する。
+//
+// 返されるerrorはErrBeginFailed、ErrCommitFailed、ErrRollbackFailed（*RollbackError）をラップしている。
+//
+//	err := ssql.TryTransaction(c, nil, func(tx *sql.Tx) error { ... })
+//	if errors.Is(err, ssql.ErrBeginFailed) {
+//		// DBに接続できないため、キャッシュの値で応答する等
+//	}
+//
+// 無名関数の中で発生したpanic（Query、Exec等のpanicを含む）は、Transactionと同じくロールバックした上で呼び出し元へ伝搬する。
+// また、エラーのあるトランザクションをコミットしようとした場合（PanicCommitDespiteErrInTx）もプログラムの誤りのためpanicとなる。
+func TryTransaction(c context.Context, opts *TxOptions, f func(*sql.Tx) error) error {
+	return transaction(c, DB, opts, f)
+}
+
+// トランザクションの開始、コミット、ロールバックの失敗はisTxFailureを満たすerrorとして返す。
+func transaction(c context.Context, db *sql.DB, opts *TxOptions, f func(*sql.Tx) error) error {
+	tx, err := beginTx(c, db, opts)
+	if err != nil {
+		return err
+	}
+	// コンテキストがキャンセルされた場合、database/sqlによってトランザクションはロールバックされる。
+	// 以降の文の実行はbeforeTxStatementによりエラーとする。
+	registerTx(c, db, tx, opts)
+	defer unregisterTx(tx)
+
 	if err := doAndRecover(c, tx, f); err != nil {
 		// doAndRecover内で「f」の実行時にpanicが発生した場合は、
 		// doAndRecover内でロールバックした上で、panicにしている。
@@ -497,8 +1149,9 @@ func Transaction(c context.Context, f func(*sql.Tx) error) error {
 		// ロールバックに失敗するケースとして、考えられるのは、
 		// ネットワークエラーやDB自体が停止している等。いずれにしても
 		// 更新内容は消失する可能性が高い。（原子性が担保されていれば許容はできる）
-		if err := tx.Rollback(); err != nil {
-			panic(err)
+		// コンテキストのキャンセルにより既にロールバックされている場合は問題ない。
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return &RollbackError{Cause: err, Err: rbErr}
 		}
 		if DumpTransactionRollbackLog {
 			l.Info(c, "rollback end")
@@ -506,9 +1159,28 @@ func Transaction(c context.Context, f func(*sql.Tx) error) error {
 		return err
 	}
 
+	// 無名関数の実行中にコンテキストがキャンセルされた場合はコミットしない。
+	return commitTx(c, tx, opts)
+}
+
+// トランザクションをコミットする。TransactionとTx.Commitで共通の処理。
+// コンテキストがキャンセルされている場合はコミットせず、コンテキストのerrorを返す。
+// コミットに失敗した場合はErrCommitFailedをラップしたerrorを返す。
+func commitTx(c context.Context, tx *sql.Tx, opts *TxOptions) error {
+	if c.Err() != nil {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			return &RollbackError{Cause: c.Err(), Err: err}
+		}
+		return c.Err()
+	}
+
 	// Commitが失敗しても成功してもコネクションはcloseされる。
 	// なお、ロールバックもコミットもせずにcloseをすると、通常はロールバックされるはず。
 	if err := tx.Commit(); err != nil {
+		// コミットの直前にコンテキストがキャンセルされた場合
+		if errors.Is(err, sql.ErrTxDone) && c.Err() != nil {
+			return c.Err()
+		}
 		// トランザクションの中で既にエラーがあるにも関わらず
 		// コミットをしている場合はpgxからErrTxCommitRollbackが返ってくる。
 		// これはプログラムでちゃんとerrをチェックしていないということなので
@@ -516,8 +1188,18 @@ func Transaction(c context.Context, f func(*sql.Tx) error) error {
 		if errors.Is(err, pgx.ErrTxCommitRollback) {
 			panic(PanicCommitDespiteErrInTx)
 		}
+		// SERIALIZABLEのトランザクションでは、コミット時にシリアライゼーションの失敗が検出される場合がある。
+		// これは再実行によって解消するものであるため、panicにはせずerrorとして返す。
+		if e := isAssumedSQLError(err); errors.Is(e, ErrSerializationFailure) {
+			return e
+		}
 		// トランザクション中にエラーが発生せずにコミット時にエラーが出るケースは想定していない。
-		panic(err)
+		// （ネットワークエラーやDB自体が停止している等）
+		return fmt.Errorf("%w: %w", ErrCommitFailed, err)
+	}
+	// 読み取り専用の場合は書き込みが発生しないため、レプリカの読み取りに影響させない。
+	if opts == nil || !opts.ReadOnly {
+		markWrite()
 	}
 	return nil
 }
@@ -122,6 +122,955 @@ func TestSQL(t *testing.T) {
 		testutil.AssertEqual(t, m1.UID, "a")
 		testutil.AssertNotUnTypedNil(t, m2)
 	})
+
+	t.Run("success_select_with_db_and_conn", func(t *testing.T) {
+		l, err := Query(DB, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid=$1", "a")
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, len(l), 1)
+
+		conn, err := DB.Conn(context.Background())
+		if err != nil {
+			t.Fatal("got error")
+		}
+		defer conn.Close()
+		l, err = Query(conn, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid=$1", "a")
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, len(l), 1)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestQueryEach$ ./ssql
+func TestQueryEach(t *testing.T) {
+	refreshDB()
+
+	Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+	Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "bbbb", "b")
+
+	t.Run("success_each", func(t *testing.T) {
+		uids := []string{}
+		err := QueryEach(nil, &TableForTest{}, func(m TableForTest) error {
+			uids = append(uids, m.UID)
+			return nil
+		}, "SELECT * FROM table_for_tests WHERE uid = Any($1) ORDER BY uid", []string{"a", "b"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertDeepEqual(t, uids, []string{"a", "b"})
+	})
+
+	t.Run("stop_by_callback_error", func(t *testing.T) {
+		errStop := errors.New("stop")
+		count := 0
+		err := QueryEach(nil, &TableForTest{}, func(m TableForTest) error {
+			count++
+			return errStop
+		}, "SELECT * FROM table_for_tests WHERE uid = Any($1) ORDER BY uid", []string{"a", "b"})
+		testutil.AssertEqual(t, err, errStop)
+		testutil.AssertEqual(t, count, 1)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestQueryScalar$ ./ssql
+func TestQueryScalar(t *testing.T) {
+	refreshDB()
+
+	Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+	Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "bbbb", "b")
+
+	t.Run("success_count", func(t *testing.T) {
+		c, err := QueryScalar[int64](nil, "SELECT COUNT(*) FROM table_for_tests WHERE uid = Any($1)", []string{"a", "b", "c"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, c, int64(2))
+	})
+
+	t.Run("success_exists", func(t *testing.T) {
+		b, err := QueryScalar[bool](nil, "SELECT EXISTS(SELECT 1 FROM table_for_tests WHERE uid = $1)", "c")
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertFalse(t, b)
+	})
+
+	t.Run("success_nullable", func(t *testing.T) {
+		n, err := QueryScalar[*string](nil, "SELECT MAX(name) FROM table_for_tests WHERE uid = $1", "c")
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertTypedNil(t, n)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestQueryMaps$ ./ssql
+func TestQueryMaps(t *testing.T) {
+	refreshDB()
+
+	Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+	Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "bbbb", "b")
+
+	t.Run("success_maps", func(t *testing.T) {
+		r, err := QueryMaps(nil, "SELECT uid, name FROM table_for_tests WHERE uid = Any($1) ORDER BY uid", []string{"a", "b"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, len(r), 2)
+		testutil.AssertEqual(t, r[0]["uid"], "a")
+		testutil.AssertEqual(t, r[1]["name"], "bbbb")
+	})
+
+	t.Run("success_empty", func(t *testing.T) {
+		r, err := QueryMaps(nil, "SELECT uid FROM table_for_tests WHERE uid = $1", "c")
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, len(r), 0)
+	})
+
+	t.Run("fail_max_result_rows", func(t *testing.T) {
+		cfg := DefaultGuardConfig
+		cfg.MaxResultRows = 1
+		_, err := QueryMaps(nil, "SELECT uid, name FROM table_for_tests WHERE uid = Any($1)", []string{"a", "b"}, WithGuardConfig(cfg))
+		testutil.AssertTrue(t, errors.Is(err, ErrTooManyRows))
+
+		_, err = Query(nil, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid = Any($1)", []string{"a", "b"}, WithGuardConfig(cfg))
+		testutil.AssertTrue(t, errors.Is(err, ErrTooManyRows))
+
+		r, err := QueryColumn[string](nil, "SELECT uid FROM table_for_tests WHERE uid = $1", "a", WithGuardConfig(cfg))
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, len(r), 1)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestExport$ ./ssql
+func TestExport(t *testing.T) {
+	refreshDB()
+
+	Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aa,\"a\"", "a")
+	Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", nil, "b")
+
+	t.Run("success_csv", func(t *testing.T) {
+		var b strings.Builder
+		err := ExportCSV(nil, &b, "SELECT uid, name FROM table_for_tests WHERE uid = Any($1) ORDER BY uid", []string{"a", "b"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, b.String(), "uid,name\na,\"aa,\"\"a\"\"\"\nb,\n")
+	})
+
+	t.Run("success_json", func(t *testing.T) {
+		var b strings.Builder
+		err := ExportJSON(nil, &b, "SELECT uid, name FROM table_for_tests WHERE uid = Any($1) ORDER BY uid", []string{"a", "b"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, b.String(), `[{"uid":"a","name":"aa,\"a\""},{"uid":"b","name":null}]`)
+	})
+
+	t.Run("success_json_empty", func(t *testing.T) {
+		var b strings.Builder
+		err := ExportJSON(nil, &b, "SELECT uid FROM table_for_tests WHERE uid = $1", "c")
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, b.String(), "[]")
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestQueryColumn$ ./ssql
+func TestQueryColumn(t *testing.T) {
+	refreshDB()
+
+	Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+	Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "bbbb", "b")
+
+	t.Run("success_uid", func(t *testing.T) {
+		r, err := QueryColumn[string](nil, "SELECT uid FROM table_for_tests WHERE uid = Any($1) ORDER BY uid", []string{"a", "b"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertDeepEqual(t, r, []string{"a", "b"})
+	})
+
+	t.Run("success_id", func(t *testing.T) {
+		r, err := QueryColumn[uuid.UUID](nil, "SELECT id FROM table_for_tests WHERE uid = Any($1)", []string{"a", "b"})
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, len(r), 2)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestColumnMapping$ ./ssql
+func TestColumnMapping(t *testing.T) {
+	refreshDB()
+
+	Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+
+	type uidOnly struct {
+		UID string `database:"uid"`
+	}
+
+	t.Run("panic_default", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatalf("should get panic")
+			}
+		}()
+		Query(nil, &uidOnly{}, "SELECT * FROM table_for_tests WHERE uid = $1", "a")
+	})
+
+	t.Run("success_lenient", func(t *testing.T) {
+		r, err := Query(nil, &uidOnly{}, "SELECT * FROM table_for_tests WHERE uid = $1", "a", WithColumnMapping(ColumnMappingLenient))
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, len(r), 1)
+		testutil.AssertEqual(t, r[0].UID, "a")
+	})
+
+	t.Run("panic_strict", func(t *testing.T) {
+		var r interface{}
+		defer func() {
+			if r = recover(); r == nil {
+				t.Fatalf("should get panic")
+			}
+			testutil.AssertEqual(t, r, "result does not have model field: name")
+		}()
+		Query(nil, &TableForTest{}, "SELECT id, uid, is_active, created_at, updated_at FROM table_for_tests WHERE uid = $1", "a", WithColumnMapping(ColumnMappingStrict))
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestQueryTimeout$ ./ssql
+func TestQueryTimeout(t *testing.T) {
+	q := "SELECT pg_sleep(1) WHERE '" + SeqScanCheckDisableClause + "'='" + SeqScanCheckDisableClause + "'"
+
+	t.Run("success_within_timeout", func(t *testing.T) {
+		_, err := QueryMaps(nil, q, WithTimeout(3*time.Second))
+		if err != nil {
+			t.Fatal("got error")
+		}
+	})
+
+	t.Run("canceled_by_timeout", func(t *testing.T) {
+		_, err := QueryMaps(nil, q, WithTimeout(100*time.Millisecond))
+		testutil.AssertTrue(t, errors.Is(err, ErrQueryTimeout))
+		testutil.AssertTrue(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("canceled_by_context", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+		_, err := QueryMaps(nil, q, WithContext(c))
+		testutil.AssertTrue(t, errors.Is(err, ErrQueryCanceled))
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestPrepared$ ./ssql
+func TestPrepared(t *testing.T) {
+	refreshDB()
+	ClearStmtCache()
+
+	t.Run("success_prepared_exec_and_query", func(t *testing.T) {
+		_, err := PreparedExec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+		if err != nil {
+			t.Fatal("got error")
+		}
+		for range 2 {
+			r, err := PreparedQuery(nil, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid = $1", "a")
+			if err != nil {
+				t.Fatal("got error")
+			}
+			testutil.AssertEqual(t, len(r), 1)
+		}
+		testutil.AssertEqual(t, stmts.ll.Len(), 2)
+	})
+
+	t.Run("success_in_transaction", func(t *testing.T) {
+		err := Transaction(context.Background(), func(tx *sql.Tx) error {
+			_, err := Exec(Prepared(tx), "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "bbbb", "b")
+			if err != nil {
+				return err
+			}
+			_, err = First(Prepared(tx), &TableForTest{}, []string{"uid = ?"}, []any{"b"})
+			return err
+		})
+		testutil.AssertEqual(t, err, nil)
+	})
+
+	t.Run("evict_lru", func(t *testing.T) {
+		size := StmtCacheSize
+		StmtCacheSize = 1
+		defer func() { StmtCacheSize = size }()
+
+		// 新しいステートメントを作成した時点で古いものが追い出される。
+		_, err := PreparedQuery(nil, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid = $1 AND is_active = true", "a")
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, stmts.ll.Len(), 1)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestQueryInBatches$ ./ssql
+func TestQueryInBatches(t *testing.T) {
+	refreshDB()
+
+	uids := []string{}
+	for i := range 5 {
+		uid := strconv.Itoa(i)
+		Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", uid)
+		uids = append(uids, uid)
+	}
+
+	t.Run("success_batches", func(t *testing.T) {
+		r, err := QueryInBatches(nil, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid = ANY($1) AND is_active = $2", append(uids, "x"), 2, true)
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, len(r), 5)
+	})
+
+	t.Run("success_empty_keys", func(t *testing.T) {
+		r, err := QueryInBatches(nil, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid = ANY($1)", []string{}, 0)
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, len(r), 0)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestBatch$ ./ssql
+func TestBatch(t *testing.T) {
+	refreshDB()
+
+	t.Run("success_batch", func(t *testing.T) {
+		b := NewBatch()
+		r1 := b.QueueExec("INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+		r2 := b.QueueExec("INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "bbbb", "b")
+		r3 := QueueQuery(b, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid = ANY($1)", []string{"a", "b"})
+		testutil.AssertEqual(t, b.Len(), 3)
+
+		err := b.Send(nil)
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, r1.RowsAffected(), int64(1))
+		testutil.AssertEqual(t, r2.RowsAffected(), int64(1))
+		testutil.AssertEqual(t, len(r3.Rows()), 2)
+	})
+
+	t.Run("uniq_error", func(t *testing.T) {
+		b := NewBatch()
+		b.QueueExec("INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "cccc", "c")
+		b.QueueExec("INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+		testutil.AssertTrue(t, errors.Is(b.Send(nil), ErrUniqConstraint))
+
+		// 暗黙のトランザクション内で実行されるため、1件目もロールバックされる。
+		r, _ := Query(nil, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid = $1", "c")
+		testutil.AssertEqual(t, len(r), 0)
+	})
+
+	t.Run("panic_not_supported_tx", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatalf("should get panic")
+			}
+		}()
+		Transaction(context.Background(), func(tx *sql.Tx) error {
+			b := NewBatch()
+			b.QueueExec("INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "dddd", "d")
+			return b.Send(tx)
+		})
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestBatchGuard$ ./ssql
+func TestBatchGuard(t *testing.T) {
+	cfg := DefaultGuardConfig
+	cfg.ViolationAsError = true
+
+	t.Run("violation as error", func(t *testing.T) {
+		b := NewBatch()
+		b.QueueExec("DELETE FROM table_for_tests", WithGuardConfig(cfg))
+		testutil.AssertTrue(t, errors.Is(b.Send(nil), ErrGuardViolation))
+	})
+
+	t.Run("too many parameters", func(t *testing.T) {
+		org := MaxBindParameters
+		defer func() { MaxBindParameters = org }()
+		MaxBindParameters = 1
+		b := NewBatch()
+		QueueQuery(b, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid = $1 OR uid = $2", "a", "b")
+		testutil.AssertTrue(t, errors.Is(b.Send(nil), ErrTooManyParameters))
+	})
+
+	t.Run("log only", func(t *testing.T) {
+		GuardViolationLogOnly = true
+		defer func() { GuardViolationLogOnly = false }()
+		b := NewBatch()
+		b.QueueExec("DELETE FROM table_for_tests")
+		testutil.AssertEqual(t, b.Len(), 1)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestExecReturning$ ./ssql
+func TestExecReturning(t *testing.T) {
+	refreshDB()
+
+	t.Run("success_insert_returning", func(t *testing.T) {
+		m := &TableForTest{}
+		r, err := ExecReturning(nil, m, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2) RETURNING *", "aaaa", "a")
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, len(r), 1)
+		testutil.AssertEqual(t, m.UID, "a")
+		testutil.AssertNotEqual(t, m.ID, uuid.Nil)
+		testutil.AssertFalse(t, m.CreatedAt.IsZero())
+	})
+
+	t.Run("success_update_returning", func(t *testing.T) {
+		r, err := ExecReturning(nil, &TableForTest{}, "UPDATE table_for_tests SET name = $1, updated_at = now() WHERE uid = $2 RETURNING *", "bbbb", "a")
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, len(r), 1)
+		testutil.AssertEqual(t, *r[0].Name, "bbbb")
+	})
+
+	t.Run("uniq_error", func(t *testing.T) {
+		_, err := ExecReturning(nil, &TableForTest{}, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2) RETURNING *", "aaaa", "a")
+		testutil.AssertTrue(t, errors.Is(err, ErrUniqConstraint))
+	})
+
+	t.Run("panic_without_returning", func(t *testing.T) {
+		var r interface{}
+		defer func() {
+			if r = recover(); r == nil {
+				t.Fatalf("should get panic")
+			}
+			testutil.AssertEqual(t, r, PanicExecReturningMustHaveReturning)
+		}()
+		ExecReturning(nil, &TableForTest{}, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "cccc", "c")
+	})
+
+	t.Run("panic_too_many_affected_rows", func(t *testing.T) {
+		Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "bbbb", "b")
+		cfg := DefaultGuardConfig
+		cfg.MaxAffectedRows = 1
+
+		_, err := Exec(nil, "UPDATE table_for_tests SET name = $1, updated_at = now() WHERE uid = $2", "dddd", "a", WithGuardConfig(cfg))
+		testutil.AssertEqual(t, err, nil)
+
+		var r interface{}
+		defer func() {
+			if r = recover(); r == nil {
+				t.Fatalf("should get panic")
+			}
+			testutil.AssertContainStr(t, r, "update/delete would affect 2 rows")
+			u, _ := QueryFirst(nil, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid = $1", "b")
+			testutil.AssertEqual(t, *u.Name, "bbbb")
+		}()
+		ExecReturning(nil, &TableForTest{}, "UPDATE table_for_tests SET name = $1, updated_at = now() WHERE uid = ANY($2) RETURNING *", "eeee", []string{"a", "b"}, WithGuardConfig(cfg))
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestExecExpectRows$ ./ssql
+func TestExecExpectRows(t *testing.T) {
+	refreshDB()
+	Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+	Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "bbbb", "b")
+
+	t.Run("success", func(t *testing.T) {
+		r, err := ExecExpectRows(nil, 1, "UPDATE table_for_tests SET name = $1, updated_at = now() WHERE uid = $2", "cccc", "a")
+		testutil.AssertEqual(t, err, nil)
+		n, _ := r.RowsAffected()
+		testutil.AssertEqual(t, n, int64(1))
+	})
+
+	t.Run("fail_no_rows", func(t *testing.T) {
+		_, err := ExecExpectRows(nil, 1, "UPDATE table_for_tests SET name = $1, updated_at = now() WHERE uid = $2", "cccc", "z")
+		testutil.AssertTrue(t, errors.Is(err, ErrUnexpectedRowCount))
+		testutil.AssertContainStr(t, err.Error(), "expected 1, got 0")
+	})
+
+	t.Run("rollback_in_transaction", func(t *testing.T) {
+		err := Transaction(context.Background(), func(tx *sql.Tx) error {
+			_, err := ExecExpectRows(tx, 1, "UPDATE table_for_tests SET name = $1, updated_at = now() WHERE uid = ANY($2)", "dddd", []string{"a", "b"})
+			return err
+		})
+		testutil.AssertTrue(t, errors.Is(err, ErrUnexpectedRowCount))
+
+		u, _ := QueryFirst(nil, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid = $1", "b")
+		testutil.AssertEqual(t, *u.Name, "bbbb")
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestDescribe$ ./ssql
+func TestDescribe(t *testing.T) {
+	refreshDB()
+
+	t.Run("success", func(t *testing.T) {
+		cols, err := Describe(nil, "SELECT id, uid, created_at FROM table_for_tests WHERE uid = $1", "a")
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, len(cols), 3)
+		testutil.AssertEqual(t, cols[0].Name, "id")
+		testutil.AssertEqual(t, cols[0].DatabaseType, "UUID")
+		testutil.AssertEqual(t, cols[1].Name, "uid")
+		testutil.AssertEqual(t, cols[2].Name, "created_at")
+		testutil.AssertEqual(t, cols[2].DatabaseType, "TIMESTAMPTZ")
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestMust$ ./ssql
+func TestMust(t *testing.T) {
+	refreshDB()
+
+	t.Run("success", func(t *testing.T) {
+		MustExec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+		l := MustQuery(nil, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid = $1", "a")
+		testutil.AssertEqual(t, len(l), 1)
+		testutil.AssertEqual(t, MustQueryFirst(nil, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid = $1", "a").UID, "a")
+		testutil.AssertEqual(t, MustQueryScalar[int64](nil, "SELECT COUNT(*) FROM table_for_tests WHERE uid = $1", "a"), int64(1))
+	})
+
+	t.Run("panic_on_error", func(t *testing.T) {
+		defer func() {
+			err, _ := recover().(error)
+			testutil.AssertTrue(t, errors.Is(err, ErrUniqConstraint))
+		}()
+		MustExec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestQueryCache$ ./ssql
+func TestQueryCache(t *testing.T) {
+	refreshDB()
+	defer QueryCache.Clear()
+
+	Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+
+	t.Run("cached until exec", func(t *testing.T) {
+		l, err := Query(nil, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid = $1", "a", WithCache(time.Minute))
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, *l[0].Name, "aaaa")
+
+		// ssqlを経由しない更新はキャッシュに反映されない
+		if _, err := DB.Exec("UPDATE table_for_tests SET name = $1 WHERE uid = $2", "bbbb", "a"); err != nil {
+			t.Fatal(err)
+		}
+		l, _ = Query(nil, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid = $1", "a", WithCache(time.Minute))
+		testutil.AssertEqual(t, *l[0].Name, "aaaa")
+
+		// Execで同じテーブルを更新した場合はキャッシュが削除される
+		Exec(nil, "UPDATE table_for_tests SET name = $1, updated_at = now() WHERE uid = $2", "cccc", "a")
+		l, _ = Query(nil, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid = $1", "a", WithCache(time.Minute))
+		testutil.AssertEqual(t, *l[0].Name, "cccc")
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestQueryJoin$ ./ssql
+func TestQueryJoin(t *testing.T) {
+	refreshDB()
+
+	type tableForTestPair struct {
+		A TableForTest `join:"a"`
+		B TableForTest `join:"b"`
+	}
+
+	Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2), ($3, $4)", "aaaa", "a", "bbbb", "b")
+
+	t.Run("success", func(t *testing.T) {
+		l, err := Query(nil, &tableForTestPair{}, "SELECT "+JoinColumns(&tableForTestPair{})+
+			" FROM table_for_tests a JOIN table_for_tests b ON b.uid <> a.uid WHERE a.uid = $1", "a")
+		if err != nil {
+			t.Fatal("got error")
+		}
+		testutil.AssertEqual(t, len(l), 1)
+		testutil.AssertEqual(t, l[0].A.UID, "a")
+		testutil.AssertEqual(t, l[0].B.UID, "b")
+		testutil.AssertEqual(t, *l[0].B.Name, "bbbb")
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestTransactionWithOptions$ ./ssql
+func TestTransactionWithOptions(t *testing.T) {
+	refreshDB()
+
+	t.Run("success_isolation_level", func(t *testing.T) {
+		var level string
+		err := TransactionWithOptions(context.Background(), &TxOptions{Isolation: sql.LevelSerializable}, func(tx *sql.Tx) error {
+			var err error
+			level, err = QueryScalar[string](tx, "SELECT current_setting('transaction_isolation')")
+			return err
+		})
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, level, "serializable")
+	})
+
+	t.Run("success_default_level", func(t *testing.T) {
+		var level string
+		err := TransactionWithOptions(context.Background(), nil, func(tx *sql.Tx) error {
+			var err error
+			level, err = QueryScalar[string](tx, "SELECT current_setting('transaction_isolation')")
+			return err
+		})
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, level, "read committed")
+	})
+
+	t.Run("panic_write_in_read_only", func(t *testing.T) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				t.Fatalf("should get panic")
+			}
+			testutil.AssertContainStr(t, r, "read-only transaction")
+
+			c, err := QueryScalar[int64](nil, "SELECT COUNT(*) FROM table_for_tests WHERE uid = $1", "a")
+			testutil.AssertEqual(t, err, nil)
+			testutil.AssertEqual(t, c, int64(0))
+		}()
+		TransactionWithOptions(context.Background(), &TxOptions{ReadOnly: true}, func(tx *sql.Tx) error {
+			_, err := Exec(tx, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+			return err
+		})
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestTransactionFromContext$ ./ssql
+func TestTransactionFromContext(t *testing.T) {
+	refreshDB()
+
+	t.Run("rollback_query_via_context", func(t *testing.T) {
+		errRollback := errors.New("rollback")
+		err := Transaction(context.Background(), func(tx *sql.Tx) error {
+			c := ContextWithTx(context.Background(), tx)
+			_, err := Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a", WithContext(c))
+			testutil.AssertEqual(t, err, nil)
+
+			// 同じトランザクション内のため、コミット前の行を参照できる。
+			u, err := First(nil, &TableForTest{}, []string{"uid = ?"}, []any{"a"}, WithContext(c))
+			testutil.AssertEqual(t, err, nil)
+			testutil.AssertEqual(t, *u.Name, "aaaa")
+			return errRollback
+		})
+		testutil.AssertEqual(t, err, errRollback)
+
+		c, err := Count(nil, &TableForTest{}, []string{"uid = ?"}, []any{"a"})
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, c, int64(0))
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestTransactionWithRetry$ ./ssql
+func TestTransactionWithRetry(t *testing.T) {
+	refreshDB()
+
+	Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+
+	t.Run("success_retry_serialization_failure", func(t *testing.T) {
+		opts := &TxOptions{Isolation: sql.LevelSerializable}
+		p := RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond}
+		attempts := 0
+		err := TransactionWithRetry(context.Background(), opts, p, func(tx *sql.Tx) error {
+			attempts++
+			_, err := Query(tx, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid = $1", "a")
+			if err != nil {
+				return err
+			}
+			if attempts == 1 {
+				// 読み取った行を別のトランザクションで更新し、シリアライゼーションの失敗を起こす。
+				_, err := Exec(nil, "UPDATE table_for_tests SET name = $1, updated_at = now() WHERE uid = $2", "bbbb", "a")
+				testutil.AssertEqual(t, err, nil)
+			}
+			_, err = Exec(tx, "UPDATE table_for_tests SET name = $1, updated_at = now() WHERE uid = $2", "cccc", "a")
+			return err
+		})
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, attempts, 2)
+
+		u, err := First(nil, &TableForTest{}, []string{"uid = ?"}, []any{"a"})
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, *u.Name, "cccc")
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestTransactionCanceled$ ./ssql
+func TestTransactionCanceled(t *testing.T) {
+	refreshDB()
+
+	t.Run("canceled_between_statements", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		var execErr error
+		err := Transaction(c, func(tx *sql.Tx) error {
+			_, err := Exec(tx, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+			testutil.AssertEqual(t, err, nil)
+
+			cancel()
+			_, execErr = Exec(tx, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "bbbb", "b")
+			return execErr
+		})
+		testutil.AssertEqual(t, execErr, context.Canceled)
+		testutil.AssertEqual(t, err, context.Canceled)
+
+		n, err := Count(nil, &TableForTest{}, []string{"uid = Any(?)"}, []any{[]string{"a", "b"}})
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, n, int64(0))
+	})
+
+	t.Run("canceled_before_commit", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		err := Transaction(c, func(tx *sql.Tx) error {
+			_, err := Exec(tx, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+			cancel()
+			return err
+		})
+		testutil.AssertEqual(t, err, context.Canceled)
+
+		n, err := Count(nil, &TableForTest{}, []string{"uid = ?"}, []any{"a"})
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, n, int64(0))
+	})
+
+	t.Run("canceled_before_begin", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		cancel()
+		called := false
+		err := Transaction(c, func(tx *sql.Tx) error {
+			called = true
+			return nil
+		})
+		testutil.AssertEqual(t, err, context.Canceled)
+		testutil.AssertFalse(t, called)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestManualTx$ ./ssql
+func TestManualTx(t *testing.T) {
+	refreshDB()
+
+	count := func(uid string) int64 {
+		n, err := Count(nil, &TableForTest{}, []string{"uid = ?"}, []any{uid})
+		testutil.AssertEqual(t, err, nil)
+		return n
+	}
+
+	t.Run("success_commit", func(t *testing.T) {
+		tx, err := Begin(context.Background(), nil)
+		testutil.AssertEqual(t, err, nil)
+		defer tx.Rollback()
+
+		_, err = Exec(tx, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, count("a"), int64(0))
+
+		testutil.AssertEqual(t, tx.Commit(), nil)
+		testutil.AssertEqual(t, count("a"), int64(1))
+		// コミット後のロールバックは何もしない。
+		testutil.AssertEqual(t, tx.Rollback(), nil)
+	})
+
+	t.Run("success_rollback", func(t *testing.T) {
+		tx, err := Begin(context.Background(), nil)
+		testutil.AssertEqual(t, err, nil)
+
+		_, err = Exec(tx, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "bbbb", "b")
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, tx.Rollback(), nil)
+		testutil.AssertEqual(t, count("b"), int64(0))
+	})
+
+	t.Run("success_savepoint", func(t *testing.T) {
+		tx, err := Begin(context.Background(), nil)
+		testutil.AssertEqual(t, err, nil)
+		defer tx.Rollback()
+
+		_, err = Exec(tx, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "cccc", "c")
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, tx.Savepoint("sp1"), nil)
+
+		// ユニーク制約違反の後もセーブポイントまで戻せば処理を続行できる。
+		_, err = Exec(tx, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "cccc", "c")
+		testutil.AssertTrue(t, errors.Is(err, ErrUniqConstraint))
+		testutil.AssertEqual(t, tx.RollbackTo("sp1"), nil)
+
+		_, err = Exec(tx, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "dddd", "d")
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, tx.ReleaseSavepoint("sp1"), nil)
+		testutil.AssertEqual(t, tx.Commit(), nil)
+
+		testutil.AssertEqual(t, count("c"), int64(1))
+		testutil.AssertEqual(t, count("d"), int64(1))
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestTransactionTimeout$ ./ssql
+func TestTransactionTimeout(t *testing.T) {
+	refreshDB()
+
+	Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+
+	t.Run("lock_timeout", func(t *testing.T) {
+		locked := make(chan struct{})
+		release := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Transaction(context.Background(), func(tx *sql.Tx) error {
+				_, err := Query(tx, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid = $1 FOR UPDATE", "a")
+				testutil.AssertEqual(t, err, nil)
+				close(locked)
+				<-release
+				return nil
+			})
+		}()
+		<-locked
+
+		start := time.Now()
+		err := TransactionWithOptions(context.Background(), &TxOptions{LockTimeout: 100 * time.Millisecond}, func(tx *sql.Tx) error {
+			_, err := Query(tx, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid = $1 FOR UPDATE", "a")
+			return err
+		})
+		testutil.AssertTrue(t, errors.Is(err, ErrLockNotAvailable))
+		testutil.AssertEqual(t, time.Since(start) < 5*time.Second, true)
+
+		close(release)
+		wg.Wait()
+	})
+
+	t.Run("statement_timeout", func(t *testing.T) {
+		q := "SELECT pg_sleep(1) WHERE '" + SeqScanCheckDisableClause + "'='" + SeqScanCheckDisableClause + "'"
+		err := TransactionWithOptions(context.Background(), &TxOptions{StatementTimeout: 100 * time.Millisecond}, func(tx *sql.Tx) error {
+			_, err := QueryMaps(tx, q)
+			return err
+		})
+		testutil.AssertTrue(t, errors.Is(err, ErrStatementTimeout))
+	})
+
+	t.Run("settings_are_local", func(t *testing.T) {
+		var v string
+		err := TransactionWithOptions(context.Background(), &TxOptions{LockTimeout: 1500 * time.Millisecond}, func(tx *sql.Tx) error {
+			var err error
+			v, err = QueryScalar[string](tx, "SELECT current_setting('lock_timeout')")
+			return err
+		})
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, v, "1500ms")
+
+		v, err = QueryScalar[string](nil, "SELECT current_setting('statement_timeout')")
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, v, "0")
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestRollbackFailure$ ./ssql
+func TestRollbackFailure(t *testing.T) {
+	t.Run("keep_original_panic", func(t *testing.T) {
+		defer func() {
+			r := recover()
+			e, ok := r.(*RollbackError)
+			if !ok {
+				t.Fatalf("should get RollbackError, but got %v", r)
+			}
+			testutil.AssertEqual(t, e.Cause, "original panic")
+			testutil.AssertEqual(t, e.Err == nil, false)
+		}()
+		Transaction(context.Background(), func(tx *sql.Tx) error {
+			// コネクションを切断してロールバックを失敗させる。
+			tx.ExecContext(context.Background(), "SELECT pg_terminate_backend(pg_backend_pid())")
+			panic("original panic")
+		})
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestTryTransaction$ ./ssql
+func TestTryTransaction(t *testing.T) {
+	refreshDB()
+
+	t.Run("success", func(t *testing.T) {
+		err := TryTransaction(context.Background(), nil, func(tx *sql.Tx) error {
+			_, err := Exec(tx, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+			return err
+		})
+		testutil.AssertEqual(t, err, nil)
+	})
+
+	t.Run("begin_failed", func(t *testing.T) {
+		org := DB
+		defer func() { DB = org }()
+		var err error
+		DB, err = sql.Open("pgx", "user=test password=test host=127.0.0.1 port=1 dbname=test_db sslmode=disable connect_timeout=1")
+		testutil.AssertEqual(t, err, nil)
+		defer DB.Close()
+
+		called := false
+		err = TryTransaction(context.Background(), nil, func(tx *sql.Tx) error {
+			called = true
+			return nil
+		})
+		testutil.AssertTrue(t, errors.Is(err, ErrBeginFailed))
+		testutil.AssertFalse(t, called)
+	})
+
+	t.Run("rollback_failed", func(t *testing.T) {
+		errInTx := errors.New("error in tx")
+		err := TryTransaction(context.Background(), nil, func(tx *sql.Tx) error {
+			// コネクションを切断してロールバックを失敗させる。
+			tx.ExecContext(context.Background(), "SELECT pg_terminate_backend(pg_backend_pid())")
+			return errInTx
+		})
+		testutil.AssertTrue(t, errors.Is(err, ErrRollbackFailed))
+		testutil.AssertTrue(t, errors.Is(err, errInTx))
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestReadTransaction$ ./ssql
+func TestReadTransaction(t *testing.T) {
+	refreshDB()
+
+	Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaa", "a")
+
+	t.Run("success_read", func(t *testing.T) {
+		var readOnly string
+		var users []TableForTest
+		err := ReadTransaction(context.Background(), func(tx *sql.Tx) error {
+			var err error
+			readOnly, err = QueryScalar[string](tx, "SELECT current_setting('transaction_read_only')")
+			if err != nil {
+				return err
+			}
+			users, err = Find(tx, &TableForTest{}, []string{"uid = ?"}, []any{"a"})
+			return err
+		})
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, readOnly, "on")
+		testutil.AssertEqual(t, len(users), 1)
+	})
+
+	t.Run("panic_exec", func(t *testing.T) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				t.Fatalf("should get panic")
+			}
+			testutil.AssertContainStr(t, r, "read-only transaction")
+		}()
+		ReadTransaction(context.Background(), func(tx *sql.Tx) error {
+			_, err := Exec(tx, "UPDATE table_for_tests SET name = $1, updated_at = now() WHERE uid = $2", "bbbb", "a")
+			return err
+		}, WithPrimary())
+	})
 }
 
 // ユニーク制約エラーのハンドリング
@@ -141,9 +1090,28 @@ func TestUniqError(t *testing.T) {
 		if err == nil {
 			t.Fatal("should got error")
 		}
-		testutil.AssertEqual(t, err, ErrUniqConstraint)
+		testutil.AssertTrue(t, errors.Is(err, ErrUniqConstraint))
 		dv(err)
 	})
+
+	t.Run("uniq_error_detail", func(t *testing.T) {
+		_, err := Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaaaa", "a")
+		var e *SQLError
+		testutil.AssertTrue(t, errors.As(err, &e))
+		testutil.AssertEqual(t, e.Code, PostgresErrCodeUniqConstraint)
+		testutil.AssertEqual(t, e.ConstraintName, "uniq__table_for_tests__uid")
+		testutil.AssertEqual(t, e.TableName, "table_for_tests")
+	})
+
+	t.Run("uniq_error_registered", func(t *testing.T) {
+		errUIDTaken := errors.New("uid is already taken")
+		RegisterConstraintError("uniq__table_for_tests__uid", errUIDTaken)
+		defer RegisterConstraintError("uniq__table_for_tests__uid", nil)
+
+		_, err := Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaaaa", "a")
+		testutil.AssertTrue(t, errors.Is(err, errUIDTaken))
+		testutil.AssertTrue(t, errors.Is(err, ErrUniqConstraint))
+	})
 }
 
 // トランザクションブロックにおけるユニーク制約エラー
@@ -203,7 +1171,7 @@ func TestUniqErrorAtCommit(t *testing.T) {
 				_, err := Exec(tx, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaaaa", uid)
 				d("g2 insert done")
 				dv(err)
-				testutil.AssertEqual(t, err, ErrUniqConstraint) // uniq制約違反になる。
+				testutil.AssertTrue(t, errors.Is(err, ErrUniqConstraint)) // uniq制約違反になる。
 
 				if err != nil {
 					return err
@@ -211,7 +1179,7 @@ func TestUniqErrorAtCommit(t *testing.T) {
 
 				return nil
 			})
-			testutil.AssertEqual(t, err, ErrUniqConstraint) // uniq制約違反になる。
+			testutil.AssertTrue(t, errors.Is(err, ErrUniqConstraint)) // uniq制約違反になる。
 			d("g2 commit done")
 			wg.Done()
 		}()
@@ -571,7 +1539,7 @@ func TestLockError(t *testing.T) {
 			}
 			return nil
 		})
-		testutil.AssertEqual(t, err, ErrLockNotAvailable)
+		testutil.AssertTrue(t, errors.Is(err, ErrLockNotAvailable))
 		wg.Wait()
 	})
 
@@ -604,7 +1572,7 @@ func TestInvalidSQL(t *testing.T) {
 			}
 			testutil.AssertEqual(t, r, PanicLockingReadMustUseNowait)
 		}()
-		_, err := Query(nil, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid=a FOR SELECT")
+		_, err := Query(nil, &TableForTest{}, "SELECT * FROM table_for_tests WHERE uid='a' FOR SHARE")
 		if err != nil {
 			t.Fatalf("should not get error")
 		}
@@ -692,15 +1660,9 @@ func TestColumnConstraint(t *testing.T) {
 	})
 
 	t.Run("over_length_varchar", func(t *testing.T) {
-		var r interface{}
-		defer func() {
-			if r = recover(); r == nil {
-				t.Fatalf("should get panic")
-			}
-			testutil.AssertContainStr(t, r, "SQLSTATE 22001")
-		}()
-
-		Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aa", "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa") //501文字
+		_, err := Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aa", "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa") //501文字
+		testutil.AssertTrue(t, errors.Is(err, ErrValueTooLong))
+		testutil.AssertContainStr(t, err.Error(), "SQLSTATE 22001")
 	})
 }
 
@@ -712,7 +1674,7 @@ func TestIsNotSeqScanSQL(t *testing.T) {
 			if r = recover(); r == nil {
 				t.Fatalf("should get panic")
 			}
-			testutil.AssertEqual(t, r, fmt.Sprintf(PanicSQLIsSeqScan, "SELECT * FROM table_for_tests WHERE name = $1"))
+			testutil.AssertEqual(t, r, fmt.Sprintf(PanicSQLIsSeqScan, "SELECT * FROM table_for_tests WHERE name = $1")+" (suggested index: CREATE INDEX ON table_for_tests (name))")
 		}()
 		_, err := Query(nil, &TableForTest{}, "SELECT * FROM table_for_tests WHERE name = $1", "aaaaa")
 		if err != nil {
@@ -734,6 +1696,101 @@ func TestIsNotSeqScanSQL(t *testing.T) {
 		testutil.AssertFalse(t, CheckSeqScan("SELECT name FROM table_for_tests WHERE name = ANY(SELECT name FROM table_for_tests WHERE name = ANY(SELECT name FROM table_for_tests WHERE name = ANY(SELECT name FROM table_for_tests WHERE name = ANY(SELECT name FROM table_for_tests WHERE name = ANY(SELECT name FROM table_for_tests WHERE name = ANY(SELECT name FROM table_for_tests WHERE name = ANY(SELECT name FROM table_for_tests WHERE name = ANY(SELECT name FROM table_for_tests WHERE name = ANY(SELECT name FROM table_for_tests WHERE name = $1)))))))))", "aaaaa"))
 		testutil.AssertFalse(t, CheckSeqScan("SELECT name FROM table_for_tests WHERE name = ANY(SELECT name FROM table_for_tests WHERE name = ANY(SELECT name FROM table_for_tests WHERE name = ANY(SELECT name FROM table_for_tests WHERE name = ANY(SELECT name FROM table_for_tests WHERE name = ANY(SELECT name FROM table_for_tests WHERE name = ANY(SELECT name FROM table_for_tests WHERE name = ANY(SELECT name FROM table_for_tests WHERE name = ANY(SELECT name FROM table_for_tests WHERE name = ANY(SELECT name FROM table_for_tests WHERE name = ANY(SELECT name FROM table_for_tests WHERE name = $1))))))))))", "aaaaa"))
 	})
+
+	t.Run("allow_seq_scan", func(t *testing.T) {
+		_, err := Query(nil, &TableForTest{}, "SELECT * FROM table_for_tests WHERE name = $1", "aaaaa", AllowSeqScan())
+		testutil.AssertEqual(t, err, nil)
+	})
+
+	t.Run("seq_scan_threshold", func(t *testing.T) {
+		cfg := DefaultGuardConfig
+		cfg.SeqScanCostThreshold = 1.0e9
+		_, err := Query(nil, &TableForTest{}, "SELECT * FROM table_for_tests WHERE name = $1", "aaaaa", WithGuardConfig(cfg))
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertTrue(t, checkSeqScan(cfg, nil, "SELECT name FROM table_for_tests WHERE name = $1", "aaaaa"))
+
+		cfg.SeqScanCostThreshold = 0.1
+		testutil.AssertFalse(t, checkSeqScan(cfg, nil, "SELECT name FROM table_for_tests WHERE name = $1", "aaaaa"))
+	})
+
+	t.Run("seq_scan_check_before_exec", func(t *testing.T) {
+		refreshDB()
+		Exec(nil, "INSERT INTO table_for_tests (name, uid) VALUES ($1, $2)", "aaaaa", "a", AllowSeqScan())
+		cfg := DefaultGuardConfig
+		cfg.SeqScanCheckBeforeExec = true
+
+		func() {
+			defer func() {
+				testutil.AssertEqual(t, recover(), fmt.Sprintf(PanicSQLIsSeqScan, "DELETE FROM table_for_tests WHERE name = $1")+" (suggested index: CREATE INDEX ON table_for_tests (name))")
+			}()
+			Exec(nil, "DELETE FROM table_for_tests WHERE name = $1", "aaaaa", WithGuardConfig(cfg))
+		}()
+
+		// 実行前のチェックのため、GuardViolationAsErrorの場合はerrorとなる。
+		func() {
+			org := GuardViolationAsError
+			defer func() { GuardViolationAsError = org }()
+			GuardViolationAsError = true
+			_, err := Exec(nil, "DELETE FROM table_for_tests WHERE name = $1", "aaaaa", WithGuardConfig(cfg))
+			testutil.AssertTrue(t, errors.Is(err, ErrGuardViolation))
+		}()
+
+		// 違反した文は実行されない
+		n, err := Count(nil, &TableForTest{}, []string{"uid = ?"}, []any{"a"}, AllowSeqScan())
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertEqual(t, n, int64(1))
+	})
+
+	t.Run("plan_rule", func(t *testing.T) {
+		cfg := DefaultGuardConfig
+		cfg.PlanRules = []PlanRule{{Name: "limit", Match: func(n *PlanNode) bool { return n.NodeType == "Limit" }}}
+		query := "SELECT * FROM table_for_tests WHERE name = $1 LIMIT 1"
+		defer func() {
+			testutil.AssertEqual(t, recover(), fmt.Sprintf(PanicPlanRuleViolation, "limit", "Limit", query))
+		}()
+		// AllowSeqScanを指定した場合もルールはチェックされる。
+		Query(nil, &TableForTest{}, query, "aaaaa", AllowSeqScan(), WithGuardConfig(cfg))
+	})
+
+	t.Run("in_transaction", func(t *testing.T) {
+		// 同じトランザクションで作成した一時テーブルもチェックできる。
+		err := Transaction(context.Background(), func(tx *sql.Tx) error {
+			Exec(tx, "CREATE TEMP TABLE tmp_seq_scan (id int PRIMARY KEY, name text) ON COMMIT DROP", AllowSeqScan())
+			testutil.AssertTrue(t, checkSeqScan(DefaultGuardConfig, tx, "SELECT id FROM tmp_seq_scan WHERE id = $1", 1))
+			testutil.AssertFalse(t, checkSeqScan(DefaultGuardConfig, tx, "SELECT id FROM tmp_seq_scan WHERE name = $1", "a"))
+
+			// enable_seqscanの設定はトランザクションに残らない
+			v, err := QueryScalar[string](tx, "SELECT current_setting('enable_seqscan') WHERE true = $1", true)
+			testutil.AssertEqual(t, v, "on")
+			return err
+		})
+		testutil.AssertEqual(t, err, nil)
+	})
+
+	t.Run("allow_no_where", func(t *testing.T) {
+		_, err := Query(nil, &TableForTest{}, "SELECT * FROM table_for_tests", AllowNoWhere(), AllowSeqScan())
+		testutil.AssertEqual(t, err, nil)
+
+		n, err := Count(nil, &TableForTest{}, nil, nil, AllowNoWhere(), AllowSeqScan())
+		testutil.AssertEqual(t, err, nil)
+		testutil.AssertTrue(t, n >= 0)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestDialect$ ./ssql
+func TestDialect(t *testing.T) {
+	Dialect = DIALECT_SQLITE
+	defer func() { Dialect = DIALECT_POSTGRES }()
+
+	t.Run("seq_scan_check_is_skipped", func(t *testing.T) {
+		testutil.AssertTrue(t, CheckSeqScan("SELECT name FROM table_for_tests WHERE name = $1", "aaaaa"))
+	})
+
+	t.Run("sqlite_error", func(t *testing.T) {
+		testutil.AssertTrue(t, errors.Is(isAssumedSQLError(errors.New("UNIQUE constraint failed: table_for_tests.uid")), ErrUniqConstraint))
+		testutil.AssertTrue(t, errors.Is(isAssumedSQLError(errors.New("database is locked")), ErrLockNotAvailable))
+		testutil.AssertEqual(t, isAssumedSQLError(errors.New("ERROR: duplicate key value (SQLSTATE 23505)")), nil)
+	})
 }
 
 // env `cat .env` go test -v -count=1 -timeout 60s -run ^TestContainStr$ ./ssql
@@ -0,0 +1,256 @@
+package ssql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// 想定されたDBのエラー（制約違反、ロック待ちのタイムアウト等）の詳細
+//
+// Query、Exec、ORMの各関数がpanicにせずに返すDBのエラーはこの型となる。
+// エラーの種類はerrors.Isで判定し、制約名等の詳細が必要な場合はerrors.Asで取得する。
+//
+//	_, err := ssql.Insert(tx, &user)
+//	if errors.Is(err, ssql.ErrUniqConstraint) {
+//		var e *ssql.SQLError
+//		if errors.As(err, &e) && e.ConstraintName == "users_email_key" {
+//			...
+//		}
+//	}
+type SQLError struct {
+	// エラーの種類（ErrUniqConstraint等）
+	Kind error
+	// SQLSTATE（SQLiteの場合は空）
+	Code           string
+	Message        string
+	Detail         string
+	ConstraintName string
+	TableName      string
+	ColumnName     string
+	// RegisterConstraintErrorで制約名に対して登録されたerror（登録されていない場合はnil）
+	DomainErr error
+	// ドライバーが返した元のerror（PostgreSQLの場合は*pgconn.PgError）
+	Err error
+}
+
+func (e *SQLError) Error() string {
+	if e.DomainErr != nil {
+		return fmt.Sprintf("%s: %s: %s", e.DomainErr, e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Kind, e.Err)
+}
+
+func (e *SQLError) Unwrap() []error {
+	if e.DomainErr != nil {
+		return []error{e.DomainErr, e.Kind, e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// QueryErrorに含めるSQLの最大長（バイト）。超えた部分は切り詰められる。
+var QueryErrorMaxQueryLength = 1000
+
+// 想定されたDBのエラーに、実行したクエリの情報を付与したerror
+//
+// 上位でerrorをログに出力した際に、再現に必要な情報を含めるために利用する。
+// 引数の値は個人情報等を含む可能性があるため、個数のみを保持する。
+// errors.Is、errors.Asは元のerror（*SQLError）に対しても有効。
+type QueryError struct {
+	// 実行したSQL（QueryErrorMaxQueryLengthで切り詰められる）
+	Query    string
+	ArgCount int
+	// エラーが返されるまでの時間（再試行を含む）
+	Duration time.Duration
+	Err      error
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%s (query: %s, args: %d, duration: %s)", e.Err, e.Query, e.ArgCount, e.Duration)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// 想定されたDBのエラーの場合は、クエリの情報を付与した*QueryErrorを返す。それ以外の場合はnilを返す。
+func queryError(err error, query string, args []any, start time.Time) error {
+	e := isAssumedSQLError(err)
+	if e == nil {
+		return nil
+	}
+	return &QueryError{
+		Query:    truncateQuery(query),
+		ArgCount: len(args),
+		Duration: time.Since(start),
+		Err:      e,
+	}
+}
+
+func truncateQuery(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if QueryErrorMaxQueryLength <= 0 || len(query) <= QueryErrorMaxQueryLength {
+		return query
+	}
+	// マルチバイト文字の途中で切らないようにする。
+	n := QueryErrorMaxQueryLength
+	for n > 0 && !utf8.RuneStart(query[n]) {
+		n--
+	}
+	return query[:n] + "..."
+}
+
+var (
+	constraintErrorsMu sync.RWMutex
+	constraintErrors   = map[string]error{}
+)
+
+// 制約名に対して、その制約に違反した場合に返すerrorを登録する。
+// 各ハンドラーで制約名を判定せずに、アプリケーションで定義したerrorとして扱うために利用する。
+//
+//	var ErrEmailTaken = errors.New("email is already taken")
+//	ssql.RegisterConstraintError("uniq__users__email", ErrEmailTaken)
+//
+//	_, err := ssql.Insert(tx, &user)
+//	if errors.Is(err, ErrEmailTaken) { ... }
+//
+// 返されるerrorは*SQLErrorのままのため、errors.Is(err, ssql.ErrUniqConstraint)も引き続き満たす。
+// 通常は起動時に登録する。errにnilを指定した場合は登録を削除する。
+func RegisterConstraintError(constraint string, err error) {
+	constraintErrorsMu.Lock()
+	defer constraintErrorsMu.Unlock()
+	if err == nil {
+		delete(constraintErrors, constraint)
+		return
+	}
+	constraintErrors[constraint] = err
+}
+
+func constraintError(constraint string) error {
+	if constraint == "" {
+		return nil
+	}
+	constraintErrorsMu.RLock()
+	defer constraintErrorsMu.RUnlock()
+	return constraintErrors[constraint]
+}
+
+// 想定されたDBのエラーの場合は*SQLErrorを返す。それ以外の場合はnilを返す。
+func isAssumedSQLError(err error) error {
+	// ドライバーから返されたコンテキストのエラー
+	// 利用者による中断はサーバーのエラーとは区別して扱えるようにする。
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &SQLError{Kind: ErrQueryTimeout, Message: err.Error(), Err: err}
+	}
+	if errors.Is(err, context.Canceled) {
+		return &SQLError{Kind: ErrQueryCanceled, Message: err.Error(), Err: err}
+	}
+	if isConnFailure(err) {
+		return &SQLError{Kind: ErrConnUnavailable, Message: err.Error(), Err: err}
+	}
+	if !IsPostgres() {
+		return isAssumedSQLiteError(err)
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil
+	}
+	kind := postgresErrorKind(pgErr)
+	if kind == nil {
+		return nil
+	}
+	return &SQLError{
+		Kind:           kind,
+		Code:           pgErr.Code,
+		Message:        pgErr.Message,
+		Detail:         pgErr.Detail,
+		ConstraintName: pgErr.ConstraintName,
+		TableName:      pgErr.TableName,
+		ColumnName:     pgErr.ColumnName,
+		DomainErr:      constraintError(pgErr.ConstraintName),
+		Err:            err,
+	}
+}
+
+// SQLSTATEに対応するエラーの種類を返す。想定していないエラーの場合はnilを返す。
+func postgresErrorKind(e *pgconn.PgError) error {
+	switch e.Code {
+	case PostgresErrCodeLockNotAvailable:
+		return ErrLockNotAvailable
+	case PostgresErrCodeUniqConstraint:
+		return ErrUniqConstraint
+	case PostgresErrCodeForeignKeyConstraint:
+		return ErrForeignKeyConstraint
+	case PostgresErrCodeNotNullViolation:
+		return ErrNotNullViolation
+	case PostgresErrCodeCheckConstraint:
+		return ErrCheckConstraint
+	case PostgresErrCodeDeadLock:
+		return ErrDeadLock
+	case PostgresErrCodeSerializationFailure:
+		return ErrSerializationFailure
+	case PostgresErrCodeQueryCanceled:
+		// ユーザーによるキャンセル（コンテキストのキャンセル等）も同じコードのため、メッセージで区別する。
+		if strings.Contains(e.Message, "statement timeout") {
+			return ErrStatementTimeout
+		}
+		// コンテキストのキャンセルによりドライバーがキャンセル要求を送信した場合
+		if strings.Contains(e.Message, "user request") {
+			return ErrQueryCanceled
+		}
+	case PostgresErrCodeAdminShutdown, PostgresErrCodeCrashShutdown, PostgresErrCodeCannotConnectNow:
+		// サーバーの停止、再起動中等
+		return ErrConnUnavailable
+	case PostgresErrCodeInvalidSyntax:
+		// Enumの検証を経由しなかったenum型のカラムへの不正な値
+		if strings.Contains(e.Message, "enum") {
+			return ErrInvalidEnum
+		}
+	case PostgresErrCodeStringDataRightTruncation:
+		// validateタグで検証されなかった桁あふれ（varcharの長さ超過等）
+		return ErrValueTooLong
+	}
+	if strings.HasPrefix(e.Code, PostgresErrClassConnectionException) {
+		return ErrConnUnavailable
+	}
+	return nil
+}
+
+// ドライバーやネットワークのerrorのうち、接続の失敗や切断によるものかどうか
+// （サーバーから返されたSQLSTATEによる判定はpostgresErrorKindで行う）
+func isConnFailure(err error) bool {
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	// ラップされずに文字列化されたerrorの場合
+	msg := err.Error()
+	return strings.Contains(msg, "connection refused") || strings.Contains(msg, "connection reset by peer") || strings.Contains(msg, "broken pipe")
+}
+
+// SQLiteのエラーはコードではなくメッセージで判定する。
+func isAssumedSQLiteError(err error) error {
+	var kind error
+	switch {
+	case strings.Contains(err.Error(), SQLiteErrMessageBusy):
+		kind = ErrLockNotAvailable
+	case strings.Contains(err.Error(), SQLiteErrMessageUniqConstraint):
+		kind = ErrUniqConstraint
+	default:
+		return nil
+	}
+	return &SQLError{Kind: kind, Message: err.Error(), Err: err}
+}
@@ -0,0 +1,183 @@
+package ssql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestIsAssumedSQLError$ ./ssql
+func TestIsAssumedSQLError(t *testing.T) {
+	t.Run("uniq constraint", func(t *testing.T) {
+		pgErr := &pgconn.PgError{
+			Code:           PostgresErrCodeUniqConstraint,
+			Message:        `duplicate key value violates unique constraint "table_for_tests_uid_key"`,
+			Detail:         "Key (uid)=(a) already exists.",
+			ConstraintName: "table_for_tests_uid_key",
+			TableName:      "table_for_tests",
+		}
+		err := isAssumedSQLError(fmt.Errorf("wrapped: %w", pgErr))
+		testutil.AssertTrue(t, errors.Is(err, ErrUniqConstraint))
+
+		var e *SQLError
+		testutil.AssertTrue(t, errors.As(err, &e))
+		testutil.AssertEqual(t, e.Code, PostgresErrCodeUniqConstraint)
+		testutil.AssertEqual(t, e.ConstraintName, "table_for_tests_uid_key")
+		testutil.AssertEqual(t, e.TableName, "table_for_tests")
+		testutil.AssertEqual(t, e.Detail, "Key (uid)=(a) already exists.")
+
+		var got *pgconn.PgError
+		testutil.AssertTrue(t, errors.As(err, &got))
+		testutil.AssertEqual(t, got, pgErr)
+	})
+
+	t.Run("kinds", func(t *testing.T) {
+		for _, tt := range []struct {
+			pgErr *pgconn.PgError
+			kind  error
+		}{
+			{&pgconn.PgError{Code: PostgresErrCodeLockNotAvailable}, ErrLockNotAvailable},
+			{&pgconn.PgError{Code: PostgresErrCodeDeadLock}, ErrDeadLock},
+			{&pgconn.PgError{Code: PostgresErrCodeSerializationFailure}, ErrSerializationFailure},
+			{&pgconn.PgError{Code: PostgresErrCodeQueryCanceled, Message: "canceling statement due to statement timeout"}, ErrStatementTimeout},
+			{&pgconn.PgError{Code: PostgresErrCodeInvalidSyntax, Message: `invalid input value for enum mood: "x"`}, ErrInvalidEnum},
+			{&pgconn.PgError{Code: PostgresErrCodeQueryCanceled, Message: "canceling statement due to user request"}, ErrQueryCanceled},
+			{&pgconn.PgError{Code: PostgresErrCodeForeignKeyConstraint}, ErrForeignKeyConstraint},
+			{&pgconn.PgError{Code: PostgresErrCodeNotNullViolation}, ErrNotNullViolation},
+			{&pgconn.PgError{Code: PostgresErrCodeCheckConstraint}, ErrCheckConstraint},
+			{&pgconn.PgError{Code: PostgresErrCodeStringDataRightTruncation}, ErrValueTooLong},
+			{&pgconn.PgError{Code: PostgresErrCodeAdminShutdown}, ErrConnUnavailable},
+			{&pgconn.PgError{Code: PostgresErrCodeCannotConnectNow}, ErrConnUnavailable},
+			{&pgconn.PgError{Code: "08006"}, ErrConnUnavailable},
+			// 以前のErrValidationでの判定も引き続き可能
+			{&pgconn.PgError{Code: PostgresErrCodeStringDataRightTruncation}, ErrValidation},
+		} {
+			testutil.AssertTrue(t, errors.Is(isAssumedSQLError(tt.pgErr), tt.kind))
+		}
+	})
+
+	t.Run("not assumed", func(t *testing.T) {
+		testutil.AssertEqual(t, isAssumedSQLError(&pgconn.PgError{Code: "42601"}), nil)
+		// SQLSTATEを含むだけの文字列のエラーは対象外
+		testutil.AssertEqual(t, isAssumedSQLError(errors.New("ERROR: duplicate key value (SQLSTATE 23505)")), nil)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestRegisterConstraintError$ ./ssql
+func TestRegisterConstraintError(t *testing.T) {
+	errEmailTaken := errors.New("email is already taken")
+	RegisterConstraintError("uniq__users__email", errEmailTaken)
+	defer RegisterConstraintError("uniq__users__email", nil)
+
+	t.Run("registered", func(t *testing.T) {
+		err := isAssumedSQLError(&pgconn.PgError{Code: PostgresErrCodeUniqConstraint, ConstraintName: "uniq__users__email"})
+		testutil.AssertTrue(t, errors.Is(err, errEmailTaken))
+		testutil.AssertTrue(t, errors.Is(err, ErrUniqConstraint))
+		testutil.AssertContainStr(t, err.Error(), "email is already taken: violate uniq constraint")
+	})
+
+	t.Run("other constraint", func(t *testing.T) {
+		err := isAssumedSQLError(&pgconn.PgError{Code: PostgresErrCodeUniqConstraint, ConstraintName: "uniq__users__name"})
+		testutil.AssertFalse(t, errors.Is(err, errEmailTaken))
+		testutil.AssertTrue(t, errors.Is(err, ErrUniqConstraint))
+	})
+
+	t.Run("unregistered", func(t *testing.T) {
+		RegisterConstraintError("uniq__users__email", nil)
+		err := isAssumedSQLError(&pgconn.PgError{Code: PostgresErrCodeUniqConstraint, ConstraintName: "uniq__users__email"})
+		testutil.AssertFalse(t, errors.Is(err, errEmailTaken))
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestQueryError$ ./ssql
+func TestQueryError(t *testing.T) {
+	pgErr := &pgconn.PgError{Code: PostgresErrCodeUniqConstraint, Message: "duplicate key value"}
+
+	t.Run("wrap", func(t *testing.T) {
+		err := queryError(pgErr, "INSERT INTO users (name, email)\n\tVALUES ($1, $2)", []any{"taro", "taro@example.com"}, time.Now())
+		testutil.AssertTrue(t, errors.Is(err, ErrUniqConstraint))
+
+		var qe *QueryError
+		testutil.AssertTrue(t, errors.As(err, &qe))
+		testutil.AssertEqual(t, qe.Query, "INSERT INTO users (name, email) VALUES ($1, $2)")
+		testutil.AssertEqual(t, qe.ArgCount, 2)
+		testutil.AssertTrue(t, qe.Duration >= 0)
+
+		var se *SQLError
+		testutil.AssertTrue(t, errors.As(err, &se))
+		testutil.AssertEqual(t, se.Code, PostgresErrCodeUniqConstraint)
+
+		// 引数の値は含めない。
+		testutil.AssertFalse(t, strings.Contains(err.Error(), "taro@example.com"))
+		testutil.AssertContainStr(t, err.Error(), "args: 2")
+	})
+
+	t.Run("not assumed", func(t *testing.T) {
+		testutil.AssertEqual(t, queryError(&pgconn.PgError{Code: "42601"}, "SELECT", nil, time.Now()), nil)
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		org := QueryErrorMaxQueryLength
+		defer func() { QueryErrorMaxQueryLength = org }()
+		QueryErrorMaxQueryLength = 10
+
+		testutil.AssertEqual(t, truncateQuery("SELECT 1"), "SELECT 1")
+		testutil.AssertEqual(t, truncateQuery("SELECT * FROM users"), "SELECT * F...")
+		// マルチバイト文字の途中で切らない。
+		testutil.AssertEqual(t, truncateQuery("SELECT 'あいう'"), "SELECT '...")
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestContextError$ ./ssql
+func TestContextError(t *testing.T) {
+	t.Run("timeout", func(t *testing.T) {
+		err := isAssumedSQLError(fmt.Errorf("timeout: %w", context.DeadlineExceeded))
+		testutil.AssertTrue(t, errors.Is(err, ErrQueryTimeout))
+		testutil.AssertTrue(t, errors.Is(err, context.DeadlineExceeded))
+		testutil.AssertFalse(t, errors.Is(err, ErrQueryCanceled))
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		err := isAssumedSQLError(context.Canceled)
+		testutil.AssertTrue(t, errors.Is(err, ErrQueryCanceled))
+		testutil.AssertTrue(t, errors.Is(err, context.Canceled))
+		testutil.AssertFalse(t, IsRetryable(err))
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		Dialect = DIALECT_SQLITE
+		defer func() { Dialect = DIALECT_POSTGRES }()
+		testutil.AssertTrue(t, errors.Is(isAssumedSQLError(context.Canceled), ErrQueryCanceled))
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestConnUnavailable$ ./ssql
+func TestConnUnavailable(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"bad conn", driver.ErrBadConn, true},
+		{"connection refused", fmt.Errorf("dial tcp: %w", syscall.ECONNREFUSED), true},
+		{"broken pipe", fmt.Errorf("write: %w", syscall.EPIPE), true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"connection refused message", errors.New("dial tcp 127.0.0.1:5432: connect: connection refused"), true},
+		{"other", errors.New("other"), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := isAssumedSQLError(tt.err)
+			testutil.AssertEqual(t, errors.Is(err, ErrConnUnavailable), tt.expected)
+			testutil.AssertEqual(t, IsRetryable(err), tt.expected)
+		})
+	}
+}
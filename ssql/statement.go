@@ -0,0 +1,232 @@
+package ssql
+
+import "strings"
+
+// ガードのチェック（WHERE句の有無、行ロック句等）に利用するSQLの情報
+//
+// tokenizeの結果から抽出するため、文字列リテラルやコメント内のキーワード、
+// "deleted_at"等のキーワードを含む識別子を誤って検出することがない。
+type statementInfo struct {
+	tokens []token
+	// 主となる文の種類（SELECT、INSERT、UPDATE、DELETE等）。WITH句は読み飛ばす。
+	kind string
+}
+
+func analyzeStatement(query string) *statementInfo {
+	s := &statementInfo{tokens: tokenize(query), kind: firstKeyword(query)}
+	if s.kind == "WITH" {
+		s.kind = s.mainKeywordAfterWith()
+	}
+	return s
+}
+
+// WITH句の場合に、CTEの定義の後に続く最上位の文のキーワードを返す。
+func (s *statementInfo) mainKeywordAfterWith() string {
+	depth := 0
+	for _, t := range s.tokens {
+		switch {
+		case t.text == "(":
+			depth++
+		case t.text == ")":
+			depth--
+		case depth == 0 && t.kind == tokenWord:
+			switch w := strings.ToUpper(t.text); w {
+			case "SELECT", "INSERT", "UPDATE", "DELETE", "MERGE", "VALUES", "TABLE":
+				return w
+			}
+		}
+	}
+	return ""
+}
+
+// セミコロンで区切られた各文の先頭のキーワードを大文字で返す。
+func (s *statementInfo) statementKeywords() []string {
+	r := []string{}
+	head := true
+	for _, t := range s.tokens {
+		switch {
+		case t.text == ";":
+			head = true
+		case head && t.kind == tokenWord:
+			r = append(r, strings.ToUpper(t.text))
+			head = false
+		case head && t.text != "(":
+			head = false
+		}
+	}
+	return r
+}
+
+func (s *statementInfo) isKeyword(i int, keyword string) bool {
+	return i >= 0 && i < len(s.tokens) && s.tokens[i].kind == tokenWord && strings.EqualFold(s.tokens[i].text, keyword)
+}
+
+// いずれかの位置（サブクエリ内を含む）にキーワードが含まれるかどうか
+func (s *statementInfo) hasKeyword(keyword string) bool {
+	for i := range s.tokens {
+		if s.isKeyword(i, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// 文の先頭となるUPDATE、DELETE等の位置を返す。（WITH句内の文を含む）
+// FOR UPDATE、ON CONFLICT DO UPDATE、ON DELETE CASCADE等のキーワードは含まない。
+func (s *statementInfo) statementStarts(keyword string) []int {
+	r := []int{}
+	for i := range s.tokens {
+		if !s.isKeyword(i, keyword) {
+			continue
+		}
+		if i == 0 || s.tokens[i-1].text == "(" || s.tokens[i-1].text == ")" || s.tokens[i-1].text == ";" {
+			r = append(r, i)
+		}
+	}
+	return r
+}
+
+// startの文が終わるまで（閉じ括弧、セミコロン、末尾）の間に、同じ階層のWHEREがあるかどうか
+// サブクエリ内のWHEREは対象の文の条件とはならないため含まない。
+func (s *statementInfo) hasWhereAt(start int) bool {
+	depth := 0
+	for i := start + 1; i < len(s.tokens); i++ {
+		switch t := s.tokens[i]; {
+		case t.text == "(":
+			depth++
+		case t.text == ")":
+			depth--
+			if depth < 0 {
+				return false
+			}
+		case t.text == ";" && depth == 0:
+			return false
+		case depth == 0 && s.isKeyword(i, "WHERE"):
+			return true
+		}
+	}
+	return false
+}
+
+// WHEREの無いUPDATEまたはDELETEの文が含まれるかどうか
+func (s *statementInfo) hasStatementWithoutWhere(keyword string) bool {
+	for _, i := range s.statementStarts(keyword) {
+		if !s.hasWhereAt(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// 行の更新を含むかどうか（UPDATE文、INSERT ... ON CONFLICT DO UPDATE）
+func (s *statementInfo) hasUpdate() bool {
+	if len(s.statementStarts("UPDATE")) > 0 {
+		return true
+	}
+	for i := range s.tokens {
+		if s.isKeyword(i, "UPDATE") && s.isKeyword(i-1, "DO") {
+			return true
+		}
+	}
+	return false
+}
+
+// iの位置から行ロック句（FOR UPDATE、FOR NO KEY UPDATE、FOR SHARE、FOR KEY SHARE）が始まる場合は、
+// その末尾（UPDATEまたはSHARE）の位置を返す。それ以外の場合は-1を返す。
+func (s *statementInfo) lockingClauseEnd(i int) int {
+	if !s.isKeyword(i, "FOR") {
+		return -1
+	}
+	j := i + 1
+	if s.isKeyword(j, "NO") {
+		j++
+	}
+	if s.isKeyword(j, "KEY") {
+		j++
+	}
+	if !s.isKeyword(j, "UPDATE") && !s.isKeyword(j, "SHARE") {
+		return -1
+	}
+	return j
+}
+
+// 最上位（サブクエリの外）に行ロック句が含まれるかどうか
+func (s *statementInfo) hasLockingClause() bool {
+	depth := 0
+	for i, t := range s.tokens {
+		switch {
+		case t.text == "(":
+			depth++
+		case t.text == ")":
+			depth--
+		case depth == 0 && s.lockingClauseEnd(i) >= 0:
+			return true
+		}
+	}
+	return false
+}
+
+// NOWAITの指定されていない行ロック句が含まれるかどうか
+func (s *statementInfo) hasLockingClauseWithoutNowait() bool {
+	for i := range s.tokens {
+		j := s.lockingClauseEnd(i)
+		if j < 0 {
+			continue
+		}
+		// "OF テーブル名"の後に続くNOWAITを探す。次の行ロック句や文の終わりまでを対象とする。
+		nowait := false
+		for k := j + 1; k < len(s.tokens); k++ {
+			if s.isKeyword(k, "FOR") || s.tokens[k].text == ")" || s.tokens[k].text == ";" {
+				break
+			}
+			if s.isKeyword(k, "NOWAIT") {
+				nowait = true
+				break
+			}
+		}
+		if !nowait {
+			return true
+		}
+	}
+	return false
+}
+
+// 最上位（サブクエリ、集計関数、ウィンドウ関数の外）にLIMITの無いORDER BYがあるかどうか
+// GROUP BYによる集計結果の並び替えは対象外とする。
+func (s *statementInfo) hasOrderByWithoutLimit() bool {
+	orderBy, limit, groupBy := false, false, false
+	depth := 0
+	for i, t := range s.tokens {
+		switch {
+		case t.text == "(":
+			depth++
+		case t.text == ")":
+			depth--
+		case depth != 0:
+		case s.isKeyword(i, "ORDER") && s.isKeyword(i+1, "BY"):
+			orderBy = true
+		case s.isKeyword(i, "GROUP") && s.isKeyword(i+1, "BY"):
+			groupBy = true
+		case s.isKeyword(i, "LIMIT") || s.isKeyword(i, "FETCH"):
+			limit = true
+		}
+	}
+	return orderBy && !limit && !groupBy
+}
+
+// 識別子（カラム名等）として含まれるかどうか。引用符付きの識別子を含み、大文字小文字は区別しない。
+func (s *statementInfo) hasIdentifier(name string) bool {
+	for _, t := range s.tokens {
+		switch t.kind {
+		case tokenWord:
+			if strings.EqualFold(t.text, name) {
+				return true
+			}
+		case tokenQuotedIdent:
+			if len(t.text) >= 2 && strings.EqualFold(strings.ReplaceAll(t.text[1:len(t.text)-1], `""`, `"`), name) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package ssql
+
+import (
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestAnalyzeStatement$ ./ssql
+func TestAnalyzeStatement(t *testing.T) {
+	t.Run("kind", func(t *testing.T) {
+		for _, tt := range []struct {
+			query    string
+			expected string
+		}{
+			{"SELECT * FROM users WHERE id = $1", "SELECT"},
+			{"(SELECT 1) UNION (SELECT 2)", "SELECT"},
+			{"INSERT INTO users (name) SELECT name FROM tmp WHERE id = $1", "INSERT"},
+			{"WITH t AS (SELECT id FROM users WHERE id = $1) DELETE FROM logs WHERE user_id IN (SELECT id FROM t)", "DELETE"},
+			{"WITH RECURSIVE t(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM t WHERE n < 10) SELECT * FROM t", "SELECT"},
+			{"-- SELECT\nDELETE FROM users WHERE id = $1", "DELETE"},
+		} {
+			testutil.AssertEqual(t, analyzeStatement(tt.query).kind, tt.expected)
+		}
+	})
+
+	t.Run("statement_without_where", func(t *testing.T) {
+		for _, tt := range []struct {
+			name     string
+			query    string
+			keyword  string
+			expected bool
+		}{
+			{"delete with where", "DELETE FROM users WHERE id = $1", "DELETE", false},
+			{"delete without where", "DELETE FROM users", "DELETE", true},
+			{"where in line comment", "DELETE FROM users -- WHERE id = 1\n", "DELETE", true},
+			{"where in block comment and string literal", "DELETE FROM users /* WHERE */ RETURNING 'WHERE'", "DELETE", true},
+			{"where only in subquery", "DELETE FROM users USING (SELECT id FROM banned WHERE id = $1) b", "DELETE", true},
+			{"column name contains keyword", "UPDATE users SET to_delete = true WHERE id = $1", "DELETE", false},
+			{"delete in cte", "WITH d AS (DELETE FROM users RETURNING id) SELECT * FROM d WHERE id = $1", "DELETE", true},
+			{"delete in cte with where", "WITH d AS (DELETE FROM users WHERE id = $1 RETURNING id) SELECT * FROM d", "DELETE", false},
+			{"update with where", "UPDATE users SET name = $1 WHERE id = $2", "UPDATE", false},
+			{"update without where", "UPDATE users SET name = $1", "UPDATE", true},
+			{"on conflict do update", "INSERT INTO users (id) VALUES ($1) ON CONFLICT (id) DO UPDATE SET name = 'a'", "UPDATE", false},
+			{"multiple statements", "UPDATE users SET name = 'a' WHERE id = 1; UPDATE users SET name = 'b'", "UPDATE", true},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				testutil.AssertEqual(t, analyzeStatement(tt.query).hasStatementWithoutWhere(tt.keyword), tt.expected)
+			})
+		}
+	})
+
+	t.Run("has_update", func(t *testing.T) {
+		testutil.AssertTrue(t, analyzeStatement("UPDATE users SET name = $1 WHERE id = $2").hasUpdate())
+		testutil.AssertTrue(t, analyzeStatement("INSERT INTO users (id) VALUES ($1) ON CONFLICT (id) DO UPDATE SET name = 'a'").hasUpdate())
+		testutil.AssertFalse(t, analyzeStatement("SELECT * FROM users WHERE id = $1 FOR UPDATE").hasUpdate())
+		testutil.AssertFalse(t, analyzeStatement("INSERT INTO users (last_update) VALUES ('UPDATE ')").hasUpdate())
+	})
+
+	t.Run("locking_clause", func(t *testing.T) {
+		for _, tt := range []struct {
+			query    string
+			expected bool
+		}{
+			{"SELECT * FROM users WHERE id = $1", false},
+			{"SELECT * FROM users WHERE id = $1 FOR UPDATE", true},
+			{"SELECT * FROM users WHERE id = $1 FOR UPDATE NOWAIT", false},
+			{"SELECT * FROM users WHERE id = $1 FOR SHARE", true},
+			{"SELECT * FROM users WHERE id = $1 for no key update of users nowait", false},
+			{"SELECT * FROM users WHERE id = $1 FOR KEY SHARE", true},
+			{"SELECT * FROM users u JOIN teams t ON t.id = u.team_id WHERE u.id = $1 FOR UPDATE OF u NOWAIT FOR SHARE OF t", true},
+			{"SELECT substring(name FROM 1 FOR 2) FROM users WHERE id = $1", false},
+			{"SELECT * FROM users WHERE note = ' FOR UPDATE' AND id = $1", false},
+		} {
+			testutil.AssertEqual(t, analyzeStatement(tt.query).hasLockingClauseWithoutNowait(), tt.expected)
+		}
+	})
+
+	t.Run("locking_clause", func(t *testing.T) {
+		for _, tt := range []struct {
+			query    string
+			expected bool
+		}{
+			{"SELECT * FROM users WHERE id = $1\nFOR UPDATE", true},
+			{"SELECT * FROM users WHERE id = $1\tFOR KEY SHARE NOWAIT", true},
+			{"SELECT * FROM users WHERE id IN (SELECT user_id FROM members FOR SHARE)", false},
+			{"SELECT * FROM users WHERE note = ' FOR UPDATE' -- FOR SHARE", false},
+			{"SELECT * FROM users WHERE id = $1", false},
+		} {
+			testutil.AssertEqual(t, analyzeStatement(tt.query).hasLockingClause(), tt.expected)
+		}
+	})
+
+	t.Run("order_by_without_limit", func(t *testing.T) {
+		for _, tt := range []struct {
+			query    string
+			expected bool
+		}{
+			{"SELECT * FROM users WHERE team_id = $1 ORDER BY created_at", true},
+			{"SELECT * FROM users WHERE team_id = $1 ORDER BY created_at LIMIT 10", false},
+			{"SELECT * FROM users WHERE team_id = $1 ORDER BY created_at FETCH FIRST 10 ROWS ONLY", false},
+			{"SELECT * FROM users WHERE team_id = $1", false},
+			{"SELECT status, count(*) FROM users WHERE team_id = $1 GROUP BY status ORDER BY status", false},
+			{"SELECT string_agg(name, ',' ORDER BY name) FROM users WHERE team_id = $1", false},
+			{"SELECT id, row_number() OVER (ORDER BY created_at) FROM users WHERE team_id = $1", false},
+			{"SELECT * FROM (SELECT * FROM users WHERE team_id = $1 ORDER BY id LIMIT 10) u ORDER BY name", true},
+			{"SELECT * FROM users WHERE note = 'ORDER BY' AND team_id = $1", false},
+		} {
+			testutil.AssertEqual(t, analyzeStatement(tt.query).hasOrderByWithoutLimit(), tt.expected)
+		}
+	})
+
+	t.Run("has_identifier", func(t *testing.T) {
+		testutil.AssertTrue(t, analyzeStatement("UPDATE users SET updated_at = now() WHERE id = $1").hasIdentifier("updated_at"))
+		testutil.AssertTrue(t, analyzeStatement(`UPDATE users SET "Updated_At" = now() WHERE id = $1`).hasIdentifier("updated_at"))
+		testutil.AssertFalse(t, analyzeStatement("UPDATE users SET last_updated_at = now() WHERE id = $1").hasIdentifier("updated_at"))
+		testutil.AssertFalse(t, analyzeStatement("UPDATE users SET name = 'updated_at' WHERE id = $1").hasIdentifier("updated_at"))
+	})
+
+	t.Run("statement_keywords", func(t *testing.T) {
+		testutil.AssertDeepEqual(t, analyzeStatement("truncate users").statementKeywords(), []string{"TRUNCATE"})
+		testutil.AssertDeepEqual(t, analyzeStatement("SELECT ';'; (SELECT 1); /* ; */ drop table users;").statementKeywords(), []string{"SELECT", "SELECT", "DROP"})
+		testutil.AssertDeepEqual(t, analyzeStatement("").statementKeywords(), []string{})
+	})
+
+	t.Run("has_keyword", func(t *testing.T) {
+		testutil.AssertTrue(t, analyzeStatement("DELETE FROM users WHERE id = $1 RETURNING *").hasKeyword("RETURNING"))
+		testutil.AssertFalse(t, analyzeStatement("DELETE FROM users WHERE note = ' RETURNING '").hasKeyword("RETURNING"))
+	})
+}
@@ -0,0 +1,241 @@
+package ssql
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// プリペアドステートメントをキャッシュする最大数
+// 超えた場合は最も使われていないものからクローズされる。
+var StmtCacheSize = 100
+
+// SQL文をキーとしたプリペアドステートメントのLRUキャッシュ
+type stmtCache struct {
+	mu    sync.Mutex
+	ll    *list.List
+	items map[stmtCacheKey]*list.Element
+}
+
+// DBが開き直された場合に古いステートメントを使わないように、DBもキーに含める。
+type stmtCacheKey struct {
+	db    *sql.DB
+	query string
+}
+
+type stmtCacheEntry struct {
+	key  stmtCacheKey
+	stmt *sql.Stmt
+	// 利用中の数。キャッシュから追い出された後も、利用中の間はクローズしない。
+	refs    int
+	evicted bool
+}
+
+var stmts = &stmtCache{
+	ll:    list.New(),
+	items: make(map[stmtCacheKey]*list.Element),
+}
+
+// キャッシュからステートメントを取得する。存在しない場合はプリペアする。
+// 利用後は必ずreleaseを呼ぶこと。
+func (c *stmtCache) acquire(ctx context.Context, db *sql.DB, query string) (*stmtCacheEntry, error) {
+	key := stmtCacheKey{db: db, query: query}
+
+	c.mu.Lock()
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		entry := e.Value.(*stmtCacheEntry)
+		entry.refs++
+		c.mu.Unlock()
+		return entry, nil
+	}
+	c.mu.Unlock()
+
+	// プリペアはDBとの通信が発生するため、ロックの外で行う。
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 他のゴルーチンが先に登録していた場合はそちらを使う。
+	if e, ok := c.items[key]; ok {
+		stmt.Close()
+		c.ll.MoveToFront(e)
+		entry := e.Value.(*stmtCacheEntry)
+		entry.refs++
+		return entry, nil
+	}
+	entry := &stmtCacheEntry{key: key, stmt: stmt, refs: 1}
+	c.items[key] = c.ll.PushFront(entry)
+	for c.ll.Len() > StmtCacheSize {
+		c.evict(c.ll.Back())
+	}
+	return entry, nil
+}
+
+func (c *stmtCache) release(entry *stmtCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.refs--
+	if entry.evicted && entry.refs == 0 {
+		entry.stmt.Close()
+	}
+}
+
+// ロックを取得した状態で呼ぶこと。
+func (c *stmtCache) evict(e *list.Element) {
+	entry := e.Value.(*stmtCacheEntry)
+	c.ll.Remove(e)
+	delete(c.items, entry.key)
+	entry.evicted = true
+	if entry.refs == 0 {
+		entry.stmt.Close()
+	}
+}
+
+// キャッシュされているステートメントをすべてクローズする。
+// DBをクローズする前などに利用する。
+func ClearStmtCache() {
+	stmts.mu.Lock()
+	defer stmts.mu.Unlock()
+	for stmts.ll.Len() > 0 {
+		stmts.evict(stmts.ll.Back())
+	}
+}
+
+// DB上にプリペアドステートメントを作成する。
+//
+// キャッシュしたステートメントは追い出された際にクローズされるため、キャッシュとは別に作成したものを返す。
+// 呼び出し側で不要になった際にCloseすること。
+// 繰り返し実行するSQLをキャッシュしたステートメントで実行する場合はPreparedを利用する。
+func Prepare(query string) (*sql.Stmt, error) {
+	return DB.Prepare(query)
+}
+
+// プリペアドステートメントを利用してクエリを実行するExecutorを返す。
+// Query、Exec、ORMの各関数にそのまま渡すことができる。
+//
+// txが*sql.Txの場合は、キャッシュしたステートメントをそのトランザクション上で利用する。
+// （TransactionまたはBegin以外で開始したトランザクションの場合は、キャッシュせずにトランザクション上でプリペアする）
+// *sql.Connの場合はステートメントを共有できないため、そのまま実行する。
+// txがnilの場合は、Query等と同様に実行時にコンテキストのトランザクション、レプリカ、DBの順に解決する。
+func Prepared(tx Executor) Executor {
+	return &preparedExecutor{tx: tx}
+}
+
+// プリペアドステートメントを利用してQueryを実行する。
+func PreparedQuery[M any](tx Executor, mp *M, query string, args ...any) ([]M, error) {
+	return Query(Prepared(tx), mp, query, args...)
+}
+
+// プリペアドステートメントを利用してExecを実行する。
+func PreparedExec(tx Executor, query string, args ...any) (sql.Result, error) {
+	return Exec(Prepared(tx), query, args...)
+}
+
+type preparedExecutor struct {
+	// nilの場合は実行時に解決する。
+	tx Executor
+}
+
+// txがPreparedの場合は、Preparedに指定されたExecutorを返す。
+// トランザクションの有無等を判定する際に、Preparedを経由しない場合と同様に扱うために利用する。
+func unwrapPrepared(tx Executor) Executor {
+	if p, ok := tx.(*preparedExecutor); ok {
+		return p.tx
+	}
+	return tx
+}
+
+// ステートメントを取得してfnを実行する。
+// プリペアできない場合（*sql.Conn等）はnilを渡す。
+func (p *preparedExecutor) withStmt(ctx context.Context, query string, fn func(*sql.Stmt) error) error {
+	var db *sql.DB
+	var tx *sql.Tx
+	switch e := getExecutor(p.tx).(type) {
+	case *sql.DB:
+		db = e
+	case *sql.Tx:
+		tx = e
+	case *Tx:
+		tx = e.tx
+	default:
+		return fn(nil)
+	}
+	if tx != nil {
+		// キャッシュしたステートメントは同じDBのトランザクションでのみ利用できる。
+		// TransactionまたはBegin以外で開始したトランザクションは、どのDBのものか分からないためトランザクション上でプリペアする。
+		if st := loadTxState(tx); st != nil && st.db != nil {
+			db = st.db
+		} else {
+			// トランザクション固有のステートメントはトランザクション終了時にクローズされる。
+			stmt, err := tx.PrepareContext(ctx, query)
+			if err != nil {
+				return err
+			}
+			return fn(stmt)
+		}
+	}
+
+	entry, err := stmts.acquire(ctx, db, query)
+	if err != nil {
+		return err
+	}
+	defer stmts.release(entry)
+
+	stmt := entry.stmt
+	if tx != nil {
+		// トランザクション固有のステートメントはトランザクション終了時にクローズされる。
+		stmt = tx.StmtContext(ctx, stmt)
+	}
+	return fn(stmt)
+}
+
+func (p *preparedExecutor) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := p.withStmt(ctx, query, func(stmt *sql.Stmt) error {
+		var err error
+		if stmt == nil {
+			rows, err = getExecutor(p.tx).QueryContext(ctx, query, args...)
+		} else {
+			rows, err = stmt.QueryContext(ctx, args...)
+		}
+		return err
+	})
+	return rows, err
+}
+
+func (p *preparedExecutor) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	var result sql.Result
+	err := p.withStmt(ctx, query, func(stmt *sql.Stmt) error {
+		var err error
+		if stmt == nil {
+			result, err = getExecutor(p.tx).ExecContext(ctx, query, args...)
+		} else {
+			result, err = stmt.ExecContext(ctx, args...)
+		}
+		return err
+	})
+	return result, err
+}
+
+func (p *preparedExecutor) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	var row *sql.Row
+	err := p.withStmt(ctx, query, func(stmt *sql.Stmt) error {
+		if stmt == nil {
+			row = getExecutor(p.tx).QueryRowContext(ctx, query, args...)
+		} else {
+			row = stmt.QueryRowContext(ctx, args...)
+		}
+		return nil
+	})
+	if err != nil {
+		// *sql.Rowはエラーを保持した状態で生成できないため、
+		// プリペアせずに実行してエラーはScan時に返させる。
+		return getExecutor(p.tx).QueryRowContext(ctx, query, args...)
+	}
+	return row
+}
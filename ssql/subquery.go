@@ -0,0 +1,25 @@
+package ssql
+
+// whereの値としてSELECT文を埋め込むためのサブクエリ
+//
+// プレースホルダーの位置に"(SELECT ...)"としてSQLが展開され、
+// サブクエリのプレースホルダーと値は呼び出し側のクエリに合わせて採番し直される。
+//
+//	sub := ssql.Subquery(ssql.Model(&Order{}).Select("user_id").Where("amount > ?", 1000))
+//	users, err := ssql.Find(tx, &User{}, []string{"id IN ?"}, []any{sub})
+//
+// Query、Exec等の生のSQLでも、$1等のプレースホルダーに対応する値として利用できる。
+type SubqueryValue struct {
+	query  string
+	values []any
+}
+
+func Subquery[M any](b *Builder[M]) SubqueryValue {
+	query, values := b.SQL()
+	query, values = inlineSQLValues(query, values)
+	return SubqueryValue{query: query, values: values}
+}
+
+func (v SubqueryValue) inline() (string, []any) {
+	return "(" + v.query + ")", v.values
+}
@@ -0,0 +1,37 @@
+package ssql
+
+import (
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestInlineSubqueries$ ./ssql
+func TestInlineSubqueries(t *testing.T) {
+	t.Run("builder", func(t *testing.T) {
+		sub := Subquery(Model(&TestStruct{}).Select("id").Where("age > ?", 20).Where("name <> ?", "a"))
+		sql, values := getQuerySQL(TestStruct{}, []string{"name = ?", "id IN ?", "age < ?"}, []any{"b", sub, 60}, nil, LimitOffset{})
+		sql, values = inlineSQLValues(sql, values)
+		testutil.AssertEqual(t, sql, "SELECT * FROM test_structs WHERE name = $1 AND id IN (SELECT id FROM test_structs WHERE (age > $2) AND (name <> $3)) AND age < $4")
+		testutil.AssertDeepEqual(t, values, []any{"b", 20, "a", 60})
+	})
+
+	t.Run("nested and repeated", func(t *testing.T) {
+		inner := Subquery(Model(&TestStruct{}).Select("id").Where("age = ?", 1))
+		outer := Subquery(Model(&TestStruct{}).Select("id").Where("id IN ?", inner))
+		sql, values := inlineSQLValues("SELECT * FROM t WHERE a = $2 AND id IN $1 OR parent_id IN $1", []any{outer, "x"})
+		testutil.AssertEqual(t, sql, "SELECT * FROM t WHERE a = $2 AND id IN (SELECT id FROM test_structs WHERE (id IN (SELECT id FROM test_structs WHERE (age = $1)))) OR parent_id IN (SELECT id FROM test_structs WHERE (id IN (SELECT id FROM test_structs WHERE (age = $1))))")
+		testutil.AssertDeepEqual(t, values, []any{1, "x"})
+	})
+
+	t.Run("literal is not replaced", func(t *testing.T) {
+		sql, _ := inlineSQLValues("SELECT * FROM t WHERE a = '$1' AND id IN $1", []any{Subquery(Model(&TestStruct{}).Where("age = ?", 1))})
+		testutil.AssertEqual(t, sql, "SELECT * FROM t WHERE a = '$1' AND id IN (SELECT * FROM test_structs WHERE (age = $1))")
+	})
+
+	t.Run("no subquery", func(t *testing.T) {
+		sql, values := inlineSQLValues("SELECT * FROM t WHERE id = $1", []any{1})
+		testutil.AssertEqual(t, sql, "SELECT * FROM t WHERE id = $1")
+		testutil.AssertDeepEqual(t, values, []any{1})
+	})
+}
@@ -0,0 +1,61 @@
+package ssql
+
+import (
+	"slices"
+	"strings"
+)
+
+// Seq Scanのノードの条件（Filter）から、検索に利用できるインデックスを作成するCREATE INDEX文を返す。
+// 組み立てられない場合は空文字を返す。
+//
+// 等価条件（=、IN、IS NULL）のカラムを先に、範囲条件（<、>等）のカラムを最後に1つだけ並べる。
+// 関数やキャストを含む条件、OR等の複雑な条件を正しく扱うことはできないため、あくまで参考とすること。
+func suggestIndex(n *PlanNode) string {
+	if n.RelationName == "" || n.Filter == "" {
+		return ""
+	}
+	tokens := tokenize(n.Filter)
+	isWord := func(i int, w string) bool {
+		return i < len(tokens) && tokens[i].kind == tokenWord && strings.EqualFold(tokens[i].text, w)
+	}
+	equals, ranges := []string{}, []string{}
+	for i, t := range tokens {
+		if t.kind != tokenWord && t.kind != tokenQuotedIdent {
+			continue
+		}
+		// キャスト（::text）の型名は対象外
+		if i > 0 && tokens[i-1].text == ":" {
+			continue
+		}
+		if i+1 >= len(tokens) {
+			break
+		}
+		var list *[]string
+		switch next := tokens[i+1].text; {
+		// "= ANY (...)"（IN）を含む
+		case next == "=" || isWord(i+1, "IS"):
+			list = &equals
+		// "<>"は対象外
+		case next == "<" && (i+2 >= len(tokens) || tokens[i+2].text != ">"), next == ">":
+			list = &ranges
+		default:
+			continue
+		}
+		if !slices.Contains(*list, t.text) {
+			*list = append(*list, t.text)
+		}
+	}
+	ranges = slices.DeleteFunc(ranges, func(c string) bool { return slices.Contains(equals, c) })
+	columns := equals
+	if len(ranges) > 0 {
+		columns = append(columns, ranges[0])
+	}
+	if len(columns) == 0 {
+		return ""
+	}
+	table := n.RelationName
+	if strings.ToLower(table) != table || !safeIdentifierRegexp.MatchString(table) {
+		table = QuoteIdentifier(table)
+	}
+	return "CREATE INDEX ON " + table + " (" + strings.Join(columns, ", ") + ")"
+}
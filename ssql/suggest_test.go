@@ -0,0 +1,28 @@
+package ssql
+
+import (
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestSuggestIndex$ ./ssql
+func TestSuggestIndex(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		node     PlanNode
+		expected string
+	}{
+		{"equal", PlanNode{RelationName: "users", Filter: "(name = 'a'::text)"}, "CREATE INDEX ON users (name)"},
+		{"equal and range", PlanNode{RelationName: "users", Filter: "((created_at >= '2024-01-01 00:00:00'::timestamp without time zone) AND (team_id = 1) AND (deleted_at IS NULL))"}, "CREATE INDEX ON users (team_id, deleted_at, created_at)"},
+		{"any", PlanNode{RelationName: "users", Filter: "(status = ANY ('{a,b}'::text[]))"}, "CREATE INDEX ON users (status)"},
+		{"qualified and quoted", PlanNode{RelationName: "Users", Filter: `(u."teamId" = 1)`}, `CREATE INDEX ON "Users" ("teamId")`},
+		{"not equal only", PlanNode{RelationName: "users", Filter: "(status <> 'a'::text)"}, ""},
+		{"no filter", PlanNode{RelationName: "users"}, ""},
+		{"no relation", PlanNode{Filter: "(name = 'a'::text)"}, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.AssertEqual(t, suggestIndex(&tt.node), tt.expected)
+		})
+	}
+}
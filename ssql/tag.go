@@ -0,0 +1,331 @@
+package ssql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// databaseタグのオプション
+// `database:"payload,json"` のようにカラム名の後にカンマ区切りで指定する。
+const (
+	// jsonbカラムとして扱い、書き込み時にjson.Marshal、読み込み時にjson.Unmarshalする。
+	TagOptionJSON = "json"
+
+	// 配列カラムとして扱う。[]stringや[]int64等のスライスのフィールドに指定する。
+	// 書き込み時はpgxがスライスを配列として扱うため、読み込み時のみ変換を行う。
+	TagOptionArray = "array"
+
+	// 論理削除のカラムとして扱う。timestamptz（NULL許容）のカラムに指定する。
+	// Deleteはレコードを削除せずに現在時刻をセットし、First、Find等は論理削除されたレコードを除外する。
+	TagOptionSoftDelete = "soft_delete"
+
+	// Insert時にゼロ値の場合はカラムを省略し、データベース側のデフォルト値に委ねる。
+	// InsertBulkでは全ての要素がゼロ値の場合のみ省略する。
+	TagOptionOmitEmpty = "omitempty"
+
+	// Insert時のみ書き込み、Save、UpdateStruct等の構造体による更新の対象外とする。
+	TagOptionInsertOnly = "insert_only"
+
+	// 読み込みのみ行い、InsertやSave、UpdateStruct等では書き込まない。
+	// トリガー等によってデータベース側で値が設定されるカラムに指定する。
+	TagOptionReadOnly = "readonly"
+
+	// データベース側で生成されるカラムとして扱う。（GENERATED ALWAYS AS、シーケンス等）
+	// id、created_at、updated_atと同様にInsertやSave、UpdateStruct等ではセットせず、
+	// InsertReturningでは生成された値を構造体へ格納する。
+	TagOptionGenerated = "generated"
+
+	// 主キーのカラムとして扱う。指定が無い場合は"id"のカラムを主キーとする。
+	// FindByID、DeleteByID、Save等で利用される。
+	TagOptionPrimaryKey = "primary_key"
+
+	// Insert時にnil（NULL）の場合に利用する値を"default=pending"のように指定する。
+	// データベース側にDEFAULTが無いが、アプリケーションとして初期値がある場合に利用する。
+	// 文字列、数値、boolのポインタ、またはsql.NullString等のsql.Scannerを実装した型のフィールドに指定でき、値にカンマは含められない。
+	// ゼロ値（false、0、""）を明示的に保存できなくなるため、ポインタ以外の型に指定した場合はpanicとなる。
+	TagOptionDefault = "default"
+
+	// 書き込み時に暗号化し、読み込み時に復号する。（Encryptorを参照）
+	TagOptionEncrypted = "encrypted"
+)
+
+// databaseタグを解析した結果
+type fieldTag struct {
+	Column  string
+	Options []string
+}
+
+func (t fieldTag) has(option string) bool {
+	for _, o := range t.Options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// "key=value"形式のオプションの値を返す。
+func (t fieldTag) value(key string) (string, bool) {
+	for _, o := range t.Options {
+		if v, ok := strings.CutPrefix(o, key+"="); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func (t fieldTag) hasDefault() bool {
+	_, ok := t.value(TagOptionDefault)
+	return ok
+}
+
+// Save、UpdateStruct等の構造体による更新の対象とするかどうか
+func (t fieldTag) updatable() bool {
+	return !t.has(TagOptionInsertOnly) && !t.has(TagOptionReadOnly)
+}
+
+func parseFieldTag(f reflect.StructField) fieldTag {
+	tag := f.Tag.Get("database")
+	parts := strings.Split(tag, ",")
+	t := fieldTag{Column: strings.TrimSpace(parts[0])}
+	for _, p := range parts[1:] {
+		if p = strings.TrimSpace(p); p != "" {
+			t.Options = append(t.Options, p)
+		}
+	}
+	if t.hasDefault() && !isNullableType(f.Type) {
+		panic(fmt.Sprintf(PanicDefaultRequiresNullableField, f.Name))
+	}
+	return t
+}
+
+// nil（NULL）とゼロ値を区別できる型かどうか（ポインタ、sql.NullString等）
+func isNullableType(rt reflect.Type) bool {
+	return rt.Kind() == reflect.Ptr || reflect.PointerTo(rt).Implements(scannerType)
+}
+
+var scannerType = reflect.TypeFor[sql.Scanner]()
+
+// モデルのカラムに対応するフィールドのインデックスとタグを返す。
+// テーブル名の指定等に利用する"_"のフィールドと、relationタグのフィールドは含まない。
+func columnFields(rt reflect.Type) iter.Seq2[int, fieldTag] {
+	return func(yield func(int, fieldTag) bool) {
+		for i := range rt.NumField() {
+			f := rt.Field(i)
+			if f.Name == "_" || f.Tag.Get("relation") != "" {
+				continue
+			}
+			if !yield(i, parseFieldTag(f)) {
+				return
+			}
+		}
+	}
+}
+
+// カラム名に対応するフィールドのタグを返す。
+// 存在しない場合はokがfalseとなる。
+func findFieldTag(rt reflect.Type, column string) (fieldTag, bool) {
+	for _, t := range columnFields(rt) {
+		if t.Column == column {
+			return t, true
+		}
+	}
+	return fieldTag{}, false
+}
+
+// 論理削除のカラム名を返す。
+func softDeleteColumn(rt reflect.Type) (string, bool) {
+	for _, t := range columnFields(rt) {
+		if t.has(TagOptionSoftDelete) {
+			return t.Column, true
+		}
+	}
+	return "", false
+}
+
+// 主キーのカラム名を返す。
+func primaryKeyColumn(rt reflect.Type) string {
+	for _, t := range columnFields(rt) {
+		if t.has(TagOptionPrimaryKey) {
+			return t.Column
+		}
+	}
+	return "id"
+}
+
+// 作成日時のカラム名を返す。扱わない場合はokがfalseとなる。
+func createdAtColumn(rt reflect.Type) (string, bool) {
+	return timestampColumn(rt, "created_at", CreatedAtColumn)
+}
+
+// 更新日時のカラム名を返す。扱わない場合はokがfalseとなる。
+func updatedAtColumn(rt reflect.Type) (string, bool) {
+	return timestampColumn(rt, "updated_at", UpdatedAtColumn)
+}
+
+// モデルの"_"のフィールドのタグで指定されたカラム名を優先し、無い場合はdefaultColumnを返す。
+// "-"が指定された場合はそのモデルでは扱わない。
+//
+//	_ struct{} `created_at:"inserted_at" updated_at:"modified_at"`
+func timestampColumn(rt reflect.Type, key string, defaultColumn string) (string, bool) {
+	column := defaultColumn
+	for i := range rt.NumField() {
+		f := rt.Field(i)
+		if f.Name != "_" {
+			continue
+		}
+		if c, ok := f.Tag.Lookup(key); ok {
+			column = c
+		}
+	}
+	if column == "" || column == "-" {
+		return "", false
+	}
+	return column, true
+}
+
+// Insertでセットせず、Save等の構造体による更新の対象外とするカラムを返す。
+// id、作成日時、更新日時のカラムと、generatedオプションのカラムが対象となる。
+func generatedColumns(rt reflect.Type) []string {
+	r := []string{"id"}
+	if c, ok := createdAtColumn(rt); ok {
+		r = append(r, c)
+	}
+	if c, ok := updatedAtColumn(rt); ok {
+		r = append(r, c)
+	}
+	for _, t := range columnFields(rt) {
+		if t.has(TagOptionGenerated) && !slices.Contains(r, t.Column) {
+			r = append(r, t.Column)
+		}
+	}
+	return r
+}
+
+// タグのオプションに応じて、SQLの引数として渡す値へ変換する。
+func toColumnValue(t fieldTag, v reflect.Value) any {
+	if t.has(TagOptionJSON) {
+		return marshalJSONColumn(v.Interface())
+	}
+	if t.has(TagOptionEncrypted) {
+		return encryptColumn(v.Interface())
+	}
+	return fieldValue(v)
+}
+
+// Insertで渡す値を返す。
+// nil（sql.NullString等の場合はValidがfalse）でdefaultオプションが指定されている場合はその値とする。
+func toInsertValue(t fieldTag, v reflect.Value) any {
+	if d, ok := t.value(TagOptionDefault); ok && v.IsZero() {
+		return parseDefaultValue(d, v.Type())
+	}
+	return toColumnValue(t, v)
+}
+
+// defaultオプションの値をフィールドの型へ変換する。
+func parseDefaultValue(s string, rt reflect.Type) any {
+	// sql.NullString等はScanによって文字列から変換する。
+	if rt.Kind() != reflect.Ptr && reflect.PointerTo(rt).Implements(scannerType) {
+		rv := reflect.New(rt)
+		if err := rv.Interface().(sql.Scanner).Scan(s); err != nil {
+			panic(fmt.Sprintf("invalid default value: %s", s))
+		}
+		return fieldValue(rv.Elem())
+	}
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	rv := reflect.New(rt).Elem()
+	var err error
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		n, err = strconv.ParseInt(s, 10, rt.Bits())
+		rv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		n, err = strconv.ParseUint(s, 10, rt.Bits())
+		rv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(s, rt.Bits())
+		rv.SetFloat(f)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(s)
+		rv.SetBool(b)
+	default:
+		panic(fmt.Sprintf("unsupported default value type: %s", rt))
+	}
+	if err != nil {
+		panic(fmt.Sprintf("invalid default value: %s", s))
+	}
+	return fieldValue(rv)
+}
+
+// nilの場合はNULLとして扱う。
+func marshalJSONColumn(v any) any {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("json marshal failed: %s", err))
+	}
+	return string(b)
+}
+
+// jsonbカラムの値をUnmarshalしてフィールドへ格納するためのScanner
+type jsonScanner struct {
+	dest reflect.Value
+}
+
+func (s *jsonScanner) Scan(src any) error {
+	// 前の行の値が残らないように、一旦ゼロ値にしておく。
+	s.dest.Set(reflect.Zero(s.dest.Type()))
+	if src == nil {
+		return nil
+	}
+	var b []byte
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unsupported json source type: %T", src)
+	}
+	return json.Unmarshal(b, s.dest.Addr().Interface())
+}
+
+// タグのオプションに応じて、Scanへ渡す値を返す。
+func toScanDest(t fieldTag, v reflect.Value) any {
+	if t.has(TagOptionJSON) {
+		return &jsonScanner{dest: v}
+	}
+	if t.has(TagOptionEncrypted) {
+		return &encryptedScanner{dest: v}
+	}
+	if t.has(TagOptionArray) {
+		// pgtype.Mapは並行利用できないため、都度生成する。
+		return pgtype.NewMap().SQLScanner(v.Addr().Interface())
+	}
+	return v.Addr().Interface()
+}
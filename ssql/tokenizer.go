@@ -0,0 +1,212 @@
+package ssql
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SQLを簡易的に字句解析する。
+//
+// 文字列リテラル、ドル引用符文字列、引用符付き識別子、コメントを正しく読み飛ばすため、
+// それらの中に含まれる"$"やキーワードを誤って検出することがない。
+// 構文解析は行わないため、SQLとして正しいかどうかはチェックしない。
+
+type tokenKind int
+
+const (
+	// キーワードや識別子
+	tokenWord tokenKind = iota
+	// "xxx"で囲まれた識別子
+	tokenQuotedIdent
+	// 'xxx'、E'xxx'、$$xxx$$等の文字列リテラル
+	tokenString
+	// $1等のプレースホルダー
+	tokenPlaceholder
+	tokenNumber
+	// 記号（"::"等の連続した記号もそれぞれ1文字ずつのトークンとなる）
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	// SQL内の開始位置（バイト）
+	pos  int
+	text string
+}
+
+func tokenize(query string) []token {
+	tokens := []token{}
+	i := 0
+	n := len(query)
+	for i < n {
+		c := query[i]
+		switch {
+		case isSpace(c):
+			i++
+		// 行コメント
+		case c == '-' && i+1 < n && query[i+1] == '-':
+			for i < n && query[i] != '\n' {
+				i++
+			}
+		// ブロックコメント（PostgreSQLではネスト可能）
+		case c == '/' && i+1 < n && query[i+1] == '*':
+			depth := 0
+			for i < n {
+				if query[i] == '/' && i+1 < n && query[i+1] == '*' {
+					depth++
+					i += 2
+				} else if query[i] == '*' && i+1 < n && query[i+1] == '/' {
+					depth--
+					i += 2
+					if depth == 0 {
+						break
+					}
+				} else {
+					i++
+				}
+			}
+		case c == '\'':
+			end := scanQuoted(query, i, '\'', false)
+			tokens = append(tokens, token{kind: tokenString, pos: i, text: query[i:end]})
+			i = end
+		// バックスラッシュによるエスケープを含む文字列リテラル
+		case (c == 'E' || c == 'e') && i+1 < n && query[i+1] == '\'':
+			end := scanQuoted(query, i+1, '\'', true)
+			tokens = append(tokens, token{kind: tokenString, pos: i, text: query[i:end]})
+			i = end
+		case c == '"':
+			end := scanQuoted(query, i, '"', false)
+			tokens = append(tokens, token{kind: tokenQuotedIdent, pos: i, text: query[i:end]})
+			i = end
+		case c == '$':
+			j := i + 1
+			for j < n && isDigit(query[j]) {
+				j++
+			}
+			if j > i+1 {
+				tokens = append(tokens, token{kind: tokenPlaceholder, pos: i, text: query[i:j]})
+				i = j
+				continue
+			}
+			// ドル引用符文字列: $$xxx$$ または $tag$xxx$tag$
+			for j < n && isWordChar(query[j]) {
+				j++
+			}
+			if j < n && query[j] == '$' {
+				tag := query[i : j+1]
+				end := strings.Index(query[j+1:], tag)
+				if end < 0 {
+					end = n
+				} else {
+					end = j + 1 + end + len(tag)
+				}
+				tokens = append(tokens, token{kind: tokenString, pos: i, text: query[i:end]})
+				i = end
+				continue
+			}
+			tokens = append(tokens, token{kind: tokenSymbol, pos: i, text: "$"})
+			i++
+		case isWordStart(c):
+			j := i + 1
+			for j < n && (isWordChar(query[j]) || query[j] == '$') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenWord, pos: i, text: query[i:j]})
+			i = j
+		case isDigit(c):
+			j := i + 1
+			for j < n && (isDigit(query[j]) || query[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, pos: i, text: query[i:j]})
+			i = j
+		default:
+			tokens = append(tokens, token{kind: tokenSymbol, pos: i, text: query[i : i+1]})
+			i++
+		}
+	}
+	return tokens
+}
+
+// 開始位置の引用符に対応する終了位置（終了引用符の次）を返す。
+// 引用符が2つ連続する場合はエスケープとして扱う。
+func scanQuoted(query string, start int, quote byte, backslashEscape bool) int {
+	i := start + 1
+	for i < len(query) {
+		switch {
+		case backslashEscape && query[i] == '\\':
+			i += 2
+		case query[i] == quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i += 2
+				continue
+			}
+			return i + 1
+		default:
+			i++
+		}
+	}
+	return len(query)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// マルチバイト文字は識別子の一部として扱う。
+func isWordStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || c >= 0x80
+}
+
+func isWordChar(c byte) bool {
+	return isWordStart(c) || isDigit(c)
+}
+
+// SQLの最初のキーワードを大文字で返す。
+func firstKeyword(query string) string {
+	for _, t := range tokenize(query) {
+		if t.kind == tokenWord {
+			return strings.ToUpper(t.text)
+		}
+		// (SELECT ...) 等の括弧は読み飛ばす
+		if t.text != "(" {
+			return ""
+		}
+	}
+	return ""
+}
+
+// SQL内のプレースホルダーの番号を出現順に返す。
+func placeholderNumbers(query string) []int {
+	r := []int{}
+	for _, t := range tokenize(query) {
+		if t.kind != tokenPlaceholder {
+			continue
+		}
+		n, err := strconv.Atoi(t.text[1:])
+		if err != nil {
+			panic(err)
+		}
+		r = append(r, n)
+	}
+	return r
+}
+
+// プレースホルダーの番号が1からargsの個数まで過不足なく使われていることをチェックする。
+// 同じ番号のプレースホルダーを複数回使うことは許容する。
+func checkPlaceholders(query string, args []any) {
+	used := make(map[int]struct{})
+	for _, n := range placeholderNumbers(query) {
+		if n < 1 || n > len(args) {
+			panic(PanicPlaceHolderNumberNotMatch)
+		}
+		used[n] = struct{}{}
+	}
+	if len(used) != len(args) {
+		panic(PanicPlaceHolderNumberNotMatch)
+	}
+}
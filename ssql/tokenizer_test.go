@@ -0,0 +1,102 @@
+package ssql
+
+import (
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestPlaceholderNumbers$ ./ssql
+func TestPlaceholderNumbers(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected []int
+	}{
+		{
+			name:     "simple",
+			query:    "SELECT * FROM users WHERE id = $1 AND name = $2",
+			expected: []int{1, 2},
+		},
+		{
+			name:     "repeated",
+			query:    "SELECT * FROM users WHERE id = $1 OR parent_id = $1",
+			expected: []int{1, 1},
+		},
+		{
+			name:     "dollar in string literal",
+			query:    "SELECT * FROM users WHERE price = '$100' AND id = $1",
+			expected: []int{1},
+		},
+		{
+			name:     "escaped quote in string literal",
+			query:    "SELECT * FROM users WHERE name = 'it''s $2' AND id = $1",
+			expected: []int{1},
+		},
+		{
+			name:     "backslash escape string",
+			query:    `SELECT * FROM users WHERE name = E'\'$2' AND id = $1`,
+			expected: []int{1},
+		},
+		{
+			name:     "dollar quoted string",
+			query:    "SELECT * FROM users WHERE name = $$ $2 $$ AND note = $tag$ $3 $tag$ AND id = $1",
+			expected: []int{1},
+		},
+		{
+			name:     "comment",
+			query:    "SELECT * FROM users -- $2\n WHERE /* $3 /* $4 */ */ id = $1",
+			expected: []int{1},
+		},
+		{
+			name:     "quoted identifier and cast",
+			query:    `SELECT "$2" FROM users WHERE id = $1::uuid`,
+			expected: []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.AssertDeepEqual(t, placeholderNumbers(tt.query), tt.expected)
+		})
+	}
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestCheckPlaceholders$ ./ssql
+func TestCheckPlaceholders(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		args      []any
+		wantPanic bool
+	}{
+		{name: "match", query: "SELECT * FROM users WHERE id = $1 AND name = $2", args: []any{1, "a"}},
+		{name: "repeated", query: "SELECT * FROM users WHERE id = $1 OR parent_id = $1", args: []any{1}},
+		{name: "literal dollar", query: "SELECT * FROM users WHERE price = '$100'", args: []any{}},
+		{name: "too few args", query: "SELECT * FROM users WHERE id = $1 AND name = $2", args: []any{1}, wantPanic: true},
+		{name: "too many args", query: "SELECT * FROM users WHERE id = $1", args: []any{1, 2}, wantPanic: true},
+		{name: "skipped number", query: "SELECT * FROM users WHERE id = $1 AND name = $3", args: []any{1, 2}, wantPanic: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if tt.wantPanic {
+					testutil.AssertEqual(t, r, PanicPlaceHolderNumberNotMatch)
+				} else if r != nil {
+					t.Errorf("expected no panic, but got panic: %v", r)
+				}
+			}()
+			checkPlaceholders(tt.query, tt.args)
+		})
+	}
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestFirstKeyword$ ./ssql
+func TestFirstKeyword(t *testing.T) {
+	testutil.AssertEqual(t, firstKeyword("  insert INTO users VALUES ($1)"), "INSERT")
+	testutil.AssertEqual(t, firstKeyword("-- comment\n(SELECT 1)"), "SELECT")
+	testutil.AssertEqual(t, firstKeyword("/* UPDATE */ DELETE FROM users"), "DELETE")
+	testutil.AssertEqual(t, firstKeyword(""), "")
+}
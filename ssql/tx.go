@@ -0,0 +1,202 @@
+package ssql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// トランザクションの開始時に指定するオプション
+type TxOptions struct {
+	// 分離レベル。指定しない場合はDBのデフォルト（PostgreSQLではREAD COMMITTED）となる。
+	Isolation sql.IsolationLevel
+	// 読み取り専用のトランザクションとする。書き込みを行った場合はDBのエラーとなる。
+	ReadOnly bool
+	// トランザクション内でロックの取得を待機する最大時間（SET LOCAL lock_timeout）
+	// 超えた場合、その文はErrLockNotAvailableを返す。
+	LockTimeout time.Duration
+	// トランザクション内の各文の最大実行時間（SET LOCAL statement_timeout）
+	// 超えた場合、その文はErrStatementTimeoutを返す。
+	StatementTimeout time.Duration
+}
+
+func (o *TxOptions) sqlOptions() *sql.TxOptions {
+	if o == nil {
+		return nil
+	}
+	return &sql.TxOptions{Isolation: o.Isolation, ReadOnly: o.ReadOnly}
+}
+
+// オプションに従ってトランザクションを開始する。
+// コンテキストのキャンセルによって開始できなかった場合はコンテキストのerrorを、
+// それ以外の理由で開始できなかった場合はErrBeginFailedをラップしたerrorを返す。
+func beginTx(c context.Context, db *sql.DB, opts *TxOptions) (*sql.Tx, error) {
+	tx, err := db.BeginTx(c, opts.sqlOptions())
+	if err != nil {
+		// リクエストのキャンセル等によりコネクションの取得を中断した場合
+		if c.Err() != nil {
+			return nil, c.Err()
+		}
+		return nil, fmt.Errorf("%w: %w", ErrBeginFailed, err)
+	}
+	// タイムアウトはPostgreSQLのみ対応している。（SQLiteではbusy_timeoutを利用する）
+	if opts == nil || !IsPostgres() || (opts.LockTimeout <= 0 && opts.StatementTimeout <= 0) {
+		return tx, nil
+	}
+	// SETではプレースホルダーを利用できないため、set_configで設定する。（第3引数のtrueでSET LOCALと同じ）
+	// 0はタイムアウト無しの意味となるため、指定されない場合はDBの設定のままとする。
+	settings := []string{}
+	args := []any{}
+	for _, t := range []struct {
+		name string
+		d    time.Duration
+	}{{"lock_timeout", opts.LockTimeout}, {"statement_timeout", opts.StatementTimeout}} {
+		if t.d <= 0 {
+			continue
+		}
+		args = append(args, strconv.FormatInt(max(t.d.Milliseconds(), 1), 10)+"ms")
+		settings = append(settings, fmt.Sprintf("set_config('%s', $%d, true)", t.name, len(args)))
+	}
+	if _, err := tx.ExecContext(c, "SELECT "+strings.Join(settings, ", "), args...); err != nil {
+		tx.Rollback()
+		if c.Err() != nil {
+			return nil, c.Err()
+		}
+		return nil, fmt.Errorf("%w: %w", ErrBeginFailed, err)
+	}
+	return tx, nil
+}
+
+// トランザクションの開始、コミット、ロールバックの失敗によるerrorかどうか
+// Transaction等ではpanicとし、TryTransactionではerrorとして返す。
+func isTxFailure(err error) bool {
+	return errors.Is(err, ErrBeginFailed) || errors.Is(err, ErrCommitFailed) || errors.Is(err, ErrRollbackFailed)
+}
+
+// 無名関数のpanicまたはerrorによるロールバックが失敗した場合のpanicの値
+// 元のpanicの値（またはerror）とロールバックのerrorの両方を保持する。
+type RollbackError struct {
+	// ロールバックの原因となった、無名関数のpanicの値またはerror
+	Cause any
+	// ロールバックで発生したerror
+	Err error
+}
+
+func (e *RollbackError) Error() string {
+	return fmt.Sprintf("%v (rollback failed: %s)", e.Cause, e.Err)
+}
+
+func (e *RollbackError) Unwrap() []error {
+	if cause, ok := e.Cause.(error); ok {
+		return []error{cause, ErrRollbackFailed, e.Err}
+	}
+	return []error{ErrRollbackFailed, e.Err}
+}
+
+// 明示的にコミット、ロールバックを行うトランザクション
+//
+// CLIでの対話的な処理やサガ等、無名関数の中に処理をまとめられない場合に利用する。
+// 通常はTransactionを利用すること。
+// QueryやExec、ORMの各関数にはそのままExecutorとして渡すことができる。
+//
+//	tx, err := ssql.Begin(c, nil)
+//	if err != nil {
+//		return err
+//	}
+//	defer tx.Rollback() // コミット済みの場合は何もしない
+//	...
+//	return tx.Commit()
+//
+// コミット、ロールバックの挙動（panicとなる条件、返すerror）はTransactionと同じ。
+type Tx struct {
+	tx   *sql.Tx
+	c    context.Context
+	opts *TxOptions
+}
+
+// トランザクションを開始する。
+// コンテキストがキャンセルされた場合はトランザクションはロールバックされ、以降の文の実行はエラーとなる。
+func Begin(c context.Context, opts *TxOptions) (*Tx, error) {
+	tx, err := beginTx(c, DB, opts)
+	if err != nil {
+		if isTxFailure(err) {
+			panic(err)
+		}
+		return nil, err
+	}
+	registerTx(c, DB, tx, opts)
+	return &Tx{tx: tx, c: c, opts: opts}, nil
+}
+
+// database/sqlのトランザクションを返す。
+// ContextWithTxでコンテキストへ設定する場合等に利用する。
+func (t *Tx) SQLTx() *sql.Tx {
+	return t.tx
+}
+
+// トランザクションをコミットする。
+// コンテキストがキャンセルされている場合はロールバックし、コンテキストのerrorを返す。
+func (t *Tx) Commit() error {
+	defer unregisterTx(t.tx)
+	err := commitTx(t.c, t.tx, t.opts)
+	if isTxFailure(err) {
+		panic(err)
+	}
+	return err
+}
+
+// トランザクションをロールバックする。
+// 既にコミットまたはロールバックされている場合は何もしない。（deferでの呼び出しを想定）
+func (t *Tx) Rollback() error {
+	defer unregisterTx(t.tx)
+	if err := t.tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		panic(err)
+	}
+	return nil
+}
+
+// セーブポイントを作成する。
+func (t *Tx) Savepoint(name string) error {
+	return t.execSavepoint("SAVEPOINT %s", name)
+}
+
+// セーブポイントまでロールバックする。トランザクション自体は継続する。
+// トランザクション内でエラーが発生した後に、処理を続行する場合に利用する。
+func (t *Tx) RollbackTo(name string) error {
+	return t.execSavepoint("ROLLBACK TO SAVEPOINT %s", name)
+}
+
+// セーブポイントを破棄する。それまでの変更はトランザクションに残る。
+func (t *Tx) ReleaseSavepoint(name string) error {
+	return t.execSavepoint("RELEASE SAVEPOINT %s", name)
+}
+
+func (t *Tx) execSavepoint(format string, name string) error {
+	if err := beforeTxStatement(t.tx); err != nil {
+		return err
+	}
+	query := fmt.Sprintf(format, QuoteIdentifier(name))
+	if _, err := t.tx.ExecContext(t.c, query); err != nil {
+		if t.c.Err() != nil {
+			return t.c.Err()
+		}
+		panic(fmt.Sprintf("query failed: %s, failed query: %s", err, query))
+	}
+	return nil
+}
+
+func (t *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, query, args...)
+}
+
+func (t *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, query, args...)
+}
+
+func (t *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return t.tx.QueryRowContext(ctx, query, args...)
+}
@@ -0,0 +1,36 @@
+package ssql
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestRollbackError$ ./ssql
+func TestRollbackError(t *testing.T) {
+	rbErr := errors.New("conn closed")
+
+	t.Run("panic value", func(t *testing.T) {
+		e := &RollbackError{Cause: "some panic", Err: rbErr}
+		testutil.AssertEqual(t, e.Error(), "some panic (rollback failed: conn closed)")
+		testutil.AssertTrue(t, errors.Is(e, rbErr))
+		testutil.AssertTrue(t, errors.Is(e, ErrRollbackFailed))
+	})
+
+	t.Run("error cause", func(t *testing.T) {
+		e := &RollbackError{Cause: ErrUniqConstraint, Err: rbErr}
+		testutil.AssertTrue(t, errors.Is(e, ErrUniqConstraint))
+		testutil.AssertTrue(t, errors.Is(e, rbErr))
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestIsTxFailure$ ./ssql
+func TestIsTxFailure(t *testing.T) {
+	testutil.AssertTrue(t, isTxFailure(fmt.Errorf("%w: %w", ErrBeginFailed, errors.New("dial error"))))
+	testutil.AssertTrue(t, isTxFailure(fmt.Errorf("%w: %w", ErrCommitFailed, errors.New("conn closed"))))
+	testutil.AssertTrue(t, isTxFailure(&RollbackError{Cause: ErrUniqConstraint, Err: errors.New("conn closed")}))
+	testutil.AssertFalse(t, isTxFailure(ErrUniqConstraint))
+	testutil.AssertFalse(t, isTxFailure(nil))
+}
@@ -0,0 +1,162 @@
+package ssql
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"sync"
+)
+
+type txKey struct{}
+
+// トランザクションをコンテキストへ設定する。
+//
+// Query、Exec、ORMの各関数は、txにnilを指定した場合、
+// WithContextで渡したコンテキストにトランザクションが設定されていればそれを利用する。
+// サービス層で開始したトランザクションを、関数の引数で引き回さずに下位の処理へ伝搬させる場合に利用する。
+//
+//	err := ssql.Transaction(c, func(tx *sql.Tx) error {
+//		c := ssql.ContextWithTx(c, tx)
+//		// 内部でssql.Find(nil, &User{}, where, values, ssql.WithContext(c))等を呼ぶ。
+//		return repository.UpdateUser(c, user)
+//	})
+func ContextWithTx(c context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(c, txKey{}, tx)
+}
+
+// コンテキストに設定されたトランザクションを返す。設定されていない場合はnilを返す。
+func TxFromContext(c context.Context) *sql.Tx {
+	tx, _ := c.Value(txKey{}).(*sql.Tx)
+	return tx
+}
+
+// txがnilの場合は、コンテキストに設定されたトランザクションを返す。
+// いずれも無い場合はnilのまま返す。（getExecutorによりDBが利用される）
+func contextTx(tx Executor, opt *options) Executor {
+	// txを指定せずにPreparedを利用した場合も、コンテキストのトランザクション上で実行する。
+	if p, ok := tx.(*preparedExecutor); ok && p.tx == nil {
+		if t := TxFromContext(opt.ctx); t != nil {
+			return &preparedExecutor{tx: t}
+		}
+		return tx
+	}
+	if tx != nil {
+		return tx
+	}
+	if t := TxFromContext(opt.ctx); t != nil {
+		return t
+	}
+	return nil
+}
+
+// TransactionまたはBeginで開始したトランザクションの状態
+type txState struct {
+	// 開始時に指定されたコンテキスト
+	c context.Context
+	// IdleTransactionWarnThresholdが設定されていない場合はnil
+	idle *idleWatch
+	// 読み取り専用のトランザクション（ReadTransaction等）
+	readOnly bool
+	// トランザクションを開始したDB（レプリカの場合もある）
+	db *sql.DB
+
+	mu sync.Mutex
+	// 書き込みを行ったテーブル。終了時にキャッシュを削除するために利用する。
+	writtenTables []string
+	// テーブルを特定できない書き込みを行った場合
+	writtenUnknown bool
+}
+
+// 書き込みを行ったSQLが対象とするテーブルを記録する。
+func (st *txState) recordWrite(query string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	tables := tablesInQuery(query)
+	if len(tables) == 0 {
+		st.writtenUnknown = true
+	}
+	for _, t := range tables {
+		if !slices.Contains(st.writtenTables, t) {
+			st.writtenTables = append(st.writtenTables, t)
+		}
+	}
+}
+
+// 書き込みを行ったテーブルを参照しているキャッシュを削除する。
+func (st *txState) invalidateCache() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if QueryCache == nil || (len(st.writtenTables) == 0 && !st.writtenUnknown) {
+		return
+	}
+	if st.writtenUnknown {
+		QueryCache.Clear()
+		return
+	}
+	for _, t := range st.writtenTables {
+		QueryCache.Invalidate(t)
+	}
+}
+
+// *sql.Txをキーとした実行中のトランザクションの状態
+var txStates sync.Map
+
+// トランザクションの開始時に状態を登録する。終了時に必ずunregisterTxを呼ぶこと。
+func registerTx(c context.Context, db *sql.DB, tx *sql.Tx, opts *TxOptions) {
+	txStates.Store(tx, &txState{c: c, idle: newIdleWatch(c), readOnly: opts != nil && opts.ReadOnly, db: db})
+}
+
+func unregisterTx(tx *sql.Tx) {
+	if v, ok := txStates.LoadAndDelete(tx); ok {
+		st := v.(*txState)
+		st.idle.stop()
+		// コミットまたはロールバックの後に、トランザクション中にキャッシュされた古い行を削除する。
+		st.invalidateCache()
+	}
+}
+
+// 各文の実行前に呼び出す。
+// txがTransactionまたはBeginで開始したトランザクションで、そのコンテキストが既にキャンセルされている場合はそのerrorを返す。
+// （キャンセル後に後続の文を実行しないため）
+// また、アイドル状態の監視のために最後に文を実行した時刻を更新する。
+func beforeTxStatement(tx Executor) error {
+	st := loadTxState(tx)
+	if st == nil {
+		return nil
+	}
+	if err := st.c.Err(); err != nil {
+		return err
+	}
+	st.idle.touch()
+	return nil
+}
+
+// デバッグモードでは、読み取り専用のトランザクション内での書き込みをpanicとする。
+func checkWritableTx(tx Executor) {
+	if !IsDebugMode() {
+		return
+	}
+	if st := loadTxState(tx); st != nil && st.readOnly {
+		panic(PanicExecInReadOnlyTransaction)
+	}
+}
+
+// TransactionまたはBeginで開始したトランザクションの状態を返す。それ以外の場合はnilを返す。
+func loadTxState(tx Executor) *txState {
+	var t *sql.Tx
+	switch e := tx.(type) {
+	case *sql.Tx:
+		t = e
+	case *Tx:
+		t = e.tx
+	case *preparedExecutor:
+		return loadTxState(e.tx)
+	default:
+		return nil
+	}
+	v, ok := txStates.Load(t)
+	if !ok {
+		return nil
+	}
+	return v.(*txState)
+}
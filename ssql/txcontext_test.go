@@ -0,0 +1,92 @@
+package ssql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestContextTx$ ./ssql
+func TestContextTx(t *testing.T) {
+	tx := &sql.Tx{}
+	c := ContextWithTx(context.Background(), tx)
+
+	t.Run("from context", func(t *testing.T) {
+		testutil.AssertEqual(t, TxFromContext(c) == tx, true)
+		testutil.AssertEqual(t, TxFromContext(context.Background()) == nil, true)
+	})
+
+	t.Run("nil tx uses context", func(t *testing.T) {
+		_, opt := splitArgs([]any{WithContext(c)})
+		testutil.AssertEqual(t, contextTx(nil, opt) == Executor(tx), true)
+	})
+
+	t.Run("explicit tx takes precedence", func(t *testing.T) {
+		other := &sql.Tx{}
+		_, opt := splitArgs([]any{WithContext(c)})
+		testutil.AssertEqual(t, contextTx(other, opt) == Executor(other), true)
+	})
+
+	t.Run("no tx", func(t *testing.T) {
+		_, opt := splitArgs([]any{})
+		testutil.AssertEqual(t, contextTx(nil, opt) == nil, true)
+	})
+
+	t.Run("prepared without tx uses context", func(t *testing.T) {
+		_, opt := splitArgs([]any{WithContext(c)})
+		testutil.AssertEqual(t, unwrapPrepared(contextTx(Prepared(nil), opt)) == Executor(tx), true)
+
+		_, opt = splitArgs([]any{})
+		testutil.AssertEqual(t, unwrapPrepared(contextTx(Prepared(nil), opt)) == nil, true)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestBeforeTxStatement$ ./ssql
+func TestBeforeTxStatement(t *testing.T) {
+	tx := &sql.Tx{}
+	c, cancel := context.WithCancel(context.Background())
+	registerTx(c, nil, tx, nil)
+	defer unregisterTx(tx)
+
+	testutil.AssertEqual(t, beforeTxStatement(tx), nil)
+	testutil.AssertEqual(t, beforeTxStatement(nil), nil)
+	testutil.AssertEqual(t, beforeTxStatement(&sql.Tx{}), nil)
+
+	cancel()
+	testutil.AssertEqual(t, beforeTxStatement(tx), context.Canceled)
+	testutil.AssertEqual(t, beforeTxStatement(&Tx{tx: tx}), context.Canceled)
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestCheckWritableTx$ ./ssql
+func TestCheckWritableTx(t *testing.T) {
+	orgMode := Mode
+	defer func() { Mode = orgMode }()
+	Mode = MODE_DEBUG
+
+	readTx := &sql.Tx{}
+	registerTx(context.Background(), nil, readTx, &TxOptions{ReadOnly: true})
+	defer unregisterTx(readTx)
+	writeTx := &sql.Tx{}
+	registerTx(context.Background(), nil, writeTx, nil)
+	defer unregisterTx(writeTx)
+
+	t.Run("read-only", func(t *testing.T) {
+		defer func() {
+			testutil.AssertEqual(t, recover(), PanicExecInReadOnlyTransaction)
+		}()
+		checkWritableTx(readTx)
+	})
+
+	t.Run("writable", func(t *testing.T) {
+		checkWritableTx(writeTx)
+		checkWritableTx(nil)
+	})
+
+	t.Run("production", func(t *testing.T) {
+		Mode = MODE_PRODUCTION
+		defer func() { Mode = MODE_DEBUG }()
+		checkWritableTx(readTx)
+	})
+}
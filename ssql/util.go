@@ -18,3 +18,8 @@ func StrContainListWithIgnoreCase(target string, str ...string) bool {
 func Ptr[T any](a T) *T {
 	return &a
 }
+
+func hasKey[K comparable, V any](m map[K]V, key K) bool {
+	_, ok := m[key]
+	return ok
+}
@@ -0,0 +1,159 @@
+package ssql
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// validateタグによる書き込み前の検証
+//
+// Insert、Update等の実行前に検証し、違反している場合はSQLを実行せずに*ValidationErrorを返す。
+// 複数のルールはカンマ区切りで指定する。
+//
+//	type User struct {
+//		Name   string `database:"name" validate:"required,max=50"`
+//		Status string `database:"status" validate:"oneof=active inactive"`
+//		Age    *int   `database:"age" validate:"min=0"`
+//	}
+//
+// 指定できるルール
+//   - required: ゼロ値（ポインタの場合はnil）を許容しない。
+//   - max=N, min=N: 文字列は文字数、スライスとマップは要素数、数値は値の範囲をチェックする。
+//   - oneof=a b c: スペース区切りで列挙した値のいずれかであること。
+//
+// ポインタがnilの場合はrequired以外のルールはチェックしない。
+//
+// Updateでは更新するカラムのみ、UpdateStructやSaveでは構造体のすべてのフィールドを検証する。
+const (
+	ValidateRequired = "required"
+	ValidateMax      = "max"
+	ValidateMin      = "min"
+	ValidateOneOf    = "oneof"
+)
+
+// validateタグに違反した場合のエラー
+// errors.Is(err, ErrValidation)で判定できる。
+type ValidationError struct {
+	Column string
+	// 違反したルール（"max=50"等）
+	Rule string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s violates %s", ErrValidation, e.Column, e.Rule)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// 構造体のすべてのフィールドを検証する。
+func validateStruct(s any) error {
+	rv := checkAndGetStructValue(s)
+	rt := rv.Type()
+	for i, tag := range columnFields(rt) {
+		if err := validateValue(tag.Column, rt.Field(i).Tag.Get("validate"), rv.Field(i)); err != nil {
+			return err
+		}
+		if err := validateEnum(tag.Column, rt.Field(i).Type, rv.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setMapsに含まれるカラムのみを検証する。
+func validateSetMaps(s any, setMaps map[string]any) error {
+	rt := checkAndGetStructValue(s).Type()
+	for i, tag := range columnFields(rt) {
+		value, ok := setMaps[tag.Column]
+		if !ok {
+			continue
+		}
+		if _, isExpr := value.(inlineValue); isExpr {
+			continue
+		}
+		if _, isNow := value.(NowValue); isNow {
+			continue
+		}
+		// nilはフィールドの型のゼロ値として扱う。
+		v := reflect.Zero(rt.Field(i).Type)
+		if value != nil {
+			v = reflect.ValueOf(value)
+		}
+		if err := validateValue(tag.Column, rt.Field(i).Tag.Get("validate"), v); err != nil {
+			return err
+		}
+		if err := validateEnum(tag.Column, rt.Field(i).Type, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateValue(column string, rules string, v reflect.Value) error {
+	if rules == "" {
+		return nil
+	}
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		name, param, _ := strings.Cut(rule, "=")
+		if name == ValidateRequired {
+			if v.IsZero() {
+				return &ValidationError{Column: column, Rule: rule}
+			}
+			continue
+		}
+
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				continue
+			}
+			v = v.Elem()
+		}
+		var ok bool
+		switch name {
+		case ValidateMax:
+			ok = measure(v, rule) <= parseRuleNumber(rule, param)
+		case ValidateMin:
+			ok = measure(v, rule) >= parseRuleNumber(rule, param)
+		case ValidateOneOf:
+			ok = slices.Contains(strings.Fields(param), fmt.Sprint(v.Interface()))
+		default:
+			panic(fmt.Sprintf("invalid validate rule: %s", rule))
+		}
+		if !ok {
+			return &ValidationError{Column: column, Rule: rule}
+		}
+	}
+	return nil
+}
+
+// maxやminの比較に利用する値を返す。
+func measure(v reflect.Value, rule string) float64 {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String()))
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	panic(fmt.Sprintf("validate rule %s does not support type: %s", rule, v.Type()))
+}
+
+func parseRuleNumber(rule string, param string) float64 {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("invalid validate rule: %s", rule))
+	}
+	return n
+}
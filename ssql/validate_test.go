@@ -0,0 +1,55 @@
+package ssql
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+type testValidateModel struct {
+	Name   string   `database:"name" validate:"required,max=5"`
+	Status string   `database:"status" validate:"oneof=active inactive"`
+	Age    *int     `database:"age" validate:"min=0,max=150"`
+	Tags   []string `database:"tags" validate:"max=2"`
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestValidate$ ./ssql
+func TestValidate(t *testing.T) {
+	age := func(n int) *int { return &n }
+
+	tests := []struct {
+		name     string
+		model    testValidateModel
+		expected *ValidationError
+	}{
+		{name: "valid", model: testValidateModel{Name: "あいうえお", Status: "active", Age: age(20), Tags: []string{"a"}}},
+		{name: "nil pointer is skipped", model: testValidateModel{Name: "a", Status: "inactive"}},
+		{name: "required", model: testValidateModel{Status: "active"}, expected: &ValidationError{Column: "name", Rule: "required"}},
+		{name: "max length", model: testValidateModel{Name: "abcdef", Status: "active"}, expected: &ValidationError{Column: "name", Rule: "max=5"}},
+		{name: "oneof", model: testValidateModel{Name: "a", Status: "deleted"}, expected: &ValidationError{Column: "status", Rule: "oneof=active inactive"}},
+		{name: "min value", model: testValidateModel{Name: "a", Status: "active", Age: age(-1)}, expected: &ValidationError{Column: "age", Rule: "min=0"}},
+		{name: "max elements", model: testValidateModel{Name: "a", Status: "active", Tags: []string{"a", "b", "c"}}, expected: &ValidationError{Column: "tags", Rule: "max=2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateStruct(&tt.model)
+			if tt.expected == nil {
+				testutil.AssertEqual(t, err == nil, true)
+				return
+			}
+			testutil.AssertEqual(t, errors.Is(err, ErrValidation), true)
+			var ve *ValidationError
+			testutil.AssertEqual(t, errors.As(err, &ve), true)
+			testutil.AssertDeepEqual(t, ve, tt.expected)
+		})
+	}
+
+	t.Run("set maps", func(t *testing.T) {
+		testutil.AssertEqual(t, validateSetMaps(&testValidateModel{}, map[string]any{"status": "active"}) == nil, true)
+		testutil.AssertEqual(t, validateSetMaps(&testValidateModel{}, map[string]any{"name": Expr("upper(name)")}) == nil, true)
+		err := validateSetMaps(&testValidateModel{}, map[string]any{"name": "abcdef"})
+		testutil.AssertEqual(t, err.Error(), "validation failed: name violates max=5")
+	})
+}
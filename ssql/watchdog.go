@@ -0,0 +1,80 @@
+package ssql
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TransactionまたはBeginで開始したトランザクションで、最後に文を実行してからこの時間を超えても
+// コミット、ロールバックされない場合に、開始した箇所を含めてLoggerで警告を出力する。
+// 0の場合は監視しない。
+//
+// PostgreSQLでは長時間開いたままのトランザクションがあると、VACUUMで不要な行を回収できずにテーブルが肥大化する。
+// コミットやロールバックの漏れ、トランザクション内での外部APIの呼び出し等を検出するために利用する。
+var IdleTransactionWarnThreshold time.Duration
+
+// トランザクションのアイドル状態を監視する。
+// 文を実行するたびにタイマーをリセットし、タイマーが発火した場合に警告を出力する。
+type idleWatch struct {
+	mu        sync.Mutex
+	timer     *time.Timer
+	threshold time.Duration
+	// トランザクションを開始した箇所（ファイル名:行番号）
+	caller string
+}
+
+func newIdleWatch(c context.Context) *idleWatch {
+	if IdleTransactionWarnThreshold <= 0 {
+		return nil
+	}
+	w := &idleWatch{threshold: IdleTransactionWarnThreshold, caller: callerOutsidePackage()}
+	w.timer = time.AfterFunc(w.threshold, func() {
+		l.Warn(c, fmt.Sprintf("transaction has been idle for more than %s, began at %s", w.threshold, w.caller))
+	})
+	return w
+}
+
+func (w *idleWatch) touch() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timer.Reset(w.threshold)
+}
+
+func (w *idleWatch) stop() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timer.Stop()
+}
+
+// このパッケージのディレクトリ
+var packageDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// 呼び出し履歴から、このパッケージの外（テストファイルを含む）の最初の呼び出し元を返す。
+func callerOutsidePackage() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if filepath.Dir(f.File) != packageDir || strings.HasSuffix(f.File, "_test.go") {
+			return fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
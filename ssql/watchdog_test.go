@@ -0,0 +1,82 @@
+package ssql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/megur0/testutil"
+)
+
+type recordLogger struct {
+	defaultLogger
+	mu    sync.Mutex
+	warns []string
+}
+
+func (r *recordLogger) Warn(c context.Context, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.warns = append(r.warns, fmt.Sprint(args...))
+}
+
+func (r *recordLogger) warnings() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.warns...)
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestIdleWatch$ ./ssql
+func TestIdleWatch(t *testing.T) {
+	org := l
+	defer SetLogger(org)
+	threshold := IdleTransactionWarnThreshold
+	defer func() { IdleTransactionWarnThreshold = threshold }()
+
+	t.Run("warn when idle", func(t *testing.T) {
+		rl := &recordLogger{}
+		SetLogger(rl)
+		IdleTransactionWarnThreshold = 20 * time.Millisecond
+
+		tx := &sql.Tx{}
+		registerTx(context.Background(), nil, tx, nil)
+		defer unregisterTx(tx)
+
+		time.Sleep(100 * time.Millisecond)
+		w := rl.warnings()
+		testutil.AssertEqual(t, len(w), 1)
+		testutil.AssertContainStr(t, w[0], "transaction has been idle for more than 20ms")
+		testutil.AssertContainStr(t, w[0], "watchdog_test.go")
+	})
+
+	t.Run("no warn while active", func(t *testing.T) {
+		rl := &recordLogger{}
+		SetLogger(rl)
+		IdleTransactionWarnThreshold = 50 * time.Millisecond
+
+		tx := &sql.Tx{}
+		registerTx(context.Background(), nil, tx, nil)
+		for range 5 {
+			time.Sleep(20 * time.Millisecond)
+			testutil.AssertEqual(t, beforeTxStatement(tx), nil)
+		}
+		unregisterTx(tx)
+
+		time.Sleep(100 * time.Millisecond)
+		testutil.AssertEqual(t, len(rl.warnings()), 0)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		IdleTransactionWarnThreshold = 0
+		testutil.AssertEqual(t, newIdleWatch(context.Background()) == nil, true)
+	})
+}
+
+// env `cat .env` go test -v -count=1 -timeout 60s -run ^TestCallerOutsidePackage$ ./ssql
+func TestCallerOutsidePackage(t *testing.T) {
+	testutil.AssertEqual(t, strings.Contains(callerOutsidePackage(), "watchdog_test.go:"), true)
+}
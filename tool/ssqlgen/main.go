@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ssqlgen
+//
+// "//ssql:generate"のコメントを付けたモデルの構造体に対して、
+// ssql.ColumnScanner、ssql.ColumnValuerのメソッドを生成する。
+// 生成したメソッドはQuery、Insert、Save等から自動的に利用され、
+// スキャンや書き込みの際のタグの解析やリフレクションによるフィールドへのアクセスが不要となる。
+//
+// models.goに対してmodels_ssql.goを同じディレクトリに出力する。
+//
+//	//go:generate go run github.com/megur0/simple-sql/tool/ssqlgen $GOFILE
+//
+//	//ssql:generate
+//	type User struct {
+//		ID   uuid.UUID `database:"id"`
+//		Name string    `database:"name"`
+//	}
+//
+// json、array、omitempty、encrypted、defaultオプションはリフレクションによる変換が必要なため、
+// それらを含むモデルや埋め込みフィールド（JOIN用のモデル）は生成の対象にできない。
+
+const annotation = "//ssql:generate"
+
+// go run ./tool/ssqlgen ./models.go
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: ssqlgen file.go ...")
+		os.Exit(2)
+	}
+	for _, filename := range flag.Args() {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			fail(err)
+		}
+		out, err := generate(filename, src)
+		if err != nil {
+			fail(err)
+		}
+		if out == nil {
+			continue
+		}
+		if err := os.WriteFile(strings.TrimSuffix(filename, ".go")+"_ssql.go", out, 0o644); err != nil {
+			fail(err)
+		}
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "ssqlgen:", err)
+	os.Exit(1)
+}
+
+type model struct {
+	name   string
+	fields []field
+}
+
+type field struct {
+	name       string
+	column     string
+	pointer    bool
+	builtin    bool
+	readOnly   bool
+	insertOnly bool
+}
+
+// ソースから対象のモデルを探し、生成したファイルの内容を返す。
+// 対象のモデルが無い場合はnilを返す。
+func generate(filename string, src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	models := []model{}
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			doc := ts.Doc
+			if doc == nil && len(gd.Specs) == 1 {
+				doc = gd.Doc
+			}
+			if !hasAnnotation(doc) {
+				continue
+			}
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				return nil, fmt.Errorf("%s is not a struct", ts.Name.Name)
+			}
+			m, err := parseModel(ts.Name.Name, st)
+			if err != nil {
+				return nil, err
+			}
+			models = append(models, m)
+		}
+	}
+	if len(models) == 0 {
+		return nil, nil
+	}
+	return render(f.Name.Name, models)
+}
+
+func hasAnnotation(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == annotation {
+			return true
+		}
+	}
+	return false
+}
+
+func parseModel(name string, st *ast.StructType) (model, error) {
+	m := model{name: name}
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 {
+			return m, fmt.Errorf("%s: embedded field is not supported", name)
+		}
+		tag := reflect.StructTag("")
+		if f.Tag != nil {
+			s, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return m, err
+			}
+			tag = reflect.StructTag(s)
+		}
+		for _, n := range f.Names {
+			if n.Name == "_" || tag.Get("relation") != "" {
+				continue
+			}
+			db := tag.Get("database")
+			if db == "" {
+				return m, fmt.Errorf("%s.%s has no database tag", name, n.Name)
+			}
+			parts := strings.Split(db, ",")
+			fd := field{name: n.Name, column: strings.TrimSpace(parts[0])}
+			for _, o := range parts[1:] {
+				switch o = strings.TrimSpace(o); {
+				case o == "readonly":
+					fd.readOnly = true
+				case o == "insert_only":
+					fd.insertOnly = true
+				case o == "json", o == "array", o == "omitempty", o == "encrypted", strings.HasPrefix(o, "default="):
+					return m, fmt.Errorf("%s.%s: option %s is not supported", name, n.Name, o)
+				}
+			}
+			switch t := f.Type.(type) {
+			case *ast.StarExpr:
+				fd.pointer = true
+			case *ast.Ident:
+				fd.builtin = isBuiltinType(t.Name)
+			}
+			m.fields = append(m.fields, fd)
+		}
+	}
+	return m, nil
+}
+
+func isBuiltinType(name string) bool {
+	switch name {
+	case "string", "bool", "int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64", "float32", "float64", "byte", "rune":
+		return true
+	}
+	return false
+}
+
+// SQLの引数として渡す式を返す。
+// ポインタのフィールドと組み込み型のフィールドはそのまま渡す。
+// それ以外（time.Time、uuid.UUID、driver.Valuerを実装した型等）は、
+// ポインタレシーバでValueを実装している場合も考慮してアドレスを渡す。
+func (f field) valueExpr() string {
+	if f.pointer || f.builtin {
+		return "m." + f.name
+	}
+	return "&m." + f.name
+}
+
+func render(pkg string, models []model) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by ssqlgen. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	b.WriteString("import \"github.com/megur0/simple-sql/ssql\"\n")
+	for _, m := range models {
+		fmt.Fprintf(&b, "\nvar (\n_ ssql.ColumnScanner = (*%s)(nil)\n_ ssql.ColumnValuer = %s{}\n)\n", m.name, m.name)
+
+		fmt.Fprintf(&b, "\nfunc (m *%s) ScanTargets(columns []string) ([]any, bool) {\n", m.name)
+		b.WriteString("targets := make([]any, len(columns))\nfor i, c := range columns {\nswitch c {\n")
+		for _, f := range m.fields {
+			fmt.Fprintf(&b, "case %q:\ntargets[i] = &m.%s\n", f.column, f.name)
+		}
+		b.WriteString("default:\nreturn nil, false\n}\n}\nreturn targets, true\n}\n")
+
+		writeColumnValues(&b, m, "InsertColumnValues", func(f field) bool { return !f.readOnly })
+		writeColumnValues(&b, m, "UpdateColumnValues", func(f field) bool { return !f.readOnly && !f.insertOnly })
+	}
+	return format.Source(b.Bytes())
+}
+
+func writeColumnValues(b *bytes.Buffer, m model, method string, include func(field) bool) {
+	columns := []string{}
+	values := []string{}
+	for _, f := range m.fields {
+		if include(f) {
+			columns = append(columns, strconv.Quote(f.column))
+			values = append(values, f.valueExpr())
+		}
+	}
+	fmt.Fprintf(b, "\nfunc (m %s) %s() ([]string, []any) {\n", m.name, method)
+	fmt.Fprintf(b, "return []string{%s}, []any{%s}\n}\n", strings.Join(columns, ", "), strings.Join(values, ", "))
+}
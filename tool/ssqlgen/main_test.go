@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/megur0/testutil"
+)
+
+// go test -v -count=1 -timeout 60s -run ^TestGenerate$ ./tool/ssqlgen
+func TestGenerate(t *testing.T) {
+	src := "package models\n" + `
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+//ssql:generate
+type User struct {
+	_         struct{}  ` + "`table:\"app_users\"`" + `
+	ID        uuid.UUID ` + "`database:\"id\"`" + `
+	Name      *string   ` + "`database:\"name\"`" + `
+	Age       int       ` + "`database:\"age,insert_only\"`" + `
+	Total     int       ` + "`database:\"total,readonly\"`" + `
+	CreatedAt time.Time ` + "`database:\"created_at\"`" + `
+}
+
+type NotGenerated struct {
+	ID int ` + "`database:\"id\"`" + `
+}
+`
+	out, err := generate("models.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(out)
+	testutil.AssertEqual(t, strings.HasPrefix(s, "// Code generated by ssqlgen. DO NOT EDIT."), true)
+	testutil.AssertContainStr(t, s, "func (m *User) ScanTargets(columns []string) ([]any, bool) {")
+	testutil.AssertContainStr(t, s, "\t\tcase \"total\":\n\t\t\ttargets[i] = &m.Total\n")
+	testutil.AssertContainStr(t, s, `return []string{"id", "name", "age", "created_at"}, []any{&m.ID, m.Name, m.Age, &m.CreatedAt}`)
+	testutil.AssertContainStr(t, s, `return []string{"id", "name", "created_at"}, []any{&m.ID, m.Name, &m.CreatedAt}`)
+	testutil.AssertEqual(t, strings.Contains(s, "NotGenerated"), false)
+
+	out, err = generate("models.go", []byte("package models\n\ntype A struct{}\n"))
+	testutil.AssertEqual(t, err == nil, true)
+	testutil.AssertEqual(t, out == nil, true)
+}
+
+// go test -v -count=1 -timeout 60s -run ^TestGenerateUnsupported$ ./tool/ssqlgen
+func TestGenerateUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		err  string
+	}{
+		{name: "json", body: "Data map[string]any `database:\"data,json\"`", err: "User.Data: option json is not supported"},
+		{name: "default", body: "Status string `database:\"status,default=active\"`", err: "User.Status: option default=active is not supported"},
+		{name: "embedded", body: "Base", err: "User: embedded field is not supported"},
+		{name: "no tag", body: "Name string", err: "User.Name has no database tag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := generate("models.go", []byte("package models\n\n//ssql:generate\ntype User struct {\n"+tt.body+"\n}\n"))
+			testutil.AssertEqual(t, err.Error(), tt.err)
+		})
+	}
+}